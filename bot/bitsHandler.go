@@ -4,22 +4,54 @@ import (
 	"fmt"
 	"medgebot/bot/viewer"
 	log "medgebot/logger"
+	"sort"
 )
 
-// RegisterBitsHandler adds the Bits handler logic to the Bot
-func (bot *Bot) RegisterBitsHandler(messageTemplate HandlerTemplate) {
+// BitsTier replaces the bits message for cheers of at least MinBits
+type BitsTier struct {
+	MinBits  int
+	Template HandlerTemplate
+}
+
+// RegisterBitsHandler adds the Bits handler logic to the Bot. The largest tier the cheer
+// reaches is used over the bits message
+func (bot *Bot) RegisterBitsHandler(messageTemplate HandlerTemplate, tiers []BitsTier) {
+	bot.registerFeature(FeatureBits)
+	bot.setTemplate(TemplateBits, messageTemplate)
+
+	tiers = append([]BitsTier{}, tiers...)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinBits > tiers[j].MinBits
+	})
+
 	bot.RegisterHandler(
 		NewHandler(func(evt Event) {
+			if !bot.FeatureEnabled(FeatureBits) {
+				return
+			}
+
 			if evt.IsBitsEvent() {
 				log.Info(fmt.Sprintf("> %s cheered %d bits!", evt.Sender, evt.Amount))
-				bot.SendMessage(messageTemplate.Parse(evt))
+
+				tmpl := bot.template(TemplateBits)
+				for _, tier := range tiers {
+					if evt.Amount >= tier.MinBits {
+						tmpl = tier.Template
+						break
+					}
+				}
+
+				// Parsed separately, as the cheer message may contain format verbs
+				if msg := tmpl.Parse(evt); msg != "" {
+					bot.SendMessage("%s", msg)
+				}
 
 				metric := viewer.Metric{
 					Name:   evt.Sender,
 					Amount: evt.Amount,
 				}
-				bot.dataStore.Put(viewer.LastBits, metric.String())
+				bot.putMetric(viewer.LastBits, metric)
 			}
-		}),
+		}).Named(FeatureBits),
 	)
 }
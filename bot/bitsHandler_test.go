@@ -18,7 +18,7 @@ func TestBitsHandler(t *testing.T) {
 	tmpl := bottest.MakeTemplate("testBits", "Thanks for the {{.Amount}} bits {{.Sender}}")
 	bot.RegisterBitsHandler(HandlerTemplate{
 		template: tmpl,
-	})
+	}, nil)
 
 	// This must happen after Handler registration, else data race occurs
 	bot.Start()
@@ -47,7 +47,7 @@ func TestBitsHandlerIgnoresInvalidEvents(t *testing.T) {
 	tmpl := bottest.MakeTemplate("testBits", "Thanks for the {{.Amount}} bits {{.Sender}}")
 	bot.RegisterBitsHandler(HandlerTemplate{
 		template: tmpl,
-	})
+	}, nil)
 
 	// This must happen after Handler registration, else data race occurs
 	bot.Start()
@@ -63,3 +63,38 @@ func TestBitsHandlerIgnoresInvalidEvents(t *testing.T) {
 		// If we don't receive a response, the Bot didn't erroneously parse the wrong message
 	}
 }
+
+func TestBitsHandlerTiers(t *testing.T) {
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+
+	base, _ := ParseHandlerTemplate(TemplateBits, "{{.Sender}} cheered {{.Amount}}")
+	hundred, _ := ParseHandlerTemplate(TemplateBits, "{{.Sender}} cheered {{.Amount}}: {{.Message}}")
+	thousand, _ := ParseHandlerTemplate(TemplateBits, "{{if .Anonymous}}Someone{{else}}{{.Sender}}{{end}} made it rain {{.Amount}}!")
+	bot.RegisterBitsHandler(base, []BitsTier{
+		{MinBits: 1000, Template: thousand},
+		{MinBits: 100, Template: hundred},
+	})
+	bot.Start()
+
+	tests := []struct {
+		evt      Event
+		expected string
+	}{
+		{Event{Type: BITS, Sender: "a", Amount: 99}, "a cheered 99"},
+		{Event{Type: BITS, Sender: "b", Amount: 100, Message: "100% hype"}, "b cheered 100: 100% hype"},
+		{Event{Type: BITS, Sender: "c", Amount: 999}, "c cheered 999: "},
+		{Event{Type: BITS, Sender: AnonymousCheerer, Amount: 5000, Anonymous: true}, "Someone made it rain 5000!"},
+	}
+
+	for _, test := range tests {
+		bot.events <- test.evt
+
+		response := <-checker.events
+		if response.Message != test.expected {
+			t.Errorf("Expected [%s] for %d bits, got [%s]", test.expected, test.evt.Amount, response.Message)
+		}
+	}
+}
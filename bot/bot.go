@@ -3,8 +3,12 @@ package bot
 import (
 	"errors"
 	"fmt"
+	"medgebot/bot/poll"
+	"medgebot/bot/viewer"
 	"medgebot/cache"
 	"medgebot/logger"
+	"medgebot/telemetry"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -30,17 +34,61 @@ type Bot struct {
 	// Cache for various handler metrics
 	dataStore cache.Cache
 
+	// Notified when state rendered by overlays changes
+	stateListeners []StateListener
+
+	// Notified of each message the Bot sends to Chat
+	messageListeners []MessageListener
+
+	// Runtime-changeable settings. Separate lock from the Bot so handlers can
+	// read settings while the listen loop holds the Bot lock
+	settingsMu  sync.RWMutex
+	features    map[string]bool
+	unavailable map[string]string // Reason each unavailable feature can't be turned on
+	templates   map[string]HandlerTemplate
+	commands    []Command
+
+	// goals
+	goalsMu sync.Mutex
+	goals   []Goal
+
+	// event history
+	historyMu    sync.Mutex
+	historySize  int
+	history      []HistoryEntry
+	historyTimer *time.Timer // Pending write of the history, nil when none
+
 	// polls
-	pollRunning  bool
-	pollQuestion string
-	pollAnswers  []PollAnswer
+	pollMu      sync.Mutex
+	poll        *poll.Poll // nil when no Poll is running
+	pollTimer   *time.Timer
+	pollHistory []poll.Poll
+
+	// raid history
+	raiders raidHistory
+
+	// greeter. Custom greetings are by lowercased username
+	greeter     *greetingBatcher
+	greeted     cache.Cache
+	greetingsMu sync.Mutex
+	greetings   map[string]HandlerTemplate
 }
 
-// PollAnswer keeps track of an Answer label and number of votes for that answer
-type PollAnswer struct {
-	Answer string
-	Count  int
-}
+var (
+	eventsReceived = telemetry.NewCounter("medgebot_events_received_total",
+		"Events received by the Bot, by type", "type")
+	messagesSent = telemetry.NewCounter("medgebot_messages_sent_total",
+		"Messages the Bot sent to Chat")
+	pollVotes = telemetry.NewCounter("medgebot_poll_votes_total",
+		"Votes counted in Polls")
+)
+
+// StateListener is called with a topic whenever Bot state changes. Topics are
+// either a viewer.Metric cache key or PollTopic
+type StateListener func(topic string)
+
+// MessageListener is called with each message Event the Bot sends to Chat
+type MessageListener func(evt Event)
 
 // New produces a newly instantiated Bot
 func New(metricsCache cache.Cache) Bot {
@@ -50,7 +98,11 @@ func New(metricsCache cache.Cache) Bot {
 		events:      make(chan Event, 0),
 		listening:   false,
 		dataStore:   metricsCache,
-		pollRunning: false,
+		features:    make(map[string]bool),
+		unavailable: make(map[string]string),
+		templates:   make(map[string]HandlerTemplate),
+		commands:    make([]Command, 0),
+		greetings:   make(map[string]HandlerTemplate),
 	}
 }
 
@@ -90,145 +142,100 @@ func (bot *Bot) RegisterHandler(consumer Handler) error {
 	bot.Lock()
 	defer bot.Unlock()
 
+	// Unnamed handlers are reported by registration order
+	if consumer.name == "" {
+		consumer.name = strconv.Itoa(len(bot.consumers))
+	}
+
 	consumers := append(bot.consumers, consumer)
 	bot.consumers = consumers
 	return nil
 }
 
-// ReceiveEvent is a way for code to directly queue Events to be processed. Ex: alias commands
-func (bot *Bot) ReceiveEvent(evt Event) {
-	bot.events <- evt
-}
+// AddStateListener registers a function to be notified of Bot state changes.
+// Listeners are called synchronously, so they should not block
+func (bot *Bot) AddStateListener(listener StateListener) {
+	bot.Lock()
+	defer bot.Unlock()
 
-// SendMessage sends a message to the given channel, without prefix
-func (bot *Bot) SendMessage(message string, args ...interface{}) {
-	if strings.TrimSpace(message) == "" {
-		return
-	}
+	bot.stateListeners = append(bot.stateListeners, listener)
+}
 
-	evt := NewChatEvent()
-	evt.Message = fmt.Sprintf(message, args...)
+// AddMessageListener registers a function to be notified of messages the Bot sends
+// to Chat. Listeners are called synchronously, so they should not block
+func (bot *Bot) AddMessageListener(listener MessageListener) {
+	bot.Lock()
+	defer bot.Unlock()
 
-	go bot.sendEvent(evt)
+	bot.messageListeners = append(bot.messageListeners, listener)
 }
 
-// IsPollRunning checks if a Poll is currently active
-func (bot *Bot) IsPollRunning() bool {
-	return bot.pollRunning
+// notifyStateChange informs all StateListeners that the given topic changed
+func (bot *Bot) notifyStateChange(topic string) {
+	for _, listener := range bot.stateListeners {
+		listener(topic)
+	}
 }
 
-// StartPoll starts a poll within the Bot. Returns error if poll already running.
-// Store reference to Poll contents for Handler use later
-func (bot *Bot) StartPoll(duration time.Duration, question string, answers []string) error {
-	if bot.IsPollRunning() {
-		return errors.New("Poll already running")
+// putMetric stores the given Metric in the dataStore and notifies listeners.
+// The Metric is timestamped if it has no Time
+func (bot *Bot) putMetric(key string, metric viewer.Metric) {
+	if metric.Time.IsZero() {
+		metric.Time = time.Now()
 	}
 
-	bot.ClearPoll()
-	logger.Info("Starting new Poll: %s", question)
-	bot.pollRunning = true
-	bot.pollQuestion = question
-
-	pollAnswers := make([]PollAnswer, len(answers))
-	for idx, answer := range answers {
-		pollAnswers[idx] = PollAnswer{
-			Answer: answer,
-			Count:  0,
-		}
+	if err := bot.dataStore.Put(key, metric.String()); err != nil {
+		logger.Error(err, "store metric %s", key)
 	}
-	bot.pollAnswers = pollAnswers
-
-	bot.SendPollMessage()
-
-	// Spawn off goroutine to close the poll after the given Duration
-	go func(bot *Bot, dur time.Duration) {
-		select {
-		case <-time.After(dur):
-			bot.closePoll()
-		}
-	}(bot, duration)
 
-	return nil
+	bot.notifyStateChange(key)
 }
 
-// SendPollMessage sends the current Poll message, if a poll is running
-func (bot *Bot) SendPollMessage() {
-	if !bot.pollRunning {
-		bot.SendMessage("No poll running")
-		return
-	}
-
-	formattedAnswers := ""
-	for idx, pollAnswer := range bot.pollAnswers {
-		formattedAnswers += fmt.Sprintf("%d: %s | ", idx+1, pollAnswer.Answer)
-	}
-
-	bot.SendMessage("Poll started! Type a number only in chat to vote! Question: %s - | %s", bot.pollQuestion, formattedAnswers)
+// ReceiveEvent is a way for code to directly queue Events to be processed. Ex: alias commands
+func (bot *Bot) ReceiveEvent(evt Event) {
+	bot.events <- evt
 }
 
-// AddPollVote increments the Count for the given Answer key
-func (bot *Bot) AddPollVote(key int) {
-	if key < 1 || key > len(bot.pollAnswers) {
-		// Invalid vote. Skip
+// SendMessage sends a message to the given channel, without prefix
+func (bot *Bot) SendMessage(message string, args ...interface{}) {
+	if strings.TrimSpace(message) == "" {
 		return
 	}
 
-	bot.pollAnswers[key-1].Count++
-}
+	evt := NewChatEvent()
+	evt.Message = fmt.Sprintf(message, args...)
 
-// GetPollState returns the current question, answers, and vote counts for each answer
-func (bot *Bot) GetPollState() (question string, answers []PollAnswer) {
-	return bot.pollQuestion, bot.pollAnswers
+	go bot.sendEvent(evt)
 }
 
-// closePoll ends an active poll
-func (bot *Bot) closePoll() {
-	highestIdx := -1
-	winningAnswer := PollAnswer{}
-	for idx, answer := range bot.pollAnswers {
-		if answer.Count >= winningAnswer.Count {
-			highestIdx = idx
-			winningAnswer = answer
-		}
-	}
+// sendEvent sends a Bot event to Write-enabled clients
+func (bot *Bot) sendEvent(evt Event) {
+	bot.chatClient.Channel() <- evt
+	messagesSent.Inc()
 
-	// If no votes - exit immediately
-	if highestIdx == -1 {
-		bot.SendMessage("No poll winner")
-		bot.ClearPoll()
-		return
+	for _, listener := range bot.messageListeners {
+		listener(evt)
 	}
+}
 
-	// Format winning response and account for ties
-	winnerStr := fmt.Sprintf("[%d] %s with %d votes", highestIdx+1, winningAnswer.Answer, winningAnswer.Count)
-	for idx, answer := range bot.pollAnswers {
-		if idx == highestIdx {
-			continue
-		}
-
-		if answer.Count == winningAnswer.Count {
-			winnerStr += " | "
-			winnerStr += fmt.Sprintf("[%d] %s with %d votes", idx+1, answer.Answer, answer.Count)
+// Healthy returns an error naming any handler that has been processing a single Event for
+// longer than maxBusy. A stuck handler eventually blocks every other handler, as the
+// listen loop waits for room in its queue
+func (bot *Bot) Healthy(maxBusy time.Duration) error {
+	// consumers can't change once listening, and the listen loop holds the Bot lock
+	// while blocked on a stuck handler, so it is read without locking
+	var stuck []string
+	for _, consumer := range bot.consumers {
+		if busy := consumer.BusyFor(); busy > maxBusy {
+			stuck = append(stuck, fmt.Sprintf("%s (%s)", consumer.name, busy.Round(time.Second)))
 		}
 	}
-	bot.SendMessage("Poll Winner(s): %s", winnerStr)
 
-	// Ensure poll clears
-	logger.Info("Closing poll")
-	bot.ClearPoll()
-}
-
-// ClearPoll clears out any existing Poll state in the Bot and the dataStore
-func (bot *Bot) ClearPoll() {
-	bot.pollRunning = false
-	bot.pollQuestion = ""
-	bot.pollAnswers = []PollAnswer{}
-	bot.dataStore.Clear("voters")
-}
+	if len(stuck) > 0 {
+		return errors.New("handlers stuck: " + strings.Join(stuck, ", "))
+	}
 
-// sendEvent sends a Bot event to Write-enabled clients
-func (bot *Bot) sendEvent(evt Event) {
-	bot.chatClient.Channel() <- evt
+	return nil
 }
 
 // Start listening for Events on the inbound channel and broadcast out
@@ -244,6 +251,7 @@ func (bot *Bot) listen() {
 	for {
 		select {
 		case evt := <-bot.events:
+			eventsReceived.Inc(evt.TypeName())
 			bot.Mutex.Lock()
 			for _, consumer := range bot.consumers {
 				consumer.Receive(evt)
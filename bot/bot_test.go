@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"medgebot/bot/bottest"
+	"medgebot/bot/viewer"
+	"medgebot/cache"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestStateListenerNotifiedOnMetricChange(t *testing.T) {
+	// Initialize Bot
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+
+	topics := make(chan string, 1)
+	bot.AddStateListener(func(topic string) {
+		topics <- topic
+	})
+
+	tmpl := bottest.MakeTemplate("testBits", "Thanks for the {{.Amount}} bits {{.Sender}}")
+	bot.RegisterBitsHandler(NewHandlerTemplate(tmpl), nil)
+
+	// This must happen after Handler registration, else data race occurs
+	bot.Start()
+
+	evt := NewBitsEvent()
+	evt.Sender = "ReallyFrank"
+	evt.Amount = 100
+	bot.events <- evt
+
+	select {
+	case topic := <-topics:
+		if topic != viewer.LastBits {
+			t.Fatalf("Expected %s topic, got %s", viewer.LastBits, topic)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("StateListener not notified of metric change")
+	}
+}
+
+func TestDisabledFeatureIgnoresEvents(t *testing.T) {
+	// Initialize Bot
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+
+	tmpl := bottest.MakeTemplate("testBits", "Thanks for the {{.Amount}} bits {{.Sender}}")
+	bot.RegisterBitsHandler(NewHandlerTemplate(tmpl), nil)
+
+	if err := bot.SetFeatureEnabled(FeatureBits, false); err != nil {
+		t.Fatalf("Failed to disable bits feature: %v", err)
+	}
+
+	// This must happen after Handler registration, else data race occurs
+	bot.Start()
+
+	evt := NewBitsEvent()
+	evt.Sender = "ReallyFrank"
+	evt.Amount = 100
+	bot.events <- evt
+
+	select {
+	case resp := <-checker.events:
+		t.Fatalf("Received message from disabled BitsHandler - %+v", resp)
+	case <-time.After(100 * time.Millisecond):
+		// If we don't receive a response, the disabled feature was skipped
+	}
+}
+
+func TestUnavailableFeatureCannotBeEnabled(t *testing.T) {
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+	bot.RegisterChannelPointHandler()
+	bot.SetFeatureUnavailable(FeatureChannelPoints, "needs PubSub, which was not started")
+
+	if err := bot.SetFeatureEnabled(FeatureChannelPoints, false); err != nil {
+		t.Fatalf("Failed to disable unavailable feature: %v", err)
+	}
+
+	err := bot.SetFeatureEnabled(FeatureChannelPoints, true)
+	if errors.Cause(err) != ErrFeatureUnavailable {
+		t.Fatalf("Expected ErrFeatureUnavailable, got %v", err)
+	}
+
+	if bot.FeatureEnabled(FeatureChannelPoints) {
+		t.Fatalf("Expected unavailable feature to stay disabled")
+	}
+}
+
+func TestSetMessageFormat(t *testing.T) {
+	// Initialize Bot
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+
+	tmpl := bottest.MakeTemplate("testBits", "Thanks for the {{.Amount}} bits {{.Sender}}")
+	bot.RegisterBitsHandler(NewHandlerTemplate(tmpl), nil)
+
+	if err := bot.SetMessageFormat(TemplateBits, "{{.NotAField}}"); err == nil {
+		t.Fatalf("Expected template with unknown field to be rejected")
+	}
+
+	if err := bot.SetMessageFormat("unknown", "{{.Sender}}"); err == nil {
+		t.Fatalf("Expected unknown template to be rejected")
+	}
+
+	if err := bot.SetMessageFormat(TemplateBits, "{{.Sender}} cheered {{.Amount}}"); err != nil {
+		t.Fatalf("Valid template rejected: %v", err)
+	}
+
+	// This must happen after Handler registration, else data race occurs
+	bot.Start()
+
+	evt := NewBitsEvent()
+	evt.Sender = "ReallyFrank"
+	evt.Amount = 100
+	bot.events <- evt
+
+	response := <-checker.events
+	if response.Message != "ReallyFrank cheered 100" {
+		t.Fatalf("Changed template not used. Got: %+v", response)
+	}
+}
+
+func TestStuckHandlerIsUnhealthy(t *testing.T) {
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+
+	release := make(chan bool)
+	defer close(release)
+	bot.RegisterHandler(NewHandler(func(evt Event) {
+		<-release
+	}).Named("stuck"))
+
+	bot.Start()
+
+	if err := bot.Healthy(time.Minute); err != nil {
+		t.Fatalf("Expected healthy before any Event, got %v", err)
+	}
+
+	bot.events <- NewBitsEvent()
+	time.Sleep(20 * time.Millisecond)
+
+	if err := bot.Healthy(time.Minute); err != nil {
+		t.Fatalf("Expected healthy while under maxBusy, got %v", err)
+	}
+
+	err := bot.Healthy(10 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Fatalf("Expected the stuck handler to be named, got %v", err)
+	}
+}
@@ -6,9 +6,15 @@ import (
 
 // RegisterChannelPointHandler responds to Channel Point redemption messages
 func (bot *Bot) RegisterChannelPointHandler() {
+	bot.registerFeature(FeatureChannelPoints)
+
 	bot.RegisterHandler(
 		NewHandler(func(evt Event) {
+			if !bot.FeatureEnabled(FeatureChannelPoints) || !evt.IsPointsEvent() {
+				return
+			}
+
 			log.Info("%+v", evt)
-		}),
+		}).Named(FeatureChannelPoints),
 	)
 }
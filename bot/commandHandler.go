@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // Command represents a known Command from Config that the Bot can respond to
@@ -15,6 +17,37 @@ type Command struct {
 	MessageTemplate HandlerTemplate
 }
 
+// NewCommand creates a Command that either responds with the messageFormat template,
+// or, if aliasFor is set, is an alias for another command
+func NewCommand(prefix, messageFormat, aliasFor string) (Command, error) {
+	if !strings.HasPrefix(prefix, "!") || strings.ContainsAny(prefix, " \t") {
+		return Command{}, errors.Errorf("command prefix must start with ! and contain no spaces - %s", prefix)
+	}
+
+	if aliasFor != "" {
+		return Command{
+			Prefix:   prefix,
+			IsAlias:  true,
+			AliasFor: aliasFor,
+		}, nil
+	}
+
+	tmpl, err := ParseHandlerTemplate(prefix, messageFormat)
+	if err != nil {
+		return Command{}, err
+	}
+
+	return Command{
+		Prefix:          prefix,
+		MessageTemplate: tmpl,
+	}, nil
+}
+
+// MessageFormat returns the text the Command's message template was parsed from
+func (c *Command) MessageFormat() string {
+	return c.MessageTemplate.Format()
+}
+
 // ParsedMessage Return the interpolated Message for the given command
 func (c *Command) ParsedMessage(evt Event) string {
 	return c.MessageTemplate.Parse(evt)
@@ -22,10 +55,18 @@ func (c *Command) ParsedMessage(evt Event) string {
 
 // HandleCommands is the chat component of KnownCommands
 func (bot *Bot) HandleCommands(knownCommands []Command) {
+	bot.registerFeature(FeatureCommands)
+	bot.setCommands(knownCommands)
+
 	bot.RegisterHandler(
 		NewHandler(func(evt Event) {
+			if !bot.FeatureEnabled(FeatureCommands) {
+				return
+			}
+
 			if evt.IsChatEvent() {
 				contents := evt.Message
+				knownCommands := bot.Commands()
 
 				// 1: check if the chat event is a Command message
 				// 2: Is the command an Alias? If so - alter contents and send back through the bot
@@ -98,6 +139,6 @@ func (bot *Bot) HandleCommands(knownCommands []Command) {
 					bot.SendMessage(fmt.Sprintf("@%s flipped: %s", evt.Sender, result))
 				}
 			}
-		}),
+		}).Named(FeatureCommands),
 	)
 }
@@ -7,6 +7,53 @@ const (
 	GIFTSUB
 	POINT_REDEMPTION
 	RAID
+	MYSTERY_GIFT
+	SUB_UPGRADE
+	BITS_BADGE
+	ANNOUNCEMENT
+	UNRAID
+	RITUAL
+	HYPE
+)
+
+// eventTypeNames maps Event types to the names used in config.yaml and the API
+var eventTypeNames = map[int]string{
+	CHAT_MSG:         "chat",
+	BITS:             "bits",
+	SUB:              "sub",
+	GIFTSUB:          "giftsub",
+	POINT_REDEMPTION: "channelPoints",
+	RAID:             "raid",
+	MYSTERY_GIFT:     "mysterygift",
+	SUB_UPGRADE:      "subupgrade",
+	BITS_BADGE:       "bitsbadge",
+	ANNOUNCEMENT:     "announcement",
+	UNRAID:           "unraid",
+	RITUAL:           "ritual",
+	HYPE:             "hype",
+}
+
+// EventTypeName returns the config/API name for the given Event type, or
+// an empty string if unknown
+func EventTypeName(eventType int) string {
+	return eventTypeNames[eventType]
+}
+
+// ParseEventType returns the Event type for the given config/API name
+func ParseEventType(name string) (int, bool) {
+	for eventType, typeName := range eventTypeNames {
+		if typeName == name {
+			return eventType, true
+		}
+	}
+
+	return 0, false
+}
+
+// Senders Twitch uses for users who chose not to be named
+const (
+	AnonymousGifter  = "AnAnonymousGifter"  // Anonymous gift subs
+	AnonymousCheerer = "AnAnonymousCheerer" // Anonymous cheers
 )
 
 // Event is an all-encompassing model for Events that the Bot understands
@@ -14,10 +61,31 @@ const (
 type Event struct {
 	Type      int    // Identify what kind of Event we are receiving
 	Sender    string // Source user, empty if not tied to a user
-	Recipient string // Target user, if applicable (i.e gifted subscription)
+	Recipient string // Target user, if applicable (i.e gifted subscription, or the gifter of an upgraded gift sub)
 	Message   string // User-supplied message, empty if not provided
-	Amount    int    // Any numerical amount tied to the message (bits, points, sub count)
-	Title     string // title of the Channel Point redemption made
+	Amount    int    // Any numerical amount tied to the message (bits, points, sub count, bits badge tier)
+	Title     string // title of the Channel Point redemption made, or the ritual name
+	Moderator bool   // Sender is a moderator or the broadcaster, for chat commands that manage the Bot
+
+	FirstMessage bool // Chat message is the Sender's first ever in the channel
+	Anonymous    bool // Sender chose not to be named. Sender is then a placeholder, ex: AnAnonymousGifter
+
+	// Sub and gift sub details. Message holds the resub message, if any
+	Tier       int  // Sub tier, 1 to 3. Prime subs are tier 1
+	Prime      bool // Sub paid for with Prime Gaming
+	Streak     int  // Consecutive months subscribed, if the subscriber shared it
+	GiftMonths int  // Months gifted at once
+
+	// Ties the gift subs of a community gift to its mystery gift Event. Empty for single gift subs
+	GiftOrigin string
+
+	// Hype Events only: everyone who supported during the burst, most support first
+	Contributors []string
+}
+
+// TypeName returns the config/API name of the Event's type
+func (evt Event) TypeName() string {
+	return EventTypeName(evt.Type)
 }
 
 func NewChatEvent() Event {
@@ -79,3 +147,81 @@ func NewRaidEvent() Event {
 func (evt Event) IsRaidEvent() bool {
 	return evt.Type == RAID
 }
+
+// NewMysteryGiftEvent is a community gift of Amount subs. Each gifted sub follows as a gift sub Event
+func NewMysteryGiftEvent() Event {
+	return Event{
+		Type: MYSTERY_GIFT,
+	}
+}
+
+func (evt Event) IsMysteryGiftEvent() bool {
+	return evt.Type == MYSTERY_GIFT
+}
+
+// NewSubUpgradeEvent is a gifted or Prime sub continued as a paid sub. Recipient is the
+// original gifter, empty for Prime or anonymous gifts
+func NewSubUpgradeEvent() Event {
+	return Event{
+		Type: SUB_UPGRADE,
+	}
+}
+
+func (evt Event) IsSubUpgradeEvent() bool {
+	return evt.Type == SUB_UPGRADE
+}
+
+// NewBitsBadgeEvent is a bits badge tier unlocked. Amount is the tier, ex: 1000
+func NewBitsBadgeEvent() Event {
+	return Event{
+		Type: BITS_BADGE,
+	}
+}
+
+func (evt Event) IsBitsBadgeEvent() bool {
+	return evt.Type == BITS_BADGE
+}
+
+// NewAnnouncementEvent is a mod's /announce message
+func NewAnnouncementEvent() Event {
+	return Event{
+		Type: ANNOUNCEMENT,
+	}
+}
+
+func (evt Event) IsAnnouncementEvent() bool {
+	return evt.Type == ANNOUNCEMENT
+}
+
+// NewUnraidEvent is a raid on the channel being canceled
+func NewUnraidEvent() Event {
+	return Event{
+		Type: UNRAID,
+	}
+}
+
+func (evt Event) IsUnraidEvent() bool {
+	return evt.Type == UNRAID
+}
+
+// NewRitualEvent is a chat ritual, ex: a new chatter introducing themselves. Title is the ritual name
+func NewRitualEvent() Event {
+	return Event{
+		Type: RITUAL,
+	}
+}
+
+func (evt Event) IsRitualEvent() bool {
+	return evt.Type == RITUAL
+}
+
+// NewHypeEvent is a burst of support reaching a new level. Amount is the level, from 1
+func NewHypeEvent() Event {
+	return Event{
+		Type: HYPE,
+	}
+}
+
+func (evt Event) IsHypeEvent() bool {
+	return evt.Type == HYPE
+}
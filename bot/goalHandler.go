@@ -0,0 +1,458 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	log "medgebot/logger"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Goal types, named by the Events they count
+const (
+	GoalSubs = "subs" // One per sub or gifted sub
+	GoalBits = "bits" // Amount of bits cheered
+)
+
+// GoalTopic is the state topic used to notify listeners of Goal changes
+const GoalTopic = "goals"
+
+// goalsKey is the dataStore key Goals are persisted at
+const goalsKey = "goals"
+
+// defaultMilestones are the percentages announced for Goals without configured milestones
+var defaultMilestones = []int{25, 50, 75, 100}
+
+// Goal tracks progress towards a target number of subs or bits
+type Goal struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Target     int    `json:"target"`
+	Current    int    `json:"current"`
+	Milestones []int  `json:"milestones"`
+
+	// Announced is the highest milestone already announced in Chat
+	Announced int `json:"announced"`
+}
+
+// GoalMilestone is the data for the goals message template
+type GoalMilestone struct {
+	Goal
+	Milestone int    // Percentage of the Target reached
+	Sender    string // Who pushed the Goal over the milestone. Empty if adjusted by a mod
+}
+
+// NewGoal creates a Goal with no progress. Milestones are percentages of the
+// target announced in Chat, defaulting to 25/50/75/100
+func NewGoal(name, goalType, title string, target int, milestones []int) (Goal, error) {
+	if name == "" || strings.ContainsAny(name, " \t/") {
+		return Goal{}, errors.Errorf("goal name must be set and contain no spaces or slashes - %q", name)
+	}
+
+	if goalType != GoalSubs && goalType != GoalBits {
+		return Goal{}, errors.Errorf("goal %s type must be %s or %s - %q", name, GoalSubs, GoalBits, goalType)
+	}
+
+	if target <= 0 {
+		return Goal{}, errors.Errorf("goal %s target must be above 0", name)
+	}
+
+	if len(milestones) == 0 {
+		milestones = defaultMilestones
+	}
+	milestones = append([]int{}, milestones...)
+	sort.Ints(milestones)
+	for _, milestone := range milestones {
+		if milestone <= 0 || milestone > 100 {
+			return Goal{}, errors.Errorf("goal %s milestones must be percentages from 1 to 100", name)
+		}
+	}
+
+	if title == "" {
+		title = name
+	}
+
+	return Goal{
+		Name:       name,
+		Type:       goalType,
+		Title:      title,
+		Target:     target,
+		Milestones: milestones,
+	}, nil
+}
+
+// Percent of the Target reached. May be above 100
+func (g Goal) Percent() int {
+	if g.Target <= 0 {
+		return 0
+	}
+
+	return g.Current * 100 / g.Target
+}
+
+// amountFor returns how much the Event counts towards the Goal
+func (g Goal) amountFor(evt Event) int {
+	switch g.Type {
+	case GoalSubs:
+		if evt.IsSubEvent() || evt.IsGiftSubEvent() {
+			return 1
+		}
+	case GoalBits:
+		if evt.IsBitsEvent() {
+			return evt.Amount
+		}
+	}
+
+	return 0
+}
+
+// reached returns the highest milestone the Goal's progress has reached, or 0
+func (g Goal) reached() int {
+	percent := g.Percent()
+	highest := 0
+	for _, milestone := range g.Milestones {
+		if milestone <= percent {
+			highest = milestone
+		}
+	}
+
+	return highest
+}
+
+// ParseGoalTemplate parses the goals message template, validated against a GoalMilestone
+func ParseGoalTemplate(format string) (HandlerTemplate, error) {
+	return parseTemplate(TemplateGoals, format, GoalMilestone{})
+}
+
+// RegisterGoalHandler tracks the given Goals from sub, gift sub and bits Events, announcing
+// milestones with the messageTemplate. Progress stored from a previous run is kept for the
+// matching Goal. Also handles the !goal chat command
+func (bot *Bot) RegisterGoalHandler(goals []Goal, messageTemplate HandlerTemplate) {
+	bot.registerFeature(FeatureGoals)
+	bot.setTemplate(TemplateGoals, messageTemplate)
+	bot.loadGoals(goals)
+
+	bot.RegisterHandler(
+		NewHandler(func(evt Event) {
+			if !bot.FeatureEnabled(FeatureGoals) {
+				return
+			}
+
+			if evt.IsChatEvent() {
+				if evt.Message == "!goal" || strings.HasPrefix(evt.Message, "!goal ") {
+					bot.handleGoalCommand(evt)
+				}
+				return
+			}
+
+			bot.addGoalProgress(evt)
+		}).Named(FeatureGoals),
+	)
+}
+
+// loadGoals sets the known Goals. Configured Goals keep their definition, taking only
+// their progress from the persisted Goal of the same name
+func (bot *Bot) loadGoals(goals []Goal) {
+	persisted := make(map[string]Goal)
+	if stored, err := bot.dataStore.GetOrDefault(goalsKey, ""); err != nil {
+		log.Error(err, "fetch goals from bot.dataStore")
+	} else if stored != "" {
+		var storedGoals []Goal
+		if err := json.Unmarshal([]byte(stored), &storedGoals); err != nil {
+			log.Error(err, "parse stored goals")
+		}
+		for _, goal := range storedGoals {
+			persisted[goal.Name] = goal
+		}
+	}
+
+	bot.goalsMu.Lock()
+	defer bot.goalsMu.Unlock()
+
+	bot.goals = make([]Goal, 0, len(goals)+len(persisted))
+	for _, goal := range goals {
+		if stored, ok := persisted[goal.Name]; ok {
+			goal.Current = stored.Current
+			goal.Announced = stored.Announced
+			delete(persisted, goal.Name)
+		}
+		bot.goals = append(bot.goals, goal)
+	}
+
+	// Goals added at runtime are kept, even if not in config
+	for _, goal := range persisted {
+		bot.goals = append(bot.goals, goal)
+	}
+}
+
+// Goals returns the known Goals
+func (bot *Bot) Goals() []Goal {
+	bot.goalsMu.Lock()
+	defer bot.goalsMu.Unlock()
+
+	return append([]Goal{}, bot.goals...)
+}
+
+// Goal returns the known Goal with the given name
+func (bot *Bot) Goal(name string) (Goal, bool) {
+	bot.goalsMu.Lock()
+	defer bot.goalsMu.Unlock()
+
+	for _, goal := range bot.goals {
+		if goal.Name == name {
+			return goal, true
+		}
+	}
+
+	return Goal{}, false
+}
+
+// SetGoal adds the Goal, or replaces the known Goal with the same name.
+// Milestones already reached are not announced
+func (bot *Bot) SetGoal(goal Goal) {
+	goal.Announced = goal.reached()
+
+	bot.updateGoals(func() {
+		for idx, known := range bot.goals {
+			if known.Name == goal.Name {
+				bot.goals[idx] = goal
+				return
+			}
+		}
+
+		bot.goals = append(bot.goals, goal)
+	})
+}
+
+// RemoveGoal removes the known Goal with the given name.
+// Returns false if there was no such Goal
+func (bot *Bot) RemoveGoal(name string) bool {
+	removed := false
+	bot.updateGoals(func() {
+		for idx, known := range bot.goals {
+			if known.Name == name {
+				bot.goals = append(bot.goals[:idx], bot.goals[idx+1:]...)
+				removed = true
+				return
+			}
+		}
+	})
+
+	return removed
+}
+
+// AdjustGoal adds the amount, which may be negative, to the named Goal's progress.
+// Milestones reached are announced
+func (bot *Bot) AdjustGoal(name string, amount int) (Goal, error) {
+	var adjusted Goal
+	var milestones []GoalMilestone
+	found := false
+
+	bot.updateGoals(func() {
+		for idx := range bot.goals {
+			if bot.goals[idx].Name != name {
+				continue
+			}
+
+			found = true
+			milestones = bot.progressGoal(&bot.goals[idx], amount, "")
+			adjusted = bot.goals[idx]
+			return
+		}
+	})
+
+	if !found {
+		return Goal{}, errors.Errorf("unknown goal %s", name)
+	}
+
+	bot.announceMilestones(milestones)
+	return adjusted, nil
+}
+
+// addGoalProgress counts the Event towards every Goal it applies to
+func (bot *Bot) addGoalProgress(evt Event) {
+	var milestones []GoalMilestone
+	changed := false
+
+	bot.goalsMu.Lock()
+	for idx := range bot.goals {
+		amount := bot.goals[idx].amountFor(evt)
+		if amount == 0 {
+			continue
+		}
+
+		changed = true
+		milestones = append(milestones, bot.progressGoal(&bot.goals[idx], amount, evt.Sender)...)
+	}
+	bot.goalsMu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	bot.persistGoals()
+	bot.announceMilestones(milestones)
+}
+
+// progressGoal adds the amount to the Goal, returning the milestone reached, if any.
+// Caller must hold goalsMu
+func (bot *Bot) progressGoal(goal *Goal, amount int, sender string) []GoalMilestone {
+	goal.Current += amount
+	if goal.Current < 0 {
+		goal.Current = 0
+	}
+
+	reached := goal.reached()
+	if reached <= goal.Announced {
+		// Progress removed, so milestones can be announced again once reached
+		goal.Announced = reached
+		return nil
+	}
+
+	goal.Announced = reached
+	return []GoalMilestone{{
+		Goal:      *goal,
+		Milestone: reached,
+		Sender:    sender,
+	}}
+}
+
+// updateGoals runs the change while holding goalsMu, then persists the Goals
+func (bot *Bot) updateGoals(change func()) {
+	bot.goalsMu.Lock()
+	change()
+	bot.goalsMu.Unlock()
+
+	bot.persistGoals()
+}
+
+// persistGoals stores the Goals in the dataStore and notifies listeners
+func (bot *Bot) persistGoals() {
+	goals, err := json.Marshal(bot.Goals())
+	if err != nil {
+		log.Error(err, "encode goals")
+		return
+	}
+
+	if err := bot.dataStore.Put(goalsKey, string(goals)); err != nil {
+		log.Error(err, "store goals")
+	}
+
+	bot.notifyStateChange(GoalTopic)
+}
+
+// announceMilestones sends the goals message for each milestone reached
+func (bot *Bot) announceMilestones(milestones []GoalMilestone) {
+	for _, milestone := range milestones {
+		if msg := bot.template(TemplateGoals).execute(milestone); msg != "" {
+			bot.SendMessage("%s", msg)
+		}
+	}
+}
+
+// handleGoalCommand responds to !goal with the progress of every Goal.
+// Mods can also change progress:
+//
+//	!goal add NAME AMOUNT    - add (or, if negative, remove) progress
+//	!goal set NAME AMOUNT    - set progress
+//	!goal target NAME AMOUNT - change the target
+//	!goal reset NAME         - set progress to 0
+//
+// Milestones reached by add or set are announced. Milestones reached by changing the target
+// are not, like SetGoal
+func (bot *Bot) handleGoalCommand(evt Event) {
+	tokens := strings.Fields(evt.Message)
+	if len(tokens) == 1 {
+		bot.SendMessage("%s", goalSummary(bot.Goals()))
+		return
+	}
+
+	if !evt.Moderator || len(tokens) < 3 {
+		return
+	}
+
+	action, name := tokens[1], tokens[2]
+	if _, ok := bot.Goal(name); !ok {
+		bot.SendMessage("@%s unknown goal %s", evt.Sender, name)
+		return
+	}
+
+	amount := 0
+	if action != "reset" {
+		if len(tokens) < 4 {
+			return
+		}
+
+		var err error
+		if amount, err = strconv.Atoi(tokens[3]); err != nil {
+			bot.SendMessage("@%s %s is not a number", evt.Sender, tokens[3])
+			return
+		}
+	}
+
+	switch action {
+	case "add", "set", "reset":
+	case "target":
+		if amount <= 0 {
+			bot.SendMessage("@%s goal target must be above 0", evt.Sender)
+			return
+		}
+	default:
+		return
+	}
+
+	// Changed in one update, so progress counted meanwhile isn't lost
+	var updated Goal
+	var milestones []GoalMilestone
+	found := false
+	bot.updateGoals(func() {
+		for idx := range bot.goals {
+			goal := &bot.goals[idx]
+			if goal.Name != name {
+				continue
+			}
+
+			found = true
+			switch action {
+			case "add":
+				milestones = bot.progressGoal(goal, amount, "")
+			case "set":
+				milestones = bot.progressGoal(goal, amount-goal.Current, "")
+			case "reset":
+				milestones = bot.progressGoal(goal, -goal.Current, "")
+			case "target":
+				goal.Target = amount
+				goal.Announced = goal.reached()
+			}
+			updated = *goal
+			return
+		}
+	})
+
+	if !found {
+		bot.SendMessage("@%s unknown goal %s", evt.Sender, name)
+		return
+	}
+
+	bot.announceMilestones(milestones)
+	bot.SendMessage("%s", goalSummary([]Goal{updated}))
+}
+
+// goalSummary describes the progress of each Goal in a chat message
+func goalSummary(goals []Goal) string {
+	if len(goals) == 0 {
+		return "No goals right now!"
+	}
+
+	summaries := make([]string, 0, len(goals))
+	for _, goal := range goals {
+		summaries = append(summaries,
+			fmt.Sprintf("%s: %d/%d %s (%d%%)", goal.Title, goal.Current, goal.Target, goal.Type, goal.Percent()))
+	}
+
+	return strings.Join(summaries, " | ")
+}
@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"medgebot/cache"
+	"testing"
+	"time"
+)
+
+func newGoalTestBot(t *testing.T, store *cache.PersistableCache) (*Bot, TestChatClient) {
+	bot := New(store)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+
+	goal, err := NewGoal("subathon", GoalSubs, "Subathon", 4, []int{50, 100})
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+
+	tmpl, err := ParseGoalTemplate("{{.Title}} hit {{.Milestone}}%{{if .Sender}} thanks to {{.Sender}}{{end}}! {{.Current}}/{{.Target}}")
+	if err != nil {
+		t.Fatalf("Failed to parse goal template: %v", err)
+	}
+
+	bot.RegisterGoalHandler([]Goal{goal}, tmpl)
+
+	// This must happen after Handler registration, else data race occurs
+	bot.Start()
+	return &bot, checker
+}
+
+func expectMessage(t *testing.T, checker TestChatClient, expected string) {
+	select {
+	case response := <-checker.events:
+		if response.Message != expected {
+			t.Fatalf("Expected [%s], got [%s]", expected, response.Message)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for [%s]", expected)
+	}
+}
+
+func TestGoalAnnouncesMilestones(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newGoalTestBot(t, &store)
+
+	sub := NewSubEvent()
+	sub.Sender = "saltymoth"
+	bot.events <- sub
+
+	// Bits don't count towards a subs goal
+	bits := NewBitsEvent()
+	bits.Amount = 100
+	bot.events <- bits
+
+	gift := NewGiftSubEvent()
+	gift.Sender = "BlackMarvel"
+	gift.Recipient = "nojoy"
+	bot.events <- gift
+
+	expectMessage(t, checker, "Subathon hit 50% thanks to BlackMarvel! 2/4")
+
+	goal, _ := bot.Goal("subathon")
+	if goal.Current != 2 {
+		t.Fatalf("Expected 2 subs towards the goal, got %d", goal.Current)
+	}
+}
+
+func TestGoalProgressPersisted(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, _ := newGoalTestBot(t, &store)
+
+	if _, err := bot.AdjustGoal("subathon", 1); err != nil {
+		t.Fatalf("Failed to adjust goal: %v", err)
+	}
+
+	// A restarted Bot picks up the stored progress over the configured Goal
+	restarted, _ := newGoalTestBot(t, &store)
+	goal, ok := restarted.Goal("subathon")
+	if !ok || goal.Current != 1 {
+		t.Fatalf("Expected stored progress of 1, got %+v", goal)
+	}
+}
+
+func TestGoalDefinitionFromConfig(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, _ := newGoalTestBot(t, &store)
+
+	if _, err := bot.AdjustGoal("subathon", 3); err != nil {
+		t.Fatalf("Failed to adjust goal: %v", err)
+	}
+
+	// The target was raised in config.yaml before the restart
+	restarted := New(&store)
+	goal, err := NewGoal("subathon", GoalSubs, "Bigger Subathon", 10, []int{50, 100})
+	if err != nil {
+		t.Fatalf("Failed to create goal: %v", err)
+	}
+	tmpl, _ := ParseGoalTemplate("{{.Title}} hit {{.Milestone}}%")
+	restarted.RegisterGoalHandler([]Goal{goal}, tmpl)
+
+	loaded, ok := restarted.Goal("subathon")
+	if !ok || loaded.Target != 10 || loaded.Title != "Bigger Subathon" {
+		t.Fatalf("Expected the configured target and title, got %+v", loaded)
+	}
+	if loaded.Current != 3 || loaded.Announced != 50 {
+		t.Fatalf("Expected stored progress of 3 with 50%% announced, got %+v", loaded)
+	}
+}
+
+func TestGoalChatCommandSetAnnouncesMilestones(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newGoalTestBot(t, &store)
+
+	mod := NewChatEvent()
+	mod.Sender = "ReallyFrank"
+	mod.Moderator = true
+	mod.Message = "!goal set subathon 2"
+	bot.events <- mod
+
+	expectMessages(t, checker, "Subathon hit 50%! 2/4", "Subathon: 2/4 subs (50%)")
+
+	// Lowering the target doesn't announce the milestones it reaches
+	mod.Message = "!goal target subathon 2"
+	bot.events <- mod
+	expectMessage(t, checker, "Subathon: 2/2 subs (100%)")
+
+	goal, _ := bot.Goal("subathon")
+	if goal.Announced != 100 {
+		t.Fatalf("Expected 100%% marked announced, got %+v", goal)
+	}
+}
+
+func TestGoalChatCommandRequiresModerator(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newGoalTestBot(t, &store)
+
+	viewer := NewChatEvent()
+	viewer.Sender = "notamod"
+	viewer.Message = "!goal set subathon 3"
+	bot.events <- viewer
+
+	mod := NewChatEvent()
+	mod.Sender = "ReallyFrank"
+	mod.Moderator = true
+	mod.Message = "!goal add subathon 1"
+	bot.events <- mod
+
+	expectMessage(t, checker, "Subathon: 1/4 subs (25%)")
+}
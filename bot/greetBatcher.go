@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for GreetingBatch fields left at 0
+const (
+	DefaultGreetingWindow        = 3 * time.Second
+	DefaultGreetingMaxPerMessage = 5
+)
+
+// GreetingBatch configures how greetings are collected before being sent
+type GreetingBatch struct {
+	Window        time.Duration // How long greetings are collected after the first one
+	MaxPerMessage int           // Most viewers greeted in one message
+}
+
+// pendingGreeting is a viewer waiting to be greeted
+type pendingGreeting struct {
+	evt      Event
+	template string // Greeter template name
+	custom   bool   // Greeted with their custom greeting, always on their own
+}
+
+// greetingBatcher collects greetings for a window, then sends them together. Adding a
+// greeting never blocks on the window, so the greeter handler keeps up with bursts of chatters
+type greetingBatcher struct {
+	mu            sync.Mutex
+	window        time.Duration
+	maxPerMessage int
+	pending       []pendingGreeting
+	timer         *time.Timer
+	send          func(batch []pendingGreeting, maxPerMessage int)
+}
+
+// newGreetingBatcher creates a greetingBatcher calling send with each batch
+func newGreetingBatcher(batch GreetingBatch, send func([]pendingGreeting, int)) *greetingBatcher {
+	if batch.Window <= 0 {
+		batch.Window = DefaultGreetingWindow
+	}
+
+	if batch.MaxPerMessage <= 0 {
+		batch.MaxPerMessage = DefaultGreetingMaxPerMessage
+	}
+
+	return &greetingBatcher{
+		window:        batch.Window,
+		maxPerMessage: batch.MaxPerMessage,
+		send:          send,
+	}
+}
+
+// add queues the greeting, starting the window if it is the first one pending
+func (b *greetingBatcher) add(greeting pendingGreeting) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = append(b.pending, greeting)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+}
+
+// flush sends the pending greetings
+func (b *greetingBatcher) flush() {
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = nil
+	b.timer = nil
+	b.mu.Unlock()
+
+	if len(batch) > 0 {
+		b.send(batch, b.maxPerMessage)
+	}
+}
+
+// groupGreetings splits the batch into messages: one per custom greeting, and for each
+// template, groups of up to maxPerMessage viewers in the order they chatted
+func groupGreetings(batch []pendingGreeting, maxPerMessage int) [][]pendingGreeting {
+	var groups [][]pendingGreeting
+	open := make(map[string]int) // template name to the index of its group being filled
+	for _, greeting := range batch {
+		if greeting.custom {
+			groups = append(groups, []pendingGreeting{greeting})
+			continue
+		}
+
+		idx, ok := open[greeting.template]
+		if !ok || len(groups[idx]) >= maxPerMessage {
+			idx = len(groups)
+			open[greeting.template] = idx
+			groups = append(groups, nil)
+		}
+		groups[idx] = append(groups[idx], greeting)
+	}
+
+	return groups
+}
+
+// mentions joins the names as mentions, ex: "@a", "@a and @b", "@a, @b and @c"
+func mentions(names []string) string {
+	mentioned := make([]string, 0, len(names))
+	for _, name := range names {
+		mentioned = append(mentioned, "@"+name)
+	}
+
+	if len(mentioned) <= 1 {
+		return strings.Join(mentioned, "")
+	}
+
+	return strings.Join(mentioned[:len(mentioned)-1], ", ") + " and " + mentioned[len(mentioned)-1]
+}
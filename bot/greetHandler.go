@@ -1,30 +1,320 @@
 package bot
 
 import (
+	"encoding/json"
 	"medgebot/cache"
 	log "medgebot/logger"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
-// RegisterGreeter creates and registers the greeter module with the Bot
-// Note: this is a different cache from the bot.metricsCache, so we still expect one as an arg
-func (bot *Bot) RegisterGreeter(cache cache.Cache, messageTemplate HandlerTemplate) {
+// dataStore key for the custom greetings
+const customGreetingsKey = "customGreetings"
+
+// Greetings are the message templates the greeter picks from
+type Greetings struct {
+	First        HandlerTemplate // Viewers never greeted before
+	Returning    HandlerTemplate // Viewers greeted before, once their greeting expired. Empty uses First
+	FirstMessage HandlerTemplate // Chatters sending their first ever message in the channel. Empty uses First
+}
+
+// Greeting is the data for greeter message templates. Templates using Senders or Mentions
+// greet every viewer greeted with the same template in one message, and the Event is the
+// first viewer's. Other templates greet each viewer in their own message
+type Greeting struct {
+	Event
+	Senders  []string // Each greeted viewer
+	Mentions string   // Each greeted viewer mentioned, ex: "@a, @b and @c"
+}
+
+// ParseGreeterTemplate parses a greeter message template, validated against a Greeting
+func ParseGreeterTemplate(name, format string) (HandlerTemplate, error) {
+	return parseTemplate(name, format, Greeting{})
+}
+
+// RegisterGreeter creates and registers the greeter module with the Bot. A viewer is greeted
+// once until their entry in the cache expires. Viewers in the greeted cache get the returning
+// greeting, so its expiration is how long a viewer counts as returning. Ignored usernames,
+// ex: other bots, are never greeted. Greetings are collected over the batch window and sent
+// together. Also handles the !greeting chat command
+// Note: these are different caches from the bot.metricsCache, so we still expect them as args
+func (bot *Bot) RegisterGreeter(cache cache.Cache, greeted cache.Cache, ignored []string, greetings Greetings, batch GreetingBatch) {
+	bot.registerFeature(FeatureGreeter)
+	bot.setTemplate(TemplateGreeter, greetings.First)
+	bot.setTemplate(TemplateGreeterReturning, greetings.Returning)
+	bot.setTemplate(TemplateGreeterFirstMessage, greetings.FirstMessage)
+	bot.loadCustomGreetings()
+	bot.greeted = greeted
+	bot.greeter = newGreetingBatcher(batch, bot.sendGreetings)
+
+	ignoredUsers := make(map[string]bool, len(ignored))
+	for _, username := range ignored {
+		ignoredUsers[strings.ToLower(strings.TrimPrefix(username, "@"))] = true
+	}
+
 	bot.RegisterHandler(
 		NewHandler(func(evt Event) {
+			if !bot.FeatureEnabled(FeatureGreeter) {
+				return
+			}
+
+			if evt.IsChatEvent() && (evt.Message == "!greeting" || strings.HasPrefix(evt.Message, "!greeting ")) {
+				bot.handleGreetingCommand(evt)
+				return
+			}
+
 			username := strings.ToLower(evt.Sender)
 			if strings.TrimSpace(username) == "" {
 				log.Info("Empty username for: %+v", evt)
 				return
 			}
 
+			if ignoredUsers[username] {
+				return
+			}
+
 			if cache.Absent(username) {
 				log.Info("Never seen %s before", username)
-				time.Sleep(3 * time.Second)
-
-				bot.SendMessage(messageTemplate.Parse(evt))
 				cache.Put(username, "")
+				bot.greeter.add(bot.pendingGreeting(evt))
 			}
-		}),
+		}).Named(FeatureGreeter),
 	)
 }
+
+// pendingGreeting picks how the Event's Sender is greeted, preferring their custom greeting.
+// Marks the Sender as greeted, so later greetings use the returning template
+func (bot *Bot) pendingGreeting(evt Event) pendingGreeting {
+	username := strings.ToLower(evt.Sender)
+
+	greeting := pendingGreeting{evt: evt, template: TemplateGreeter}
+	if _, ok := bot.customGreeting(username); ok {
+		greeting.custom = true
+	} else if evt.FirstMessage {
+		greeting.template = TemplateGreeterFirstMessage
+	} else if !bot.greeted.Absent(username) {
+		greeting.template = TemplateGreeterReturning
+	}
+
+	if err := bot.greeted.Put(username, time.Now().Format(time.RFC3339)); err != nil {
+		log.Error(err, "store greeted %s", username)
+	}
+
+	return greeting
+}
+
+// sendGreetings sends a message for each group of viewers greeted with the same template
+func (bot *Bot) sendGreetings(batch []pendingGreeting, maxPerMessage int) {
+	if !bot.FeatureEnabled(FeatureGreeter) {
+		return
+	}
+
+	for _, group := range groupGreetings(batch, maxPerMessage) {
+		first := group[0]
+		tmpl := bot.template(first.template)
+		if first.custom {
+			// Removed while the greeting waited, so the viewer gets the usual greeting
+			custom, ok := bot.customGreeting(strings.ToLower(first.evt.Sender))
+			if ok {
+				tmpl = custom
+			} else {
+				tmpl = bot.template(TemplateGreeter)
+			}
+		} else if tmpl.Format() == "" {
+			tmpl = bot.template(TemplateGreeter)
+		}
+
+		if greetsSeveral(tmpl) {
+			bot.sendGreeting(tmpl, group)
+			continue
+		}
+
+		for _, greeting := range group {
+			bot.sendGreeting(tmpl, []pendingGreeting{greeting})
+		}
+	}
+}
+
+// greetsSeveral checks if the greeter template mentions every viewer it is sent for,
+// through Senders or Mentions, so viewers can share one message
+func greetsSeveral(tmpl HandlerTemplate) bool {
+	format := tmpl.Format()
+	return strings.Contains(format, ".Senders") || strings.Contains(format, ".Mentions")
+}
+
+// sendGreeting sends one message greeting the viewers with the template
+func (bot *Bot) sendGreeting(tmpl HandlerTemplate, group []pendingGreeting) {
+	data := Greeting{Event: group[0].evt}
+	for _, greeting := range group {
+		data.Senders = append(data.Senders, greeting.evt.Sender)
+	}
+	data.Mentions = mentions(data.Senders)
+
+	if msg := tmpl.execute(data); msg != "" {
+		bot.SendMessage("%s", msg)
+	}
+}
+
+// loadCustomGreetings reads the persisted custom greetings. Any that no longer parse are dropped
+func (bot *Bot) loadCustomGreetings() {
+	formats := make(map[string]string)
+	if err := bot.loadJSON(customGreetingsKey, &formats); err != nil {
+		log.Error(err, "load custom greetings")
+	}
+
+	bot.greetingsMu.Lock()
+	defer bot.greetingsMu.Unlock()
+
+	bot.greetings = make(map[string]HandlerTemplate, len(formats))
+	for username, format := range formats {
+		tmpl, err := ParseGreeterTemplate(TemplateGreeter, format)
+		if err != nil {
+			log.Error(err, "invalid custom greeting for %s", username)
+			continue
+		}
+		bot.greetings[username] = tmpl
+	}
+}
+
+// customGreeting returns the custom greeting template for the lowercased username
+func (bot *Bot) customGreeting(username string) (HandlerTemplate, bool) {
+	bot.greetingsMu.Lock()
+	defer bot.greetingsMu.Unlock()
+
+	tmpl, ok := bot.greetings[username]
+	return tmpl, ok
+}
+
+// CustomGreetings returns the format of every custom greeting, by lowercased username
+func (bot *Bot) CustomGreetings() map[string]string {
+	bot.greetingsMu.Lock()
+	defer bot.greetingsMu.Unlock()
+
+	formats := make(map[string]string, len(bot.greetings))
+	for username, tmpl := range bot.greetings {
+		formats[username] = tmpl.Format()
+	}
+
+	return formats
+}
+
+// SetCustomGreeting greets the user with the given format, validated like other greetings,
+// instead of the greeter templates
+func (bot *Bot) SetCustomGreeting(username, format string) error {
+	username = strings.ToLower(strings.TrimPrefix(username, "@"))
+	if username == "" {
+		return errors.New("custom greeting needs a username")
+	}
+
+	tmpl, err := ParseGreeterTemplate(TemplateGreeter, format)
+	if err != nil {
+		return err
+	}
+
+	bot.greetingsMu.Lock()
+	bot.greetings[username] = tmpl
+	bot.greetingsMu.Unlock()
+
+	bot.persistCustomGreetings()
+	return nil
+}
+
+// RemoveCustomGreeting removes the user's custom greeting.
+// Returns false if there was no such greeting
+func (bot *Bot) RemoveCustomGreeting(username string) bool {
+	username = strings.ToLower(strings.TrimPrefix(username, "@"))
+
+	bot.greetingsMu.Lock()
+	_, ok := bot.greetings[username]
+	delete(bot.greetings, username)
+	bot.greetingsMu.Unlock()
+
+	if ok {
+		bot.persistCustomGreetings()
+	}
+
+	return ok
+}
+
+// persistCustomGreetings stores the custom greetings in the dataStore
+func (bot *Bot) persistCustomGreetings() {
+	formats, err := json.Marshal(bot.CustomGreetings())
+	if err != nil {
+		log.Error(err, "encode custom greetings")
+		return
+	}
+
+	if err := bot.dataStore.Put(customGreetingsKey, string(formats)); err != nil {
+		log.Error(err, "store custom greetings")
+	}
+}
+
+// handleGreetingCommand lets mods (and the broadcaster) manage custom greetings:
+//
+//	!greeting                  - list users with a custom greeting
+//	!greeting set USER MESSAGE - greet USER with MESSAGE, which may use the greeter template fields
+//	!greeting remove USER      - go back to the usual greeting for USER
+func (bot *Bot) handleGreetingCommand(evt Event) {
+	if !evt.Moderator {
+		return
+	}
+
+	tokens := strings.Fields(evt.Message)
+	if len(tokens) == 1 {
+		usernames := make([]string, 0)
+		for username := range bot.CustomGreetings() {
+			usernames = append(usernames, username)
+		}
+		sort.Strings(usernames)
+
+		if len(usernames) == 0 {
+			bot.SendMessage("No custom greetings")
+			return
+		}
+		bot.SendMessage("Custom greetings for: %s", strings.Join(usernames, ", "))
+		return
+	}
+
+	if len(tokens) < 3 {
+		return
+	}
+
+	action, username := tokens[1], strings.TrimPrefix(tokens[2], "@")
+	switch action {
+	case "set":
+		format := trimFields(evt.Message, 3)
+		if format == "" {
+			return
+		}
+
+		if err := bot.SetCustomGreeting(username, format); err != nil {
+			bot.SendMessage("@%s invalid greeting: %s", evt.Sender, err.Error())
+			return
+		}
+		bot.SendMessage("@%s custom greeting set for %s", evt.Sender, username)
+	case "remove":
+		if !bot.RemoveCustomGreeting(username) {
+			bot.SendMessage("@%s no custom greeting for %s", evt.Sender, username)
+			return
+		}
+		bot.SendMessage("@%s custom greeting removed for %s", evt.Sender, username)
+	}
+}
+
+// trimFields returns the message without its first n whitespace-separated fields,
+// keeping the spacing of the rest
+func trimFields(message string, n int) string {
+	rest := strings.TrimSpace(message)
+	for i := 0; i < n && rest != ""; i++ {
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			return ""
+		}
+		rest = strings.TrimSpace(rest[end:])
+	}
+
+	return rest
+}
@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"medgebot/cache"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newGreeterTestBot(store *cache.PersistableCache, window time.Duration) (*Bot, TestChatClient) {
+	greeted, _ := cache.InMemory(0)
+	return newGreeterTestBotGreeted(store, &greeted, window)
+}
+
+// newGreeterTestBotGreeted creates a greeter test Bot remembering greeted viewers in greeted
+func newGreeterTestBotGreeted(store, greeted *cache.PersistableCache, window time.Duration) (*Bot, TestChatClient) {
+	first, _ := ParseGreeterTemplate(TemplateGreeter, "Welcome, {{.Mentions}}!")
+	returning, _ := ParseGreeterTemplate(TemplateGreeterReturning, "Welcome back, @{{.Sender}}!")
+	firstMessage, _ := ParseGreeterTemplate(TemplateGreeterFirstMessage, "First time here, @{{.Sender}}?")
+
+	greeterCache, _ := cache.InMemory(0)
+	bot := New(store)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+	bot.RegisterGreeter(&greeterCache, greeted, []string{"Nightbot"}, Greetings{
+		First:        first,
+		Returning:    returning,
+		FirstMessage: firstMessage,
+	}, GreetingBatch{Window: window, MaxPerMessage: 2})
+
+	// This must happen after Handler registration, else data race occurs
+	bot.Start()
+	return &bot, checker
+}
+
+func sendChat(bot *Bot, sender, message string, firstMessage bool) {
+	evt := NewChatEvent()
+	evt.Sender = sender
+	evt.Message = message
+	evt.FirstMessage = firstMessage
+	bot.events <- evt
+}
+
+func TestGreeterTemplates(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	greeted, _ := cache.InMemory(0)
+	bot, checker := newGreeterTestBotGreeted(&store, &greeted, 10*time.Millisecond)
+
+	sendChat(bot, "nightbot", "Follow the stream!", false)
+	sendChat(bot, "saltymoth", "hello", false)
+	expectMessage(t, checker, "Welcome, @saltymoth!")
+
+	// Greeted once until the greeter cache entry expires, simulated by a new greeter cache
+	sendChat(bot, "saltymoth", "hello again", false)
+	sendChat(bot, "BlackMarvel", "hi chat", true)
+	expectMessage(t, checker, "First time here, @BlackMarvel?")
+
+	restarted, checker := newGreeterTestBotGreeted(&store, &greeted, 10*time.Millisecond)
+	sendChat(restarted, "SaltyMoth", "I'm back", false)
+	expectMessage(t, checker, "Welcome back, @SaltyMoth!")
+
+	if !store.Absent("greeted:saltymoth") || greeted.Absent("saltymoth") {
+		t.Fatalf("Expected greeted viewers in the greeted cache, not the dataStore")
+	}
+}
+
+func TestGreetedViewersExpire(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	greeted, _ := cache.InMemory(0)
+	bot, checker := newGreeterTestBotGreeted(&store, &greeted, 10*time.Millisecond)
+
+	sendChat(bot, "saltymoth", "hello", false)
+	expectMessage(t, checker, "Welcome, @saltymoth!")
+
+	// Forgotten once the greeted entry expires, simulated by a new greeted cache
+	forgotten, _ := cache.InMemory(0)
+	restarted, checker := newGreeterTestBotGreeted(&store, &forgotten, 10*time.Millisecond)
+	sendChat(restarted, "saltymoth", "hello again", false)
+	expectMessage(t, checker, "Welcome, @saltymoth!")
+}
+
+func TestCustomGreetings(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newGreeterTestBot(&store, 10*time.Millisecond)
+
+	mod := NewChatEvent()
+	mod.Sender = "ReallyFrank"
+	mod.Moderator = true
+	mod.Message = "!greeting set @SaltyMoth The moth has  landed, {{.Sender}}!"
+	bot.events <- mod
+	expectMessage(t, checker, "@ReallyFrank custom greeting set for SaltyMoth")
+
+	// Only mods set greetings
+	sendChat(bot, "nojoy", "!greeting set nojoy I'm a mod now", false)
+	if _, ok := bot.CustomGreetings()["nojoy"]; ok {
+		t.Fatalf("Expected viewers not to set custom greetings")
+	}
+
+	sendChat(bot, "saltymoth", "hello", false)
+	expectMessage(t, checker, "The moth has  landed, saltymoth!")
+
+	// Custom greetings are persisted
+	restarted, _ := newGreeterTestBot(&store, 10*time.Millisecond)
+	if greetings := restarted.CustomGreetings(); greetings["saltymoth"] != "The moth has  landed, {{.Sender}}!" {
+		t.Fatalf("Expected persisted custom greeting, got %+v", greetings)
+	}
+}
+
+func TestGreetingsAreBatched(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newGreeterTestBot(&store, time.Hour)
+
+	sendChat(bot, "saltymoth", "hello", false)
+	sendChat(bot, "BlackMarvel", "hi chat", true)
+	sendChat(bot, "nojoy", "hey", false)
+	sendChat(bot, "ReallyFrank", "o/", false)
+
+	// End the window early, once everyone is waiting to be greeted
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		bot.greeter.mu.Lock()
+		pending := len(bot.greeter.pending)
+		bot.greeter.mu.Unlock()
+
+		if pending == 4 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 4 pending greetings, got %d", pending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	bot.greeter.flush()
+
+	// Grouped by template, at most 2 per message
+	var messages []string
+	for len(messages) < 3 {
+		select {
+		case response := <-checker.events:
+			messages = append(messages, response.Message)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timed out waiting for greetings, got %v", messages)
+		}
+	}
+	sort.Strings(messages)
+
+	expected := []string{
+		"First time here, @BlackMarvel?",
+		"Welcome, @ReallyFrank!",
+		"Welcome, @saltymoth and @nojoy!",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Fatalf("Expected %v, got %v", expected, messages)
+	}
+}
+
+func TestGreetingsWithoutMentionsAreNotBatched(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newGreeterTestBot(&store, time.Hour)
+
+	// The first message template only mentions {{.Sender}}, so each viewer gets a message
+	sendChat(bot, "BlackMarvel", "hi chat", true)
+	sendChat(bot, "nojoy", "hey", true)
+	waitForPendingGreetings(t, bot, 2)
+	bot.greeter.flush()
+
+	expectMessages(t, checker, "First time here, @BlackMarvel?", "First time here, @nojoy?")
+}
+
+func TestRemovedCustomGreetingFallsBack(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newGreeterTestBot(&store, time.Hour)
+
+	if err := bot.SetCustomGreeting("saltymoth", "The moth has landed!"); err != nil {
+		t.Fatalf("Failed to set custom greeting: %v", err)
+	}
+
+	sendChat(bot, "saltymoth", "hello", false)
+	waitForPendingGreetings(t, bot, 1)
+
+	// Removed while the greeting waited for the window to end
+	bot.RemoveCustomGreeting("saltymoth")
+	bot.greeter.flush()
+
+	expectMessage(t, checker, "Welcome, @saltymoth!")
+}
+
+// waitForPendingGreetings waits until the given number of viewers wait to be greeted
+func waitForPendingGreetings(t *testing.T, bot *Bot, count int) {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		bot.greeter.mu.Lock()
+		pending := len(bot.greeter.pending)
+		bot.greeter.mu.Unlock()
+
+		if pending == count {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d pending greetings, got %d", count, pending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		names    []string
+		expected string
+	}{
+		{[]string{"a"}, "@a"},
+		{[]string{"a", "b"}, "@a and @b"},
+		{[]string{"a", "b", "c"}, "@a, @b and @c"},
+	}
+
+	for _, test := range tests {
+		if joined := mentions(test.names); joined != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, joined)
+		}
+	}
+}
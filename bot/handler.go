@@ -1,23 +1,78 @@
 package bot
 
+import (
+	"medgebot/telemetry"
+	"sync"
+	"time"
+)
+
+var (
+	handlerQueueDepth = telemetry.NewGauge("medgebot_handler_queue_depth",
+		"Events waiting to be processed by each handler", "handler")
+	handlerDuration = telemetry.NewHistogram("medgebot_handler_duration_seconds",
+		"Time each handler takes to process an event", "handler")
+)
+
 type Handler struct {
+	name     string
 	consumer func(Event)
 	msgChan  chan Event
+
+	// Shared by copies of the Handler, as the Bot keeps its own copy
+	busy *handlerBusy
+}
+
+// handlerBusy tracks when the Event being processed was started. Zero when idle
+type handlerBusy struct {
+	sync.Mutex
+	since time.Time
 }
 
 func NewHandler(consumer func(Event)) Handler {
 	return Handler{
 		consumer: consumer,
 		msgChan:  make(chan Event, 10),
+		busy:     &handlerBusy{},
 	}
 }
 
+// Named sets the name the Handler is reported under in telemetry
+func (h Handler) Named(name string) Handler {
+	h.name = name
+	return h
+}
+
 func (h Handler) Listen() {
 	for msg := range h.msgChan {
+		handlerQueueDepth.Set(float64(len(h.msgChan)), h.name)
+
+		start := time.Now()
+		h.setBusySince(start)
 		h.consumer(msg)
+		h.setBusySince(time.Time{})
+		handlerDuration.ObserveSince(start, h.name)
 	}
 }
 
+// BusyFor returns how long the Handler has been processing the current Event. 0 if idle
+func (h Handler) BusyFor() time.Duration {
+	h.busy.Lock()
+	defer h.busy.Unlock()
+
+	if h.busy.since.IsZero() {
+		return 0
+	}
+
+	return time.Since(h.busy.since)
+}
+
+func (h Handler) setBusySince(since time.Time) {
+	h.busy.Lock()
+	h.busy.since = since
+	h.busy.Unlock()
+}
+
 func (h Handler) Receive(msg Event) {
 	h.msgChan <- msg
+	handlerQueueDepth.Set(float64(len(h.msgChan)), h.name)
 }
@@ -1,14 +1,21 @@
 package bot
 
 import (
+	"io"
 	log "medgebot/logger"
 	"strings"
 	"text/template"
+
+	"github.com/pkg/errors"
 )
 
 // HandlerTemplate wraps text/template for convenience
 type HandlerTemplate struct {
 	template *template.Template
+	format   string
+
+	// sample is the data the template was validated against. Nil means an Event
+	sample interface{}
 }
 
 // NewHandlerTemplate creates a new instance. Intent is to call Parse() soon after
@@ -18,10 +25,58 @@ func NewHandlerTemplate(t *template.Template) HandlerTemplate {
 	}
 }
 
+// ParseHandlerTemplate parses the given format and validates it against an Event,
+// so unknown fields are caught before the template is used by a handler
+func ParseHandlerTemplate(name, format string) (HandlerTemplate, error) {
+	return parseTemplate(name, format, nil)
+}
+
+// parseTemplate parses the given format and validates it against the sample data.
+// A nil sample validates against an Event
+func parseTemplate(name, format string, sample interface{}) (HandlerTemplate, error) {
+	tmpl, err := template.New(name).Parse(format)
+	if err != nil {
+		return HandlerTemplate{}, errors.Wrapf(err, "parse %s template", name)
+	}
+
+	var data interface{} = Event{}
+	if sample != nil {
+		data = sample
+	}
+
+	if err := tmpl.Execute(io.Discard, data); err != nil {
+		return HandlerTemplate{}, errors.Wrapf(err, "validate %s template", name)
+	}
+
+	return HandlerTemplate{
+		template: tmpl,
+		format:   format,
+		sample:   sample,
+	}, nil
+}
+
+// Format returns the text the template was parsed from
+func (h HandlerTemplate) Format() string {
+	if h.format == "" && h.template != nil && h.template.Tree != nil {
+		return h.template.Tree.Root.String()
+	}
+
+	return h.format
+}
+
 // Parse interpolates the given Event onto the stored template
 func (h HandlerTemplate) Parse(evt Event) string {
+	return h.execute(evt)
+}
+
+// execute interpolates the given data onto the stored template
+func (h HandlerTemplate) execute(data interface{}) string {
+	if h.template == nil {
+		return "" // No template configured. Bot will not send empty messages
+	}
+
 	var msg strings.Builder
-	err := h.template.Execute(&msg, evt)
+	err := h.template.Execute(&msg, data)
 	if err != nil {
 		log.Error(err, "template execute")
 		return "" // We assume bot will not send empty messages
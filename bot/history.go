@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"encoding/json"
+	log "medgebot/logger"
+	"strings"
+	"time"
+)
+
+// historyKey is the dataStore key the event history is persisted at
+const historyKey = "eventHistory"
+
+// DefaultHistorySize is the number of Events kept when no size is configured
+const DefaultHistorySize = 1000
+
+// historyPersistDelay is how long after an Event the history is written to the dataStore.
+// Events received meanwhile are written with it, so a burst is written once
+var historyPersistDelay = 2 * time.Second
+
+// HistoryEntry is an Event kept in the event history
+type HistoryEntry struct {
+	ID    int       // Increases with each Event. Used as the pagination cursor
+	Time  time.Time // When the Bot received the Event
+	Event Event
+}
+
+// HistoryQuery filters the event history. Zero values match everything
+type HistoryQuery struct {
+	Type   string    // Event type name, as used in config.yaml
+	User   string    // Sender or Recipient, case-insensitive
+	Since  time.Time // Only Events received at or after this time
+	Before int       // Only entries with a lower ID, for the next page
+	Limit  int       // Maximum entries returned
+}
+
+// matches checks if the entry passes every filter of the query
+func (q HistoryQuery) matches(entry HistoryEntry) bool {
+	if q.Type != "" && entry.Event.TypeName() != q.Type {
+		return false
+	}
+
+	if q.User != "" &&
+		!strings.EqualFold(entry.Event.Sender, q.User) &&
+		!strings.EqualFold(entry.Event.Recipient, q.User) {
+		return false
+	}
+
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+
+	if q.Before > 0 && entry.ID >= q.Before {
+		return false
+	}
+
+	return true
+}
+
+// RegisterEventHistory keeps the last size non-chat Events, persisted in the dataStore
+func (bot *Bot) RegisterEventHistory(size int) {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+
+	bot.historyMu.Lock()
+	bot.historySize = size
+	bot.history = bot.loadHistory()
+	bot.historyMu.Unlock()
+
+	bot.RegisterHandler(
+		NewHandler(func(evt Event) {
+			if evt.IsChatEvent() {
+				return
+			}
+
+			bot.recordEvent(evt, time.Now())
+		}).Named("history"),
+	)
+}
+
+// loadHistory reads the persisted event history, oldest first
+func (bot *Bot) loadHistory() []HistoryEntry {
+	stored, err := bot.dataStore.GetOrDefault(historyKey, "")
+	if err != nil {
+		log.Error(err, "fetch event history from bot.dataStore")
+		return nil
+	}
+
+	if stored == "" {
+		return nil
+	}
+
+	var history []HistoryEntry
+	if err := json.Unmarshal([]byte(stored), &history); err != nil {
+		log.Error(err, "parse stored event history")
+		return nil
+	}
+
+	return history
+}
+
+// recordEvent adds the Event to the history, dropping the oldest entries over the size
+func (bot *Bot) recordEvent(evt Event, received time.Time) {
+	bot.historyMu.Lock()
+	nextID := 1
+	if len(bot.history) > 0 {
+		nextID = bot.history[len(bot.history)-1].ID + 1
+	}
+
+	bot.history = append(bot.history, HistoryEntry{
+		ID:    nextID,
+		Time:  received,
+		Event: evt,
+	})
+	if len(bot.history) > bot.historySize {
+		bot.history = bot.history[len(bot.history)-bot.historySize:]
+	}
+
+	if bot.historyTimer == nil {
+		bot.historyTimer = time.AfterFunc(historyPersistDelay, bot.persistHistory)
+	}
+	bot.historyMu.Unlock()
+}
+
+// persistHistory writes the whole event history to the dataStore
+func (bot *Bot) persistHistory() {
+	bot.historyMu.Lock()
+	if bot.historyTimer != nil {
+		bot.historyTimer.Stop()
+		bot.historyTimer = nil
+	}
+	stored, err := json.Marshal(bot.history)
+	bot.historyMu.Unlock()
+
+	if err != nil {
+		log.Error(err, "encode event history")
+		return
+	}
+
+	if err := bot.dataStore.Put(historyKey, string(stored)); err != nil {
+		log.Error(err, "store event history")
+	}
+}
+
+// EventHistory returns the entries matching the query, newest first. more is true
+// if older matching entries were left out by the Limit
+func (bot *Bot) EventHistory(query HistoryQuery) (entries []HistoryEntry, more bool) {
+	bot.historyMu.Lock()
+	defer bot.historyMu.Unlock()
+
+	entries = make([]HistoryEntry, 0)
+	for idx := len(bot.history) - 1; idx >= 0; idx-- {
+		entry := bot.history[idx]
+		if !query.matches(entry) {
+			continue
+		}
+
+		if query.Limit > 0 && len(entries) == query.Limit {
+			return entries, true
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, false
+}
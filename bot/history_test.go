@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"medgebot/cache"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingCache counts the Puts to each key
+type countingCache struct {
+	cache.Cache
+
+	mu   sync.Mutex
+	puts map[string]int
+}
+
+func (c *countingCache) Put(key, value string) error {
+	c.mu.Lock()
+	c.puts[key]++
+	c.mu.Unlock()
+
+	return c.Cache.Put(key, value)
+}
+
+func (c *countingCache) putCount(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.puts[key]
+}
+
+func TestEventHistoryFiltersAndPages(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot := New(&store)
+	bot.RegisterEventHistory(3)
+
+	start := time.Date(2021, 6, 1, 20, 0, 0, 0, time.UTC)
+	senders := []string{"saltymoth", "Przemko9856", "saltymoth", "BlackMarvel"}
+	for idx, sender := range senders {
+		evt := NewSubEvent()
+		evt.Sender = sender
+		bot.recordEvent(evt, start.Add(time.Duration(idx)*time.Minute))
+	}
+
+	// Oldest Event dropped past the size
+	all, more := bot.EventHistory(HistoryQuery{})
+	if len(all) != 3 || more {
+		t.Fatalf("Expected 3 entries, got %d (more: %v)", len(all), more)
+	}
+	if all[0].Event.Sender != "BlackMarvel" || all[2].Event.Sender != "Przemko9856" {
+		t.Fatalf("Expected newest first, got %+v", all)
+	}
+
+	users, _ := bot.EventHistory(HistoryQuery{User: "SALTYMOTH"})
+	if len(users) != 1 {
+		t.Fatalf("Expected 1 entry for saltymoth, got %d", len(users))
+	}
+
+	since, _ := bot.EventHistory(HistoryQuery{Since: start.Add(3 * time.Minute)})
+	if len(since) != 1 || since[0].Event.Sender != "BlackMarvel" {
+		t.Fatalf("Expected only the last entry since, got %+v", since)
+	}
+
+	bits, _ := bot.EventHistory(HistoryQuery{Type: "bits"})
+	if len(bits) != 0 {
+		t.Fatalf("Expected no bits entries, got %d", len(bits))
+	}
+
+	page, more := bot.EventHistory(HistoryQuery{Limit: 2})
+	if len(page) != 2 || !more {
+		t.Fatalf("Expected first page of 2 with more, got %d (more: %v)", len(page), more)
+	}
+
+	next, more := bot.EventHistory(HistoryQuery{Limit: 2, Before: page[1].ID})
+	if len(next) != 1 || more || next[0].Event.Sender != "Przemko9856" {
+		t.Fatalf("Expected last page with the oldest entry, got %+v (more: %v)", next, more)
+	}
+
+	// A restarted Bot picks up the stored history
+	bot.persistHistory()
+	restarted := New(&store)
+	restarted.RegisterEventHistory(3)
+	stored, _ := restarted.EventHistory(HistoryQuery{})
+	if len(stored) != 3 || stored[0].ID != all[0].ID {
+		t.Fatalf("Expected stored history, got %+v", stored)
+	}
+}
+
+func TestEventHistoryPersistedOncePerBurst(t *testing.T) {
+	defer func(delay time.Duration) { historyPersistDelay = delay }(historyPersistDelay)
+	historyPersistDelay = 200 * time.Millisecond
+
+	inMemory, _ := cache.InMemory(0)
+	store := &countingCache{Cache: &inMemory, puts: make(map[string]int)}
+	bot := New(store)
+	bot.RegisterEventHistory(0)
+
+	// A gift sub bomb
+	events := 50
+	for idx := 0; idx < events; idx++ {
+		evt := NewGiftSubEvent()
+		evt.Sender = "saltymoth"
+		bot.recordEvent(evt, time.Now())
+	}
+
+	restarted := New(store.Cache)
+	deadline := time.Now().Add(3 * time.Second)
+	for len(restarted.loadHistory()) != events {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d stored entries, got %d", events, len(restarted.loadHistory()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if puts := store.putCount(historyKey); puts != 1 {
+		t.Fatalf("Expected the burst to be stored once, got %d writes", puts)
+	}
+}
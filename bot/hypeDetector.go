@@ -0,0 +1,200 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for HypeOptions fields left at 0
+const (
+	DefaultHypeWindow    = 5 * time.Minute
+	DefaultHypeSubPoints = 500
+)
+
+// DefaultHypeLevels are the points needed for each hype level when none are configured
+var DefaultHypeLevels = []int{2500, 5000, 10000}
+
+// HypeOptions configure the hype detector. Support is counted in points: 1 per bit, and
+// SubPoints per tier 1 sub or gift sub, twice that for tier 2 and five times for tier 3
+type HypeOptions struct {
+	Window    time.Duration // Support older than this no longer counts
+	Levels    []int         // Points in the window needed for each level, level 1 first
+	SubPoints int           // Points for a tier 1 sub
+
+	// now is the detector's clock, time.Now when nil. Tests replace it
+	now func() time.Time
+}
+
+// hypeContribution is one supporting Event counted by the hype detector
+type hypeContribution struct {
+	sender string
+	points int
+	time   time.Time
+}
+
+// hypeDetector watches subs, gift subs and bits over a sliding window, producing a hype Event
+// each time the support in the window reaches a new level. Once the window drops below the
+// first level, the burst is over and levels start from 1 again
+type hypeDetector struct {
+	mu            sync.Mutex
+	options       HypeOptions
+	contributions []hypeContribution
+	level         int
+}
+
+// newHypeDetector creates a hypeDetector, filling in defaults for options left at 0
+func newHypeDetector(options HypeOptions) *hypeDetector {
+	if options.Window <= 0 {
+		options.Window = DefaultHypeWindow
+	}
+
+	if len(options.Levels) == 0 {
+		options.Levels = DefaultHypeLevels
+	}
+	options.Levels = append([]int{}, options.Levels...)
+	sort.Ints(options.Levels)
+
+	if options.SubPoints <= 0 {
+		options.SubPoints = DefaultHypeSubPoints
+	}
+
+	if options.now == nil {
+		options.now = time.Now
+	}
+
+	return &hypeDetector{options: options}
+}
+
+// points returns what the Event counts for, 0 if it isn't support counted for hype.
+// Mystery gifts are counted through the gift subs that follow them
+func (d *hypeDetector) points(evt Event) int {
+	switch {
+	case evt.IsBitsEvent():
+		return evt.Amount
+	case evt.IsSubEvent(), evt.IsGiftSubEvent():
+		switch evt.Tier {
+		case 2:
+			return 2 * d.options.SubPoints
+		case 3:
+			return 5 * d.options.SubPoints
+		default:
+			return d.options.SubPoints
+		}
+	default:
+		return 0
+	}
+}
+
+// observe counts the Event, returning a hype Event if the window reached a new level
+func (d *hypeDetector) observe(evt Event) (Event, bool) {
+	points := d.points(evt)
+	if points <= 0 {
+		return Event{}, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.options.now()
+	d.prune(now)
+	d.contributions = append(d.contributions, hypeContribution{
+		sender: evt.Sender,
+		points: points,
+		time:   now,
+	})
+
+	level := d.currentLevel()
+	if level <= d.level {
+		return Event{}, false
+	}
+	d.level = level
+
+	hype := NewHypeEvent()
+	hype.Amount = level
+	hype.Contributors = d.contributors()
+	return hype, true
+}
+
+// prune drops contributions older than the window, ending the burst once the rest no
+// longer reach the first level
+func (d *hypeDetector) prune(now time.Time) {
+	kept := d.contributions[:0]
+	for _, contribution := range d.contributions {
+		if now.Sub(contribution.time) < d.options.Window {
+			kept = append(kept, contribution)
+		}
+	}
+	d.contributions = kept
+
+	if d.currentLevel() == 0 {
+		d.level = 0
+	}
+}
+
+// currentLevel returns the highest level the points in the window reach, 0 for none
+func (d *hypeDetector) currentLevel() int {
+	total := 0
+	for _, contribution := range d.contributions {
+		total += contribution.points
+	}
+
+	level := 0
+	for idx, needed := range d.options.Levels {
+		if total >= needed {
+			level = idx + 1
+		}
+	}
+
+	return level
+}
+
+// contributors returns each sender in the window, most points first. Ties keep the
+// order senders first contributed in
+func (d *hypeDetector) contributors() []string {
+	totals := make(map[string]int)
+	names := make([]string, 0)
+	for _, contribution := range d.contributions {
+		key := strings.ToLower(contribution.sender)
+		if _, seen := totals[key]; !seen {
+			names = append(names, contribution.sender)
+		}
+		totals[key] += contribution.points
+	}
+
+	sort.SliceStable(names, func(i, j int) bool {
+		return totals[strings.ToLower(names[i])] > totals[strings.ToLower(names[j])]
+	})
+
+	return names
+}
+
+// RegisterHypeDetector watches subs, gift subs and bits for bursts of support. Each time a
+// burst reaches a new level, a hype Event is sent through the Bot, so chat messages, alerts and
+// overlays can react to it. The hype template is sent to Chat for each hype Event
+func (bot *Bot) RegisterHypeDetector(messageTemplate HandlerTemplate, options HypeOptions) {
+	bot.registerFeature(FeatureHype)
+	bot.setTemplate(TemplateHype, messageTemplate)
+	detector := newHypeDetector(options)
+
+	bot.RegisterHandler(
+		NewHandler(func(evt Event) {
+			if !bot.FeatureEnabled(FeatureHype) {
+				return
+			}
+
+			if evt.IsHypeEvent() {
+				if msg := bot.template(TemplateHype).Parse(evt); msg != "" {
+					bot.SendMessage("%s", msg)
+				}
+				return
+			}
+
+			if hype, ok := detector.observe(evt); ok {
+				// Not waiting on the listen loop, which may be waiting on this handler
+				go bot.ReceiveEvent(hype)
+			}
+		}).Named(FeatureHype),
+	)
+}
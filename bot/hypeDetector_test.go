@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"medgebot/cache"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClock is a HypeOptions clock moved forward by the test
+type fakeClock struct {
+	current time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.current
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.current = c.current.Add(d)
+}
+
+func newTestHypeDetector(clock *fakeClock) *hypeDetector {
+	return newHypeDetector(HypeOptions{
+		Window:    time.Minute,
+		Levels:    []int{1000, 500},
+		SubPoints: 100,
+		now:       clock.now,
+	})
+}
+
+func bitsEvent(sender string, amount int) Event {
+	evt := NewBitsEvent()
+	evt.Sender = sender
+	evt.Amount = amount
+	return evt
+}
+
+func TestHypeDetectorLevels(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2021, 6, 1, 20, 0, 0, 0, time.UTC)}
+	detector := newTestHypeDetector(clock)
+
+	if _, ok := detector.observe(bitsEvent("a", 400)); ok {
+		t.Fatalf("Expected no hype below the first level")
+	}
+
+	// Chat and mystery gifts are not counted
+	if _, ok := detector.observe(NewChatEvent()); ok {
+		t.Fatalf("Expected chat not to count for hype")
+	}
+	mystery := NewMysteryGiftEvent()
+	mystery.Sender = "b"
+	mystery.Amount = 50
+	if _, ok := detector.observe(mystery); ok {
+		t.Fatalf("Expected mystery gifts not to count for hype")
+	}
+
+	clock.advance(10 * time.Second)
+	sub := NewSubEvent()
+	sub.Sender = "b"
+	sub.Tier = 1
+	hype, ok := detector.observe(sub)
+	if !ok || hype.Amount != 1 {
+		t.Fatalf("Expected level 1 hype at 500 points, got %+v", hype)
+	}
+	if !reflect.DeepEqual(hype.Contributors, []string{"a", "b"}) {
+		t.Fatalf("Expected contributors a and b, got %v", hype.Contributors)
+	}
+
+	clock.advance(10 * time.Second)
+	if _, ok := detector.observe(bitsEvent("c", 100)); ok {
+		t.Fatalf("Expected no hype for the same level again")
+	}
+
+	clock.advance(10 * time.Second)
+	gift := NewGiftSubEvent()
+	gift.Sender = "c"
+	gift.Recipient = "d"
+	gift.Tier = 3
+	hype, ok = detector.observe(gift)
+	if !ok || hype.Amount != 2 {
+		t.Fatalf("Expected level 2 hype at 1100 points, got %+v", hype)
+	}
+	if !reflect.DeepEqual(hype.Contributors, []string{"c", "a", "b"}) {
+		t.Fatalf("Expected contributors by points, got %v", hype.Contributors)
+	}
+
+	// The highest level was reached, so nothing more until the burst ends
+	if _, ok := detector.observe(bitsEvent("a", 5000)); ok {
+		t.Fatalf("Expected no hype past the highest level")
+	}
+}
+
+func TestHypeDetectorWindow(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2021, 6, 1, 20, 0, 0, 0, time.UTC)}
+	detector := newTestHypeDetector(clock)
+
+	detector.observe(bitsEvent("a", 300))
+	clock.advance(time.Minute)
+
+	// The first cheer left the window, so this one alone isn't enough
+	if _, ok := detector.observe(bitsEvent("b", 300)); ok {
+		t.Fatalf("Expected support older than the window not to count")
+	}
+
+	hype, ok := detector.observe(bitsEvent("c", 300))
+	if !ok || hype.Amount != 1 || !reflect.DeepEqual(hype.Contributors, []string{"b", "c"}) {
+		t.Fatalf("Expected level 1 hype from b and c, got %+v", hype)
+	}
+
+	// Once the burst is over, the next one starts from level 1 again
+	clock.advance(2 * time.Minute)
+	hype, ok = detector.observe(bitsEvent("d", 600))
+	if !ok || hype.Amount != 1 {
+		t.Fatalf("Expected a new burst to start from level 1, got %+v", hype)
+	}
+}
+
+func TestHypeHandler(t *testing.T) {
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+
+	tmpl, err := ParseHandlerTemplate(TemplateHype, "Hype level {{.Amount}}! Thanks {{range $i, $c := .Contributors}}{{if $i}}, {{end}}{{$c}}{{end}}")
+	if err != nil {
+		t.Fatalf("Invalid hype template: %v", err)
+	}
+	bot.RegisterHypeDetector(tmpl, HypeOptions{Window: time.Hour, Levels: []int{500}})
+	bot.Start()
+
+	bot.ReceiveEvent(bitsEvent("a", 200))
+	bot.ReceiveEvent(bitsEvent("b", 300))
+
+	expectMessage(t, checker, "Hype level 1! Thanks b, a")
+}
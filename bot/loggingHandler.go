@@ -14,6 +14,6 @@ func (bot *Bot) RegisterReadLogger() {
 			}
 
 			log.Info("%+v", evt)
-		}),
+		}).Named("readLogger"),
 	)
 }
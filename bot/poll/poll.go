@@ -0,0 +1,305 @@
+package poll
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Mode decides how many answers a ballot picks and how they are counted
+type Mode string
+
+const (
+	// Single ballots pick one answer
+	Single Mode = "single"
+
+	// Multi ballots pick any number of answers, each counting one vote
+	Multi Mode = "multi"
+
+	// Ranked ballots order answers by preference, counted by instant runoff
+	Ranked Mode = "ranked"
+)
+
+// Vote errors, for telling the voter why a ballot was rejected
+var (
+	ErrClosed        = errors.New("poll is closed")
+	ErrAlreadyVoted  = errors.New("already voted")
+	ErrInvalidChoice = errors.New("not an answer")
+	ErrOneChoice     = errors.New("pick only one answer")
+)
+
+// ParseMode returns the Mode with the given name. Empty is Single
+func ParseMode(name string) (Mode, error) {
+	switch Mode(strings.ToLower(name)) {
+	case "", Single:
+		return Single, nil
+	case Multi:
+		return Multi, nil
+	case Ranked:
+		return Ranked, nil
+	default:
+		return "", errors.Errorf("unknown poll mode %q. Must be %s, %s or %s", name, Single, Multi, Ranked)
+	}
+}
+
+// Options change how a Poll is voted on
+type Options struct {
+	Mode        Mode `json:"mode"`
+	AllowChange bool `json:"allowChange"` // Voters may replace their ballot while the Poll runs
+}
+
+// Ballot is one voter's choices
+type Ballot struct {
+	Voter   string    `json:"voter"`
+	Choices []int     `json:"choices"` // Answer indexes, most preferred first for Ranked
+	Time    time.Time `json:"time"`
+}
+
+// Poll is a question Chat votes on by typing the numbers of answers. It keeps one Ballot
+// per voter. Not safe for concurrent use
+type Poll struct {
+	ID       int       `json:"id"`
+	Question string    `json:"question"`
+	Answers  []string  `json:"answers"`
+	Options  Options   `json:"options"`
+	Started  time.Time `json:"started"`
+	Ends     time.Time `json:"ends"`
+	Ended    time.Time `json:"ended"` // Zero while running
+	Canceled bool      `json:"canceled"`
+
+	// Keyed by lowercased voter, as display names can change case
+	Ballots map[string]Ballot `json:"ballots"`
+}
+
+// New creates a running Poll, ending after the duration
+func New(id int, question string, answers []string, options Options, started time.Time, duration time.Duration) (*Poll, error) {
+	if strings.TrimSpace(question) == "" {
+		return nil, errors.New("poll question must be set")
+	}
+
+	if len(answers) == 0 {
+		return nil, errors.New("poll needs at least 1 answer")
+	}
+
+	if duration <= 0 {
+		return nil, errors.New("poll duration must be above 0")
+	}
+
+	mode, err := ParseMode(string(options.Mode))
+	if err != nil {
+		return nil, err
+	}
+	options.Mode = mode
+
+	return &Poll{
+		ID:       id,
+		Question: question,
+		Answers:  append([]string{}, answers...),
+		Options:  options,
+		Started:  started,
+		Ends:     started.Add(duration),
+		Ballots:  make(map[string]Ballot),
+	}, nil
+}
+
+// Running checks if the Poll is still taking votes
+func (p *Poll) Running() bool {
+	return p.Ended.IsZero()
+}
+
+// ParseChoices reads the answer numbers in a chat message, ex: "2", "1 3" or "3,1,2".
+// ok is false if the message is anything other than numbers, so isn't a vote
+func ParseChoices(message string) (numbers []int, ok bool) {
+	fields := strings.FieldsFunc(message, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t'
+	})
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	for _, field := range fields {
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers, true
+}
+
+// Vote records the voter's ballot. numbers are answer numbers as shown in chat,
+// starting at 1. Repeated numbers are ignored. Replaces an earlier ballot if the
+// Poll allows changes, else returns ErrAlreadyVoted. changed is true if it did
+func (p *Poll) Vote(voter string, numbers []int, at time.Time) (changed bool, err error) {
+	if !p.Running() {
+		return false, ErrClosed
+	}
+
+	choices := make([]int, 0, len(numbers))
+	seen := make(map[int]bool)
+	for _, number := range numbers {
+		if number < 1 || number > len(p.Answers) {
+			return false, ErrInvalidChoice
+		}
+
+		if !seen[number] {
+			seen[number] = true
+			choices = append(choices, number-1)
+		}
+	}
+
+	if len(choices) == 0 {
+		return false, ErrInvalidChoice
+	}
+
+	if p.Options.Mode == Single && len(choices) > 1 {
+		return false, ErrOneChoice
+	}
+
+	key := strings.ToLower(voter)
+	_, voted := p.Ballots[key]
+	if voted && !p.Options.AllowChange {
+		return false, ErrAlreadyVoted
+	}
+
+	p.Ballots[key] = Ballot{
+		Voter:   voter,
+		Choices: choices,
+		Time:    at,
+	}
+
+	return voted, nil
+}
+
+// Copy returns a deep copy of the Poll, safe to read while the original takes votes
+func (p *Poll) Copy() Poll {
+	copied := *p
+	copied.Answers = append([]string{}, p.Answers...)
+	copied.Ballots = make(map[string]Ballot, len(p.Ballots))
+	for key, ballot := range p.Ballots {
+		ballot.Choices = append([]int{}, ballot.Choices...)
+		copied.Ballots[key] = ballot
+	}
+
+	return copied
+}
+
+// Results is the outcome of a Poll
+type Results struct {
+	// Votes per answer, by answer index. For Ranked, the counts of the final round
+	Counts []int `json:"counts"`
+
+	// Ranked only: the counts of each instant runoff round
+	Rounds [][]int `json:"rounds,omitempty"`
+
+	// Winning answer indexes, in answer order. More than one if tied, empty if no votes
+	Winners []int `json:"winners"`
+
+	// Number of ballots
+	Voters int `json:"voters"`
+}
+
+// Results counts the ballots. Ties are never broken at random: all tied answers are
+// winners, in answer order. For Ranked, see runoff for how eliminations are decided
+func (p *Poll) Results() Results {
+	results := Results{
+		Counts:  make([]int, len(p.Answers)),
+		Winners: make([]int, 0),
+		Voters:  len(p.Ballots),
+	}
+
+	if p.Options.Mode == Ranked {
+		results.Rounds = p.runoff()
+		results.Counts = results.Rounds[len(results.Rounds)-1]
+	} else {
+		for _, ballot := range p.Ballots {
+			for _, choice := range ballot.Choices {
+				results.Counts[choice]++
+			}
+		}
+	}
+
+	highest := 0
+	for _, count := range results.Counts {
+		if count > highest {
+			highest = count
+		}
+	}
+
+	if highest == 0 {
+		return results
+	}
+
+	for idx, count := range results.Counts {
+		if count == highest {
+			results.Winners = append(results.Winners, idx)
+		}
+	}
+
+	return results
+}
+
+// runoff counts Ranked ballots by instant runoff, returning the counts of each round.
+// Each round, ballots count for their most preferred answer still in the running. Counting
+// stops once an answer has a majority, or every remaining answer is tied. Otherwise the
+// answer with the fewest votes is eliminated. Ties for fewest eliminate the answer with
+// fewer first choice votes, then the one listed last
+func (p *Poll) runoff() [][]int {
+	eliminated := make([]bool, len(p.Answers))
+	remaining := len(p.Answers)
+
+	var rounds [][]int
+	for {
+		counts := make([]int, len(p.Answers))
+		active := 0
+		for _, ballot := range p.Ballots {
+			for _, choice := range ballot.Choices {
+				if !eliminated[choice] {
+					counts[choice]++
+					active++
+					break
+				}
+			}
+		}
+		rounds = append(rounds, counts)
+
+		if active == 0 || remaining == 1 {
+			return rounds
+		}
+
+		// Ordered for elimination: fewest votes, then fewest first choice votes, then listed last
+		candidates := make([]int, 0, remaining)
+		for idx := range p.Answers {
+			if !eliminated[idx] {
+				candidates = append(candidates, idx)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if counts[a] != counts[b] {
+				return counts[a] < counts[b]
+			}
+			if rounds[0][a] != rounds[0][b] {
+				return rounds[0][a] < rounds[0][b]
+			}
+			return a > b
+		})
+
+		leader := candidates[len(candidates)-1]
+		if counts[leader]*2 > active {
+			return rounds
+		}
+
+		if counts[candidates[0]] == counts[leader] {
+			// Every remaining answer is tied
+			return rounds
+		}
+
+		eliminated[candidates[0]] = true
+		remaining--
+	}
+}
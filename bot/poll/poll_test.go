@@ -0,0 +1,165 @@
+package poll
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestPoll(t *testing.T, options Options, answers ...string) *Poll {
+	p, err := New(1, "Best snack?", answers, options, time.Now(), time.Minute)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	return p
+}
+
+func TestVoterNamesAreNotPrefixes(t *testing.T) {
+	p := newTestPoll(t, Options{}, "Chips", "Cookies")
+
+	if _, err := p.Vote("bobby", []int{1}, time.Now()); err != nil {
+		t.Fatalf("bobby vote failed: %v", err)
+	}
+
+	// Previously rejected, as "bobby" contains "bob"
+	if _, err := p.Vote("bob", []int{2}, time.Now()); err != nil {
+		t.Fatalf("bob vote failed: %v", err)
+	}
+
+	if _, err := p.Vote("BOB", []int{1}, time.Now()); err != ErrAlreadyVoted {
+		t.Fatalf("Expected ErrAlreadyVoted for a second ballot, got %v", err)
+	}
+
+	if counts := p.Results().Counts; !reflect.DeepEqual(counts, []int{1, 1}) {
+		t.Fatalf("Expected one vote each, got %v", counts)
+	}
+}
+
+func TestVoteValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  Options
+		numbers  []int
+		expected error
+	}{
+		{"single answer", Options{}, []int{2}, nil},
+		{"out of range", Options{}, []int{4}, ErrInvalidChoice},
+		{"zero", Options{}, []int{0}, ErrInvalidChoice},
+		{"several answers in single mode", Options{}, []int{1, 2}, ErrOneChoice},
+		{"repeated answer in single mode", Options{}, []int{2, 2}, nil},
+		{"several answers in multi mode", Options{Mode: Multi}, []int{1, 3}, nil},
+		{"ranking", Options{Mode: Ranked}, []int{3, 1, 2}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestPoll(t, test.options, "Chips", "Cookies", "Fruit")
+			if _, err := p.Vote("saltymoth", test.numbers, time.Now()); err != test.expected {
+				t.Fatalf("Expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestChangeVote(t *testing.T) {
+	p := newTestPoll(t, Options{AllowChange: true}, "Chips", "Cookies")
+
+	p.Vote("saltymoth", []int{1}, time.Now())
+	changed, err := p.Vote("SaltyMoth", []int{2}, time.Now())
+	if err != nil || !changed {
+		t.Fatalf("Expected vote to be changed, got %v %v", changed, err)
+	}
+
+	results := p.Results()
+	if !reflect.DeepEqual(results.Counts, []int{0, 1}) || results.Voters != 1 {
+		t.Fatalf("Expected only the changed vote to count, got %+v", results)
+	}
+}
+
+func TestClosedPollRejectsVotes(t *testing.T) {
+	p := newTestPoll(t, Options{}, "Chips", "Cookies")
+	p.Ended = time.Now()
+
+	if _, err := p.Vote("saltymoth", []int{1}, time.Now()); err != ErrClosed {
+		t.Fatalf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		ballots [][]int
+		counts  []int
+		winners []int
+	}{
+		{"no votes", Options{}, nil, []int{0, 0, 0, 0}, []int{}},
+		{"single winner", Options{}, [][]int{{1}, {2}, {2}}, []int{1, 2, 0, 0}, []int{1}},
+		{"ties are all winners in answer order", Options{}, [][]int{{3}, {1}}, []int{1, 0, 1, 0}, []int{0, 2}},
+		{"multi counts every choice", Options{Mode: Multi}, [][]int{{1, 2}, {2, 3}, {2}}, []int{1, 3, 1, 0}, []int{1}},
+		{
+			// Nuts then Fruit are eliminated, and the Fruit ballot moves to Cookies
+			"ranked runoff", Options{Mode: Ranked},
+			[][]int{{1, 2}, {1, 2}, {2, 1}, {2, 3}, {3, 2}},
+			[]int{2, 3, 0, 0}, []int{1},
+		},
+		{"ranked majority in the first round", Options{Mode: Ranked}, [][]int{{1}, {1}, {2}}, []int{2, 1, 0, 0}, []int{0}},
+		{
+			// After Nuts, Cookies and Fruit tie for fewest. Cookies had fewer first choices,
+			// so is eliminated even though Fruit is listed last
+			"ranked elimination tie on first choices", Options{Mode: Ranked},
+			[][]int{{1}, {1}, {1}, {1}, {2, 3}, {2, 3}, {3}, {3}, {3}, {4, 2, 3}},
+			[]int{4, 0, 6, 0}, []int{2},
+		},
+		{
+			// Cookies and Fruit tie on votes and first choices, so Fruit, listed last, goes
+			// first. Its ballot moves to Cookies, which then ties with Chips
+			"ranked elimination tie on order", Options{Mode: Ranked},
+			[][]int{{1}, {1}, {2, 1}, {3, 2}},
+			[]int{2, 2, 0, 0}, []int{0, 1},
+		},
+		{"ranked full tie", Options{Mode: Ranked}, [][]int{{1}, {2}}, []int{1, 1, 0, 0}, []int{0, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestPoll(t, test.options, "Chips", "Cookies", "Fruit", "Nuts")
+			for idx, ballot := range test.ballots {
+				if _, err := p.Vote(string(rune('a'+idx)), ballot, time.Now()); err != nil {
+					t.Fatalf("Ballot %v failed: %v", ballot, err)
+				}
+			}
+
+			results := p.Results()
+			if !reflect.DeepEqual(results.Counts, test.counts) {
+				t.Fatalf("Expected counts %v, got %v (rounds %v)", test.counts, results.Counts, results.Rounds)
+			}
+
+			if !reflect.DeepEqual(results.Winners, test.winners) {
+				t.Fatalf("Expected winners %v, got %v", test.winners, results.Winners)
+			}
+		})
+	}
+}
+
+func TestParseChoices(t *testing.T) {
+	tests := []struct {
+		message string
+		numbers []int
+		ok      bool
+	}{
+		{"2", []int{2}, true},
+		{"1 3", []int{1, 3}, true},
+		{"3,1, 2", []int{3, 1, 2}, true},
+		{"2 is the best", nil, false},
+		{"", nil, false},
+	}
+
+	for _, test := range tests {
+		numbers, ok := ParseChoices(test.message)
+		if ok != test.ok || !reflect.DeepEqual(numbers, test.numbers) {
+			t.Fatalf("ParseChoices(%q) expected %v %v, got %v %v", test.message, test.numbers, test.ok, numbers, ok)
+		}
+	}
+}
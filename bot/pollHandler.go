@@ -1,42 +1,515 @@
 package bot
 
 import (
+	"encoding/json"
+	"fmt"
+	"medgebot/bot/poll"
 	"medgebot/logger"
-	"strconv"
 	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PollTopic is the state topic used to notify listeners of Poll changes
+const PollTopic = "poll"
+
+// dataStore keys the running Poll and finished Polls are persisted at
+const (
+	pollKey        = "poll"
+	pollHistoryKey = "pollHistory"
+)
+
+// pollHistorySize is the number of finished Polls kept
+const pollHistorySize = 50
+
+// Poll durations, shared by the HTTP API and chat commands
+const (
+	DefaultPollDuration = 3 * time.Minute
+	MaxPollDuration     = time.Hour
 )
 
-// RegisterPollHandler collects Poll answers from Chat messages
+// ErrNoPoll is returned when ending a Poll while none is running
+var ErrNoPoll = errors.New("No poll running")
+
+// RegisterPollHandler collects Poll answers from Chat messages and handles the !poll
+// chat command. A Poll running when the Bot last stopped is resumed, or closed if it
+// ended in the meantime
 func (bot *Bot) RegisterPollHandler() {
+	bot.registerFeature(FeaturePolls)
+	bot.loadPolls()
+
 	bot.RegisterHandler(
 		NewHandler(func(evt Event) {
-			if strings.HasPrefix(evt.Message, "!poll") {
-				bot.SendPollMessage()
+			// Sub and cheer Events carry viewer text too, but only chat votes
+			if !bot.FeatureEnabled(FeaturePolls) || !evt.IsChatEvent() {
+				return
+			}
+
+			if evt.Message == "!poll" || strings.HasPrefix(evt.Message, "!poll ") {
+				bot.handlePollCommand(evt)
+				return
 			}
 
 			if !bot.IsPollRunning() {
 				return
 			}
 
-			// Message should just be a number (and within range of answers). Otherwise reject as a vote
-			vote, err := strconv.Atoi(evt.Message)
-			if err != nil {
-				return // Assumed to not be a valid vote
+			// Message should just be numbers (and within range of answers). Otherwise reject as a vote
+			numbers, ok := poll.ParseChoices(evt.Message)
+			if !ok {
+				return // Assumed to not be a vote
 			}
 
-			alreadyVoted, err := bot.dataStore.GetOrDefault("voters", "")
-			if err != nil {
-				logger.Error(err, "fetch voters from bot.dataStore")
-				return
+			if err := bot.VotePoll(evt.Sender, numbers); err != nil {
+				logger.Info("Rejected poll vote from %s: %v", evt.Sender, err)
+			}
+		}).Named(FeaturePolls),
+	)
+}
+
+// loadPolls reads the persisted Poll history and running Poll, scheduling its close
+func (bot *Bot) loadPolls() {
+	var history []poll.Poll
+	if err := bot.loadJSON(pollHistoryKey, &history); err != nil {
+		logger.Error(err, "load poll history")
+	}
+
+	var running *poll.Poll
+	if err := bot.loadJSON(pollKey, &running); err != nil {
+		logger.Error(err, "load running poll")
+	}
+
+	bot.pollMu.Lock()
+	defer bot.pollMu.Unlock()
+
+	bot.pollHistory = history
+	bot.poll = running
+	if running != nil {
+		if running.Ballots == nil {
+			running.Ballots = make(map[string]poll.Ballot)
+		}
+		logger.Info("Resuming Poll: %s", running.Question)
+		bot.schedulePollClose(running.ID, time.Until(running.Ends))
+	}
+}
+
+// loadJSON decodes the JSON stored at the dataStore key into dest. dest is left as is
+// if nothing is stored
+func (bot *Bot) loadJSON(key string, dest interface{}) error {
+	stored, err := bot.dataStore.GetOrDefault(key, "")
+	if err != nil {
+		return errors.Wrap(err, "fetch "+key)
+	}
+
+	if stored == "" {
+		return nil
+	}
+
+	return errors.Wrap(json.Unmarshal([]byte(stored), dest), "parse "+key)
+}
+
+// IsPollRunning checks if a Poll is currently active
+func (bot *Bot) IsPollRunning() bool {
+	bot.pollMu.Lock()
+	defer bot.pollMu.Unlock()
+
+	return bot.poll != nil
+}
+
+// CurrentPoll returns a copy of the running Poll, if any
+func (bot *Bot) CurrentPoll() (poll.Poll, bool) {
+	bot.pollMu.Lock()
+	defer bot.pollMu.Unlock()
+
+	if bot.poll == nil {
+		return poll.Poll{}, false
+	}
+
+	return bot.poll.Copy(), true
+}
+
+// PollHistory returns the finished Polls, newest first
+func (bot *Bot) PollHistory() []poll.Poll {
+	bot.pollMu.Lock()
+	defer bot.pollMu.Unlock()
+
+	history := make([]poll.Poll, 0, len(bot.pollHistory))
+	for idx := len(bot.pollHistory) - 1; idx >= 0; idx-- {
+		history = append(history, bot.pollHistory[idx].Copy())
+	}
+
+	return history
+}
+
+// StartPoll starts a poll within the Bot. Returns error if poll already running.
+// The Poll is closed, announcing the winner, after the given Duration
+func (bot *Bot) StartPoll(duration time.Duration, question string, answers []string, options poll.Options) error {
+	bot.pollMu.Lock()
+	if bot.poll != nil {
+		bot.pollMu.Unlock()
+		return errors.New("Poll already running")
+	}
+
+	if duration > MaxPollDuration {
+		bot.pollMu.Unlock()
+		return errors.Errorf("poll duration must be at most %s", MaxPollDuration)
+	}
+
+	started, err := poll.New(bot.nextPollID(), question, answers, options, time.Now(), duration)
+	if err != nil {
+		bot.pollMu.Unlock()
+		return err
+	}
+
+	logger.Info("Starting new Poll: %s", question)
+	bot.poll = started
+	bot.schedulePollClose(started.ID, duration)
+	bot.persistPoll()
+	bot.pollMu.Unlock()
+
+	bot.SendPollMessage()
+	bot.notifyStateChange(PollTopic)
+
+	return nil
+}
+
+// nextPollID returns the ID for a new Poll. Caller must hold pollMu
+func (bot *Bot) nextPollID() int {
+	if len(bot.pollHistory) == 0 {
+		return 1
+	}
+
+	return bot.pollHistory[len(bot.pollHistory)-1].ID + 1
+}
+
+// schedulePollClose closes the Poll with the given ID after the Duration, unless it
+// already ended. Caller must hold pollMu
+func (bot *Bot) schedulePollClose(id int, after time.Duration) {
+	if after < 0 {
+		after = 0
+	}
+
+	bot.pollTimer = time.AfterFunc(after, func() {
+		if _, err := bot.endPoll(id, false); err != nil && err != ErrNoPoll {
+			logger.Error(err, "close poll %d", id)
+		}
+	})
+}
+
+// SendPollMessage sends the current Poll message, if a poll is running
+func (bot *Bot) SendPollMessage() {
+	current, running := bot.CurrentPoll()
+	if !running {
+		bot.SendMessage("No poll running")
+		return
+	}
+
+	formattedAnswers := ""
+	for idx, answer := range current.Answers {
+		formattedAnswers += fmt.Sprintf("%d: %s | ", idx+1, answer)
+	}
+
+	instructions := "Type a number only in chat to vote!"
+	switch current.Options.Mode {
+	case poll.Multi:
+		instructions = "Type the numbers of every answer you like to vote, ex: 1 3"
+	case poll.Ranked:
+		instructions = "Rank the answers by typing their numbers, favorite first, ex: 3 1 2"
+	}
+	if current.Options.AllowChange {
+		instructions += " You can change your vote."
+	}
+
+	bot.SendMessage("Poll started! %s Question: %s - | %s", instructions, current.Question, formattedAnswers)
+}
+
+// VotePoll records the voter's ballot in the running Poll. numbers are the answer numbers
+// shown in chat. Each voter has one ballot, replaced only if the Poll allows changes
+func (bot *Bot) VotePoll(voter string, numbers []int) error {
+	bot.pollMu.Lock()
+	if bot.poll == nil {
+		bot.pollMu.Unlock()
+		return ErrNoPoll
+	}
+
+	changed, err := bot.poll.Vote(voter, numbers, time.Now())
+	if err != nil {
+		bot.pollMu.Unlock()
+		return err
+	}
+	bot.persistPoll()
+	bot.pollMu.Unlock()
+
+	if !changed {
+		pollVotes.Inc()
+	}
+	bot.notifyStateChange(PollTopic)
+
+	return nil
+}
+
+// ClosePoll ends the running Poll early, announcing the winner
+func (bot *Bot) ClosePoll() (poll.Poll, error) {
+	return bot.endPoll(0, false)
+}
+
+// CancelPoll ends the running Poll without announcing a winner
+func (bot *Bot) CancelPoll() (poll.Poll, error) {
+	return bot.endPoll(0, true)
+}
+
+// endPoll ends the running Poll and records it in the history. If id is not 0, only the
+// Poll with that ID is ended. Returns ErrNoPoll if there is no such Poll
+func (bot *Bot) endPoll(id int, canceled bool) (poll.Poll, error) {
+	bot.pollMu.Lock()
+	if bot.poll == nil || (id != 0 && bot.poll.ID != id) {
+		bot.pollMu.Unlock()
+		return poll.Poll{}, ErrNoPoll
+	}
+
+	if bot.pollTimer != nil {
+		bot.pollTimer.Stop()
+		bot.pollTimer = nil
+	}
+
+	ended := bot.poll.Copy()
+	ended.Ended = time.Now()
+	ended.Canceled = canceled
+
+	bot.poll = nil
+	bot.pollHistory = append(bot.pollHistory, ended)
+	if len(bot.pollHistory) > pollHistorySize {
+		bot.pollHistory = bot.pollHistory[len(bot.pollHistory)-pollHistorySize:]
+	}
+	bot.persistPoll()
+	bot.persistPollHistory()
+	bot.pollMu.Unlock()
+
+	if canceled {
+		logger.Info("Canceling poll")
+		bot.SendMessage("Poll canceled: %s", ended.Question)
+	} else {
+		logger.Info("Closing poll")
+		bot.announcePollWinners(ended)
+	}
+
+	bot.notifyStateChange(PollTopic)
+
+	return ended.Copy(), nil
+}
+
+// announcePollWinners sends the winning answers of the Poll, accounting for ties
+func (bot *Bot) announcePollWinners(ended poll.Poll) {
+	results := ended.Results()
+	if len(results.Winners) == 0 {
+		bot.SendMessage("No poll winner")
+		return
+	}
+
+	winners := make([]string, 0, len(results.Winners))
+	for _, idx := range results.Winners {
+		winners = append(winners, fmt.Sprintf("[%d] %s with %d votes", idx+1, ended.Answers[idx], results.Counts[idx]))
+	}
+
+	bot.SendMessage("Poll Winner(s): %s", strings.Join(winners, " | "))
+}
+
+// persistPoll stores the running Poll in the dataStore. Caller must hold pollMu
+func (bot *Bot) persistPoll() {
+	running := ""
+	if bot.poll != nil {
+		encoded, err := json.Marshal(bot.poll)
+		if err != nil {
+			logger.Error(err, "encode running poll")
+			return
+		}
+		running = string(encoded)
+	}
+
+	if err := bot.dataStore.Put(pollKey, running); err != nil {
+		logger.Error(err, "store running poll")
+	}
+}
+
+// persistPollHistory stores the finished Polls in the dataStore. Caller must hold pollMu
+func (bot *Bot) persistPollHistory() {
+	history, err := json.Marshal(bot.pollHistory)
+	if err != nil {
+		logger.Error(err, "encode poll history")
+		return
+	}
+
+	if err := bot.dataStore.Put(pollHistoryKey, string(history)); err != nil {
+		logger.Error(err, "store poll history")
+	}
+}
+
+// handlePollCommand responds to !poll with the running Poll. Anyone can see the results,
+// only mods (and the broadcaster) can manage Polls:
+//
+//	!poll start [DURATION] [MODE] [change] "QUESTION" | ANSWER | ANSWER
+//	!poll end     - close the Poll, announcing the winner
+//	!poll cancel  - cancel the Poll without a winner
+//	!poll results - votes so far, or the last Poll's results
+func (bot *Bot) handlePollCommand(evt Event) {
+	tokens := strings.Fields(evt.Message)
+	if len(tokens) == 1 {
+		bot.SendPollMessage()
+		return
+	}
+
+	action := strings.ToLower(tokens[1])
+	if action == "results" {
+		bot.SendPollResults()
+		return
+	}
+
+	if !evt.Moderator {
+		return
+	}
+
+	var err error
+	switch action {
+	case "start":
+		var cmd pollStartCommand
+		args := strings.TrimSpace(strings.TrimPrefix(evt.Message, tokens[0]+" "+tokens[1]))
+		if cmd, err = parsePollStart(args); err == nil {
+			err = bot.StartPoll(cmd.duration, cmd.question, cmd.answers, cmd.options)
+		}
+	case "end":
+		_, err = bot.ClosePoll()
+	case "cancel":
+		_, err = bot.CancelPoll()
+	default:
+		return
+	}
+
+	if err != nil {
+		bot.SendMessage("@%s %s", evt.Sender, err.Error())
+	}
+}
+
+// pollStartCommand is a parsed !poll start command
+type pollStartCommand struct {
+	duration time.Duration
+	question string
+	answers  []string
+	options  poll.Options
+}
+
+// parsePollStart reads the arguments of !poll start, ex: 2m ranked "Best snack?" | Chips | Cookies.
+// The duration, mode and "change", to allow vote changes, are optional and only recognized
+// before the question. If the question is not quoted, only a duration is. A mode or "change"
+// after the start of the question is refused, rather than silently asked as part of it
+func parsePollStart(args string) (pollStartCommand, error) {
+	cmd := pollStartCommand{duration: DefaultPollDuration}
+	usage := errors.New(`usage: !poll start [DURATION] [MODE] [change] "QUESTION" | ANSWER | ANSWER`)
+	optionsFirst := errors.New(`poll options go before a quoted question, ex: !poll start multi change "QUESTION" | ANSWER | ANSWER`)
+
+	parts := strings.Split(args, "|")
+	head := strings.TrimSpace(parts[0])
+	for _, answer := range parts[1:] {
+		if answer = strings.TrimSpace(answer); answer != "" {
+			cmd.answers = append(cmd.answers, answer)
+		}
+	}
+
+	quoted := strings.Index(head, `"`)
+	prefix := head
+	if quoted >= 0 {
+		prefix = head[:quoted]
+		question := head[quoted+1:]
+		if closing := strings.LastIndex(question, `"`); closing >= 0 {
+			if strings.TrimSpace(question[closing+1:]) != "" {
+				return cmd, optionsFirst
 			}
+			question = question[:closing]
+		}
+		cmd.question = strings.TrimSpace(question)
+	}
 
-			if strings.Contains(alreadyVoted, evt.Sender) {
-				return // Can't vote multiple times
+	fields := strings.Fields(prefix)
+	for idx, field := range fields {
+		lower := strings.ToLower(field)
+		if duration, err := time.ParseDuration(lower); err == nil && idx == 0 {
+			if duration <= 0 || duration > MaxPollDuration {
+				return cmd, errors.Errorf("poll duration must be above 0 and at most %s", MaxPollDuration)
 			}
+			cmd.duration = duration
+			continue
+		}
 
-			// Valid vote - append their vote and note that they voted
-			bot.dataStore.Append("voters", ",", evt.Sender)
-			bot.AddPollVote(vote)
-		}),
-	)
+		if quoted < 0 {
+			// Unquoted question: everything after the duration
+			for _, word := range fields[idx+1:] {
+				if isPollOption(word) {
+					return cmd, optionsFirst
+				}
+			}
+			cmd.question = strings.Join(fields[idx:], " ")
+			break
+		}
+
+		if lower == "change" {
+			cmd.options.AllowChange = true
+			continue
+		}
+
+		mode, err := poll.ParseMode(lower)
+		if err != nil {
+			return cmd, usage
+		}
+		cmd.options.Mode = mode
+	}
+
+	if cmd.question == "" || len(cmd.answers) == 0 {
+		return cmd, usage
+	}
+
+	return cmd, nil
+}
+
+// isPollOption checks if the word is a !poll start option: a mode or "change"
+func isPollOption(word string) bool {
+	word = strings.ToLower(word)
+	if word == "change" {
+		return true
+	}
+
+	_, err := poll.ParseMode(word)
+	return err == nil
+}
+
+// SendPollResults sends the votes of the running Poll, or the results of the last Poll
+func (bot *Bot) SendPollResults() {
+	if current, running := bot.CurrentPoll(); running {
+		bot.SendMessage("Poll results so far: %s", pollStandings(current))
+		return
+	}
+
+	history := bot.PollHistory()
+	if len(history) == 0 {
+		bot.SendMessage("No poll running")
+		return
+	}
+
+	last := history[0]
+	if last.Canceled {
+		bot.SendMessage("Last poll was canceled: %s", last.Question)
+		return
+	}
+
+	bot.SendMessage("Last poll: %s - %s", last.Question, pollStandings(last))
+}
+
+// pollStandings describes the votes for each answer of the Poll, ex: [1] Chips 3 | [2] Cookies 1 (4 voters)
+func pollStandings(p poll.Poll) string {
+	results := p.Results()
+	standings := make([]string, 0, len(p.Answers))
+	for idx, answer := range p.Answers {
+		standings = append(standings, fmt.Sprintf("[%d] %s %d", idx+1, answer, results.Counts[idx]))
+	}
+
+	return fmt.Sprintf("%s (%d voters)", strings.Join(standings, " | "), results.Voters)
 }
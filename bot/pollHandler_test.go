@@ -0,0 +1,268 @@
+package bot
+
+import (
+	"medgebot/bot/poll"
+	"medgebot/cache"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newPollTestBot(store *cache.PersistableCache) (*Bot, TestChatClient) {
+	bot := New(store)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+	bot.RegisterPollHandler()
+
+	// This must happen after Handler registration, else data race occurs
+	bot.Start()
+	return &bot, checker
+}
+
+func sendVote(bot *Bot, sender, vote string) {
+	evt := NewChatEvent()
+	evt.Sender = sender
+	evt.Message = vote
+	bot.events <- evt
+}
+
+// waitForVoters waits until the running Poll has a ballot from the given number of voters
+func waitForVoters(t *testing.T, bot *Bot, voters int) poll.Poll {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		current, _ := bot.CurrentPoll()
+		if len(current.Ballots) == voters {
+			return current
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d voters, got %+v", voters, current)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPollSurvivesRestart(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newPollTestBot(&store)
+
+	if err := bot.StartPoll(time.Minute, "Best snack?", []string{"Chips", "Cookies"}, poll.Options{}); err != nil {
+		t.Fatalf("StartPoll failed: %v", err)
+	}
+	expectMessage(t, checker, "Poll started! Type a number only in chat to vote! Question: Best snack? - | 1: Chips | 2: Cookies | ")
+
+	sendVote(bot, "saltymoth", "2")
+	sendVote(bot, "BlackMarvel", "2")
+	sendVote(bot, "nojoy", "1")
+	waitForVoters(t, bot, 3)
+
+	// A new Bot on the same store picks the Poll up where it was
+	restarted, checker := newPollTestBot(&store)
+	resumed := waitForVoters(t, restarted, 3)
+	if resumed.Question != "Best snack?" || resumed.Results().Counts[1] != 2 {
+		t.Fatalf("Expected resumed poll with votes, got %+v", resumed)
+	}
+
+	closed, err := restarted.ClosePoll()
+	if err != nil {
+		t.Fatalf("ClosePoll failed: %v", err)
+	}
+	expectMessage(t, checker, "Poll Winner(s): [2] Cookies with 2 votes")
+
+	if closed.Ended.IsZero() || closed.Canceled {
+		t.Fatalf("Expected closed poll, got %+v", closed)
+	}
+
+	history := restarted.PollHistory()
+	if len(history) != 1 || history[0].ID != resumed.ID {
+		t.Fatalf("Expected closed poll in history, got %+v", history)
+	}
+
+	if restarted.IsPollRunning() {
+		t.Fatalf("Expected no poll running after close")
+	}
+}
+
+func TestPollClosesAfterDuration(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newPollTestBot(&store)
+
+	if err := bot.StartPoll(50*time.Millisecond, "Ship it?", []string{"Yes", "No"}, poll.Options{}); err != nil {
+		t.Fatalf("StartPoll failed: %v", err)
+	}
+	expectMessage(t, checker, "Poll started! Type a number only in chat to vote! Question: Ship it? - | 1: Yes | 2: No | ")
+	expectMessage(t, checker, "No poll winner")
+
+	if history := bot.PollHistory(); len(history) != 1 || history[0].Question != "Ship it?" {
+		t.Fatalf("Expected timed out poll in history, got %+v", history)
+	}
+}
+
+func TestCancelPoll(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newPollTestBot(&store)
+
+	if _, err := bot.CancelPoll(); err != ErrNoPoll {
+		t.Fatalf("Expected ErrNoPoll with no poll running, got %v", err)
+	}
+
+	bot.StartPoll(time.Minute, "Ship it?", []string{"Yes", "No"}, poll.Options{})
+	expectMessage(t, checker, "Poll started! Type a number only in chat to vote! Question: Ship it? - | 1: Yes | 2: No | ")
+
+	canceled, err := bot.CancelPoll()
+	if err != nil || !canceled.Canceled {
+		t.Fatalf("Expected canceled poll, got %+v - %v", canceled, err)
+	}
+	expectMessage(t, checker, "Poll canceled: Ship it?")
+
+	// The next poll gets a new ID
+	bot.StartPoll(time.Minute, "Ship it now?", []string{"Yes", "No"}, poll.Options{})
+	if current, _ := bot.CurrentPoll(); current.ID != canceled.ID+1 {
+		t.Fatalf("Expected poll ID %d, got %d", canceled.ID+1, current.ID)
+	}
+}
+
+func TestChatVotes(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newPollTestBot(&store)
+
+	bot.StartPoll(time.Minute, "Best snack?", []string{"Chips", "Cookies", "Fruit"}, poll.Options{
+		Mode:        poll.Multi,
+		AllowChange: true,
+	})
+	expectMessage(t, checker, "Poll started! Type the numbers of every answer you like to vote, ex: 1 3 You can change your vote. Question: Best snack? - | 1: Chips | 2: Cookies | 3: Fruit | ")
+
+	sendVote(bot, "bobby", "1 2")
+	sendVote(bot, "bob", "3")
+	waitForVoters(t, bot, 2)
+
+	// bob changes their mind
+	sendVote(bot, "bob", "2,3")
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		current, _ := bot.CurrentPoll()
+		counts := current.Results().Counts
+		if counts[0] == 1 && counts[1] == 2 && counts[2] == 1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected changed vote to be counted, got %v", counts)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOnlyChatVotes(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newPollTestBot(&store)
+
+	bot.StartPoll(time.Minute, "Ship it?", []string{"Yes", "No"}, poll.Options{})
+	expectMessage(t, checker, "Poll started! Type a number only in chat to vote! Question: Ship it? - | 1: Yes | 2: No | ")
+
+	resub := NewSubEvent()
+	resub.Sender = "saltymoth"
+	resub.Message = "1"
+	bot.events <- resub
+
+	cheer := NewBitsEvent()
+	cheer.Sender = "Przemko9856"
+	cheer.Amount = 100
+	cheer.Message = "2"
+	bot.events <- cheer
+
+	// Events are handled in order, so the earlier ones were handled once this vote counts
+	sendVote(bot, "BlackMarvel", "2")
+	current := waitForVoters(t, bot, 1)
+	if _, voted := current.Ballots["blackmarvel"]; !voted {
+		t.Fatalf("Expected only the chat vote to count, got %+v", current.Ballots)
+	}
+}
+
+func TestParsePollStart(t *testing.T) {
+	tests := []struct {
+		args     string
+		expected pollStartCommand
+		ok       bool
+	}{
+		{
+			`2m "Best snack?" | Chips | Cookies`,
+			pollStartCommand{2 * time.Minute, "Best snack?", []string{"Chips", "Cookies"}, poll.Options{}},
+			true,
+		},
+		{
+			`ranked change "Best snack?" | Chips | Cookies |`,
+			pollStartCommand{DefaultPollDuration, "Best snack?", []string{"Chips", "Cookies"}, poll.Options{Mode: poll.Ranked, AllowChange: true}},
+			true,
+		},
+		{
+			// Unquoted, so "multi" is part of the question
+			`90s multi or single? | Multi | Single`,
+			pollStartCommand{90 * time.Second, "multi or single?", []string{"Multi", "Single"}, poll.Options{}},
+			true,
+		},
+		{
+			// Quoted, so the options before it count
+			`multi "Best game" | Celeste | Hades`,
+			pollStartCommand{DefaultPollDuration, "Best game", []string{"Celeste", "Hades"}, poll.Options{Mode: poll.Multi}},
+			true,
+		},
+		// Options after the start of the question are refused rather than asked
+		{`Best game multi | Celeste | Hades`, pollStartCommand{}, false},
+		{`Best game multi a b`, pollStartCommand{}, false},
+		{`2m Best game change | Celeste | Hades`, pollStartCommand{}, false},
+		{`"Best game" multi | Celeste | Hades`, pollStartCommand{}, false},
+		{`2m "Best snack?"`, pollStartCommand{}, false},
+		{`2h "Best snack?" | Chips`, pollStartCommand{}, false},
+		{`loudest "Best snack?" | Chips`, pollStartCommand{}, false},
+		{`| Chips | Cookies`, pollStartCommand{}, false},
+	}
+
+	for _, test := range tests {
+		cmd, err := parsePollStart(test.args)
+		if (err == nil) != test.ok {
+			t.Fatalf("parsePollStart(%s) expected ok %v, got %v", test.args, test.ok, err)
+		}
+
+		if test.ok && !reflect.DeepEqual(cmd, test.expected) {
+			t.Fatalf("parsePollStart(%s) expected %+v, got %+v", test.args, test.expected, cmd)
+		}
+	}
+}
+
+func TestPollChatCommands(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newPollTestBot(&store)
+
+	viewer := NewChatEvent()
+	viewer.Sender = "notamod"
+	viewer.Message = `!poll start "Ship it?" | Yes | No`
+	bot.events <- viewer
+
+	mod := NewChatEvent()
+	mod.Sender = "ReallyFrank"
+	mod.Moderator = true
+	mod.Message = `!poll start 2m "Ship it?" | Yes | No`
+	bot.events <- mod
+	expectMessage(t, checker, "Poll started! Type a number only in chat to vote! Question: Ship it? - | 1: Yes | 2: No | ")
+
+	if current, _ := bot.CurrentPoll(); current.Ends.Sub(current.Started) != 2*time.Minute {
+		t.Fatalf("Expected a 2 minute poll, got %+v", current)
+	}
+
+	sendVote(bot, "saltymoth", "1")
+	sendVote(bot, "notamod", "!poll results")
+	expectMessage(t, checker, "Poll results so far: [1] Yes 1 | [2] No 0 (1 voters)")
+
+	// Only mods end polls
+	sendVote(bot, "notamod", "!poll end")
+	mod.Message = "!poll end"
+	bot.events <- mod
+	expectMessage(t, checker, "Poll Winner(s): [1] Yes with 1 votes")
+
+	bot.events <- mod
+	expectMessage(t, checker, "@ReallyFrank No poll running")
+
+	sendVote(bot, "notamod", "!poll results")
+	expectMessage(t, checker, "Last poll: Ship it? - [1] Yes 1 | [2] No 0 (1 voters)")
+}
@@ -1,31 +1,178 @@
 package bot
 
 import (
+	"encoding/json"
 	"fmt"
 	"medgebot/bot/viewer"
 	log "medgebot/logger"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
-// RegisterRaidHandler registers the Raid Auto-Thank feature with the Bot
-func (bot *Bot) RegisterRaidHandler(messageTemplate HandlerTemplate, delaySeconds int) {
+// raidersKey is the dataStore key the raid history is persisted at
+const raidersKey = "raiders"
+
+// RaiderStats is the raid history of one raider
+type RaiderStats struct {
+	Name         string    `json:"name"`
+	Raids        int       `json:"raids"`
+	TotalViewers int       `json:"totalViewers"`
+	LastRaid     time.Time `json:"lastRaid"`
+}
+
+// RaidMessage is the data for raid message templates, ex: "{{.Sender}} raided {{.Raids}} times!"
+type RaidMessage struct {
+	Event
+	RaiderStats // Including this raid
+
+	FirstRaid    bool      // The raider never raided before
+	PreviousRaid time.Time // When the raider last raided before this one. Zero on their first raid
+}
+
+// RaidTier replaces the raid message for raids of at least MinViewers
+type RaidTier struct {
+	MinViewers int
+	Template   HandlerTemplate
+}
+
+// RaidOptions change what the Bot sends when raided
+type RaidOptions struct {
+	Delay         time.Duration   // Before the raid message and shoutout
+	Tiers         []RaidTier      // Raid size tiers. The largest tier the raid reaches is used
+	Shoutout      bool            // Shout out the raider after the raid message
+	FollowUp      HandlerTemplate // Sent FollowUpDelay after the raid, to greet raiders. Empty sends nothing
+	FollowUpDelay time.Duration
+}
+
+// ParseRaidTemplate parses a raid message template, validated against a RaidMessage
+func ParseRaidTemplate(name, format string) (HandlerTemplate, error) {
+	return parseTemplate(name, format, RaidMessage{})
+}
+
+// raidHistory is the RaiderStats of every raider, by lowercased name
+type raidHistory struct {
+	sync.Mutex
+	raiders map[string]RaiderStats
+}
+
+// RegisterRaidHandler registers the Raid Auto-Thank feature with the Bot. Each raid is
+// recorded in the raid history, persisted in the dataStore. Messages are scheduled, so the
+// handler never waits on the delays
+func (bot *Bot) RegisterRaidHandler(messageTemplate HandlerTemplate, options RaidOptions) {
+	bot.registerFeature(FeatureRaids)
+	bot.setTemplate(TemplateRaids, messageTemplate)
+	bot.setTemplate(TemplateRaidsFollowUp, options.FollowUp)
+	bot.loadRaidHistory()
+
+	tiers := append([]RaidTier{}, options.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinViewers > tiers[j].MinViewers
+	})
+
 	bot.RegisterHandler(
 		NewHandler(func(evt Event) {
+			if !bot.FeatureEnabled(FeatureRaids) {
+				return
+			}
+
 			if evt.IsRaidEvent() {
 				log.Info(fmt.Sprintf("%s is raiding with %d raiders!", evt.Sender, evt.Amount))
 
-				if delaySeconds != 0 {
-					time.Sleep(time.Duration(delaySeconds) * time.Second)
+				data := bot.recordRaid(evt, time.Now())
+				tmpl := bot.template(TemplateRaids)
+				for _, tier := range tiers {
+					if evt.Amount >= tier.MinViewers {
+						tmpl = tier.Template
+						break
+					}
 				}
 
-				bot.SendMessage(messageTemplate.Parse(evt))
+				time.AfterFunc(options.Delay, func() {
+					if msg := tmpl.execute(data); msg != "" {
+						bot.SendMessage("%s", msg)
+					}
+
+					if options.Shoutout {
+						bot.SendMessage("%s", shoutoutMessage(evt.Sender))
+					}
+				})
+
+				time.AfterFunc(options.FollowUpDelay, func() {
+					if msg := bot.template(TemplateRaidsFollowUp).execute(data); msg != "" {
+						bot.SendMessage("%s", msg)
+					}
+				})
 
 				metric := viewer.Metric{
 					Name:   evt.Sender,
 					Amount: evt.Amount,
 				}
-				bot.dataStore.Put(viewer.LastRaider, metric.String())
+				bot.putMetric(viewer.LastRaider, metric)
 			}
-		}),
+		}).Named(FeatureRaids),
 	)
 }
+
+// loadRaidHistory reads the persisted raid history
+func (bot *Bot) loadRaidHistory() {
+	raiders := make(map[string]RaiderStats)
+	if err := bot.loadJSON(raidersKey, &raiders); err != nil {
+		log.Error(err, "load raid history")
+	}
+
+	bot.raiders.Lock()
+	bot.raiders.raiders = raiders
+	bot.raiders.Unlock()
+}
+
+// recordRaid adds the raid to the raider's history, returning the raid message data
+func (bot *Bot) recordRaid(evt Event, at time.Time) RaidMessage {
+	key := strings.ToLower(evt.Sender)
+
+	bot.raiders.Lock()
+	stats, raided := bot.raiders.raiders[key]
+	data := RaidMessage{
+		Event:        evt,
+		FirstRaid:    !raided,
+		PreviousRaid: stats.LastRaid,
+	}
+
+	stats.Name = evt.Sender
+	stats.Raids++
+	stats.TotalViewers += evt.Amount
+	stats.LastRaid = at
+	bot.raiders.raiders[key] = stats
+	data.RaiderStats = stats
+
+	stored, err := json.Marshal(bot.raiders.raiders)
+	bot.raiders.Unlock()
+
+	if err != nil {
+		log.Error(err, "encode raid history")
+		return data
+	}
+
+	if err := bot.dataStore.Put(raidersKey, string(stored)); err != nil {
+		log.Error(err, "store raid history")
+	}
+
+	return data
+}
+
+// Raiders returns the raid history of every raider, most recent raid first
+func (bot *Bot) Raiders() []RaiderStats {
+	bot.raiders.Lock()
+	defer bot.raiders.Unlock()
+
+	raiders := make([]RaiderStats, 0, len(bot.raiders.raiders))
+	for _, stats := range bot.raiders.raiders {
+		raiders = append(raiders, stats)
+	}
+	sort.Slice(raiders, func(i, j int) bool {
+		return raiders[i].LastRaid.After(raiders[j].LastRaid)
+	})
+
+	return raiders
+}
@@ -4,8 +4,32 @@ import (
 	"medgebot/bot/bottest"
 	"medgebot/cache"
 	"testing"
+	"time"
 )
 
+func newRaidTestBot(t *testing.T, store *cache.PersistableCache, options RaidOptions) (*Bot, TestChatClient) {
+	tmpl, err := ParseRaidTemplate(TemplateRaids, "Welcome {{.Sender}}'s {{.Amount}} raiders!{{if not .FirstRaid}} Raid number {{.Raids}}, {{.TotalViewers}} raiders so far!{{end}}")
+	if err != nil {
+		t.Fatalf("ParseRaidTemplate failed: %v", err)
+	}
+
+	bot := New(store)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+	bot.RegisterRaidHandler(tmpl, options)
+
+	// This must happen after Handler registration, else data race occurs
+	bot.Start()
+	return &bot, checker
+}
+
+func sendRaid(bot *Bot, raider string, raidSize int) {
+	evt := NewRaidEvent()
+	evt.Sender = raider
+	evt.Amount = raidSize
+	bot.events <- evt
+}
+
 // Raid handler through the Bot
 func TestRaidHandler(t *testing.T) {
 	// Initialize Bot
@@ -18,7 +42,7 @@ func TestRaidHandler(t *testing.T) {
 	tmpl := bottest.MakeTemplate("testRaid", "Welcome {{.Sender}}'s {{.Amount}} raiders!")
 	bot.RegisterRaidHandler(HandlerTemplate{
 		template: tmpl,
-	}, 1)
+	}, RaidOptions{Delay: time.Second})
 
 	// This must happen after Handler registration, else data race occurs
 	bot.Start()
@@ -47,7 +71,7 @@ func TestRaidHandlerIgnoresInvalidEvents(t *testing.T) {
 	tmpl := bottest.MakeTemplate("testRaid", "Welcome {{.Sender}}'s {{.Amount}} raiders!")
 	bot.RegisterRaidHandler(HandlerTemplate{
 		template: tmpl,
-	}, 0)
+	}, RaidOptions{})
 
 	// This must happen after Handler registration, else data race occurs
 	bot.Start()
@@ -64,3 +88,71 @@ func TestRaidHandlerIgnoresInvalidEvents(t *testing.T) {
 		// If we don't receive a response, the Bot didn't erroneously parse the wrong message
 	}
 }
+
+func TestRaidHistory(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newRaidTestBot(t, &store, RaidOptions{})
+
+	sendRaid(bot, "shito86", 5)
+	expectMessage(t, checker, "Welcome shito86's 5 raiders!")
+
+	// Raid history is kept across restarts, by case-insensitive raider
+	restarted, checker := newRaidTestBot(t, &store, RaidOptions{})
+	sendRaid(restarted, "Shito86", 7)
+	expectMessage(t, checker, "Welcome Shito86's 7 raiders! Raid number 2, 12 raiders so far!")
+
+	raiders := restarted.Raiders()
+	if len(raiders) != 1 || raiders[0].Name != "Shito86" || raiders[0].Raids != 2 || raiders[0].TotalViewers != 12 {
+		t.Fatalf("Expected 2 raids from Shito86, got %+v", raiders)
+	}
+}
+
+func TestRaidTiersAndFollowUp(t *testing.T) {
+	big, _ := ParseRaidTemplate(TemplateRaids, "{{.Amount}} raiders! Batten down the hatches!")
+	huge, _ := ParseRaidTemplate(TemplateRaids, "{{.Amount}} raiders!? Abandon the Lab!")
+	followUp, _ := ParseRaidTemplate(TemplateRaidsFollowUp, "Glad to have you, raiders from {{.Sender}}!")
+
+	store, _ := cache.InMemory(0)
+	bot, checker := newRaidTestBot(t, &store, RaidOptions{
+		Tiers: []RaidTier{
+			{MinViewers: 50, Template: big},
+			{MinViewers: 500, Template: huge},
+		},
+		FollowUp:      followUp,
+		FollowUpDelay: 50 * time.Millisecond,
+	})
+
+	sendRaid(bot, "SpookyGhostMachine", 120)
+	expectMessages(t, checker, "120 raiders! Batten down the hatches!", "Glad to have you, raiders from SpookyGhostMachine!")
+
+	sendRaid(bot, "Sorcerbee", 500)
+	expectMessages(t, checker, "500 raiders!? Abandon the Lab!", "Glad to have you, raiders from Sorcerbee!")
+}
+
+func TestRaidShoutout(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	bot, checker := newRaidTestBot(t, &store, RaidOptions{Shoutout: true})
+
+	sendRaid(bot, "shito86", 5)
+	expectMessages(t, checker, "Welcome shito86's 5 raiders!", "Go check out @shito86 at https://twitch.tv/shito86!")
+}
+
+// expectMessages waits for each of the messages, which may arrive in any order
+func expectMessages(t *testing.T, checker TestChatClient, messages ...string) {
+	expected := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		expected[msg] = true
+	}
+
+	for range messages {
+		select {
+		case response := <-checker.events:
+			if !expected[response.Message] {
+				t.Fatalf("Unexpected message [%s], expected one of %v", response.Message, messages)
+			}
+			delete(expected, response.Message)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timed out waiting for %v", expected)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Features that can be turned on and off while the Bot is running.
+// Names match the config.yaml sections
+const (
+	FeatureGreeter       = "greeter"
+	FeatureRaids         = "raids"
+	FeatureBits          = "bits"
+	FeatureSubs          = "subs"
+	FeaturePolls         = "polls"
+	FeatureChannelPoints = "channelPoints"
+	FeatureCommands      = "commands"
+	FeatureGoals         = "goals"
+	FeatureHype          = "hype"
+)
+
+// Message templates that can be changed while the Bot is running.
+// Names match the config.yaml sections holding their messageFormat
+const (
+	TemplateGreeter             = "greeter"
+	TemplateGreeterReturning    = "greeter.returning"
+	TemplateGreeterFirstMessage = "greeter.firstMessage"
+	TemplateRaids               = "raids"
+	TemplateRaidsFollowUp       = "raids.followUp"
+	TemplateBits                = "bits"
+	TemplateSubs                = "subs"
+	TemplateSubsPrime           = "subs.prime"
+	TemplateSubsTier2           = "subs.tier2"
+	TemplateSubsTier3           = "subs.tier3"
+	TemplateGiftSubs            = "giftsubs"
+	TemplateGiftSubsTier2       = "giftsubs.tier2"
+	TemplateGiftSubsTier3       = "giftsubs.tier3"
+	TemplateGiftBombs           = "giftsubs.bomb"
+	TemplateGoals               = "goals"
+	TemplateHype                = "hype"
+)
+
+// ErrFeatureUnavailable is returned when turning on a feature the Bot can't run, ex: its
+// connection to Twitch was not started
+var ErrFeatureUnavailable = errors.New("feature can only be turned on after a restart")
+
+// registerFeature marks a feature as known and enabled, if not already known.
+// Called by the Register* methods so handlers work without extra setup
+func (bot *Bot) registerFeature(feature string) {
+	bot.settingsMu.Lock()
+	defer bot.settingsMu.Unlock()
+
+	if _, known := bot.features[feature]; !known {
+		bot.features[feature] = true
+	}
+}
+
+// SetFeatureUnavailable marks a feature as one that can't be turned on, for the given reason.
+// Used when the connection the feature's Events arrive on was not started
+func (bot *Bot) SetFeatureUnavailable(feature, reason string) {
+	bot.settingsMu.Lock()
+	defer bot.settingsMu.Unlock()
+
+	bot.unavailable[feature] = reason
+}
+
+// SetFeatureEnabled turns a registered feature on or off.
+// Returns an error if the feature's handler was never registered, or
+// ErrFeatureUnavailable when turning on an unavailable feature
+func (bot *Bot) SetFeatureEnabled(feature string, enabled bool) error {
+	bot.settingsMu.Lock()
+	defer bot.settingsMu.Unlock()
+
+	if _, known := bot.features[feature]; !known {
+		return errors.Errorf("unknown feature %s", feature)
+	}
+
+	if reason, unavailable := bot.unavailable[feature]; unavailable && enabled {
+		return errors.Wrapf(ErrFeatureUnavailable, "%s %s", feature, reason)
+	}
+
+	bot.features[feature] = enabled
+	return nil
+}
+
+// FeatureEnabled checks if the feature is registered and turned on
+func (bot *Bot) FeatureEnabled(feature string) bool {
+	bot.settingsMu.RLock()
+	defer bot.settingsMu.RUnlock()
+
+	return bot.features[feature]
+}
+
+// Features returns the on/off state of every registered feature
+func (bot *Bot) Features() map[string]bool {
+	bot.settingsMu.RLock()
+	defer bot.settingsMu.RUnlock()
+
+	features := make(map[string]bool, len(bot.features))
+	for feature, enabled := range bot.features {
+		features[feature] = enabled
+	}
+
+	return features
+}
+
+// setTemplate stores the message template handlers look up by name
+func (bot *Bot) setTemplate(name string, tmpl HandlerTemplate) {
+	bot.settingsMu.Lock()
+	defer bot.settingsMu.Unlock()
+
+	bot.templates[name] = tmpl
+}
+
+// template returns the current message template for the given name
+func (bot *Bot) template(name string) HandlerTemplate {
+	bot.settingsMu.RLock()
+	defer bot.settingsMu.RUnlock()
+
+	return bot.templates[name]
+}
+
+// SetMessageFormat replaces a registered message template with the given format.
+// The format is validated before it replaces the running template
+func (bot *Bot) SetMessageFormat(name, format string) error {
+	bot.settingsMu.RLock()
+	current, known := bot.templates[name]
+	bot.settingsMu.RUnlock()
+
+	if !known {
+		return errors.Errorf("unknown template %s", name)
+	}
+
+	// Validate against the same data the current template is executed with
+	tmpl, err := parseTemplate(name, format, current.sample)
+	if err != nil {
+		return err
+	}
+
+	bot.setTemplate(name, tmpl)
+	return nil
+}
+
+// MessageFormats returns the format of every registered message template
+func (bot *Bot) MessageFormats() map[string]string {
+	bot.settingsMu.RLock()
+	defer bot.settingsMu.RUnlock()
+
+	formats := make(map[string]string, len(bot.templates))
+	for name, tmpl := range bot.templates {
+		formats[name] = tmpl.Format()
+	}
+
+	return formats
+}
+
+// setCommands replaces the known Commands
+func (bot *Bot) setCommands(commands []Command) {
+	bot.settingsMu.Lock()
+	defer bot.settingsMu.Unlock()
+
+	bot.commands = append([]Command{}, commands...)
+}
+
+// Commands returns the known Commands, sorted by Prefix
+func (bot *Bot) Commands() []Command {
+	bot.settingsMu.RLock()
+	defer bot.settingsMu.RUnlock()
+
+	commands := append([]Command{}, bot.commands...)
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Prefix < commands[j].Prefix
+	})
+
+	return commands
+}
+
+// Command returns the known Command with the given Prefix
+func (bot *Bot) Command(prefix string) (Command, bool) {
+	bot.settingsMu.RLock()
+	defer bot.settingsMu.RUnlock()
+
+	for _, command := range bot.commands {
+		if command.Prefix == prefix {
+			return command, true
+		}
+	}
+
+	return Command{}, false
+}
+
+// SetCommand adds the Command, or replaces the known Command with the same Prefix
+func (bot *Bot) SetCommand(command Command) {
+	bot.settingsMu.Lock()
+	defer bot.settingsMu.Unlock()
+
+	for idx, known := range bot.commands {
+		if known.Prefix == command.Prefix {
+			bot.commands[idx] = command
+			return
+		}
+	}
+
+	bot.commands = append(bot.commands, command)
+}
+
+// RemoveCommand removes the known Command with the given Prefix.
+// Returns false if there was no such Command
+func (bot *Bot) RemoveCommand(prefix string) bool {
+	bot.settingsMu.Lock()
+	defer bot.settingsMu.Unlock()
+
+	for idx, known := range bot.commands {
+		if known.Prefix == prefix {
+			bot.commands = append(bot.commands[:idx], bot.commands[idx+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
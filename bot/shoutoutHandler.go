@@ -17,13 +17,16 @@ func (bot *Bot) HandleShoutoutCommand() {
 					return
 				}
 
+				// Nothing to shout out without a channel name
+				tokens := strings.Fields(contents)
+				if len(tokens) < 2 {
+					return
+				}
+
 				log.Info("Handling shoutout: %+v", evt)
 
-				tokens := strings.Split(contents, " ")
 				broadcaster := strings.TrimPrefix(tokens[1], "@")
-				bot.SendMessage(
-					fmt.Sprintf("Go check out @%s at https://twitch.tv/%s!", broadcaster, broadcaster),
-				)
+				bot.SendMessage("%s", shoutoutMessage(broadcaster))
 
 				// Grab channel being shouted out
 				// Call Twitch API for URL
@@ -44,6 +47,11 @@ func (bot *Bot) HandleShoutoutCommand() {
 				*/
 
 			}
-		}),
+		}).Named("shoutout"),
 	)
 }
+
+// shoutoutMessage is the message recommending the broadcaster's channel
+func shoutoutMessage(broadcaster string) string {
+	return fmt.Sprintf("Go check out @%s at https://twitch.tv/%s!", broadcaster, broadcaster)
+}
@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"medgebot/cache"
+	"testing"
+	"time"
+)
+
+func TestShoutoutCommand(t *testing.T) {
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+	bot.HandleShoutoutCommand()
+	bot.Start()
+
+	// Without a channel name there is nothing to shout out
+	for _, message := range []string{"!so", "!so ", "!so  "} {
+		evt := NewChatEvent()
+		evt.Message = message
+		bot.ReceiveEvent(evt)
+	}
+
+	select {
+	case resp := <-checker.events:
+		t.Fatalf("Expected no shoutout without a channel name, got %+v", resp)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	evt := NewChatEvent()
+	evt.Message = "!so  @medgelabs"
+	bot.ReceiveEvent(evt)
+
+	expectMessage(t, checker, "Go check out @medgelabs at https://twitch.tv/medgelabs!")
+}
@@ -2,33 +2,126 @@ package bot
 
 import (
 	"medgebot/bot/viewer"
+	"time"
 )
 
-// RegisterSubsHandler adds the Subscription handler logic to the Bot
-func (bot *Bot) RegisterSubsHandler(subsTemplate, giftSubsTemplate HandlerTemplate) {
+// giftBombTimeout is how long gift subs are matched to their community gift. Gift subs
+// Twitch never sent are forgotten after it
+const giftBombTimeout = time.Minute
+
+// giftBomb is a community gift still waiting for some of its gift subs
+type giftBomb struct {
+	remaining int
+	started   time.Time
+}
+
+// SubTierTemplates replace the subs and gift subs messages for Prime and higher tier subs.
+// Empty templates use the usual message
+type SubTierTemplates struct {
+	Prime     HandlerTemplate
+	Tier2     HandlerTemplate
+	Tier3     HandlerTemplate
+	GiftTier2 HandlerTemplate
+	GiftTier3 HandlerTemplate
+}
+
+// RegisterSubsHandler adds the Subscription handler logic to the Bot. A community gift is thanked
+// once with the gift bomb template, the gift subs it's made of only being recorded. Without a gift
+// bomb template, each gift sub is thanked instead
+func (bot *Bot) RegisterSubsHandler(subsTemplate, giftSubsTemplate, giftBombTemplate HandlerTemplate, tiers SubTierTemplates) {
+	bot.registerFeature(FeatureSubs)
+	bot.setTemplate(TemplateSubs, subsTemplate)
+	bot.setTemplate(TemplateGiftSubs, giftSubsTemplate)
+	bot.setTemplate(TemplateGiftBombs, giftBombTemplate)
+	bot.setTemplate(TemplateSubsPrime, tiers.Prime)
+	bot.setTemplate(TemplateSubsTier2, tiers.Tier2)
+	bot.setTemplate(TemplateSubsTier3, tiers.Tier3)
+	bot.setTemplate(TemplateGiftSubsTier2, tiers.GiftTier2)
+	bot.setTemplate(TemplateGiftSubsTier3, tiers.GiftTier3)
+
+	// Only used by the handler goroutine
+	bombs := make(map[string]*giftBomb)
+
 	bot.RegisterHandler(
 		NewHandler(func(evt Event) {
+			if !bot.FeatureEnabled(FeatureSubs) {
+				return
+			}
+
 			if evt.IsSubEvent() {
-				bot.SendMessage(subsTemplate.Parse(evt))
+				// Parsed separately, as the resub message may contain format verbs
+				if msg := bot.subTemplate(evt).Parse(evt); msg != "" {
+					bot.SendMessage("%s", msg)
+				}
 
 				// TODO if evt.isDebug() { return }
 				metric := viewer.Metric{
 					Name:   evt.Sender,
 					Amount: evt.Amount,
 				}
-				bot.dataStore.Put(viewer.LastSub, metric.String())
+				bot.putMetric(viewer.LastSub, metric)
+			} else if evt.IsMysteryGiftEvent() {
+				bombTmpl := bot.template(TemplateGiftBombs)
+				if evt.GiftOrigin == "" || bombTmpl.Format() == "" {
+					return
+				}
+
+				now := time.Now()
+				for origin, bomb := range bombs {
+					if now.Sub(bomb.started) > giftBombTimeout {
+						delete(bombs, origin)
+					}
+				}
+				bombs[evt.GiftOrigin] = &giftBomb{remaining: evt.Amount, started: now}
+
+				if msg := bombTmpl.Parse(evt); msg != "" {
+					bot.SendMessage("%s", msg)
+				}
 			} else if evt.IsGiftSubEvent() {
-				bot.SendMessage(giftSubsTemplate.Parse(evt))
+				if bomb, ok := bombs[evt.GiftOrigin]; ok && evt.GiftOrigin != "" {
+					bomb.remaining--
+					if bomb.remaining <= 0 {
+						delete(bombs, evt.GiftOrigin)
+					}
+				} else if msg := bot.subTemplate(evt).Parse(evt); msg != "" {
+					bot.SendMessage("%s", msg)
+				}
 
 				metric := viewer.Metric{
 					Name:      evt.Sender,
 					Recipient: evt.Recipient,
 					Amount:    evt.Amount,
 				}
-				bot.dataStore.Put(viewer.LastGiftSub, metric.String())
+				bot.putMetric(viewer.LastGiftSub, metric)
 			} else {
 				return // no messaging otherwise
 			}
-		}),
+		}).Named(FeatureSubs),
 	)
 }
+
+// subTemplate returns the message template for the sub or gift sub Event's tier,
+// falling back to the usual message
+func (bot *Bot) subTemplate(evt Event) HandlerTemplate {
+	name, tiered := TemplateSubs, ""
+	if evt.IsGiftSubEvent() {
+		name = TemplateGiftSubs
+	}
+
+	switch {
+	case evt.IsSubEvent() && evt.Prime:
+		tiered = TemplateSubsPrime
+	case evt.Tier == 2:
+		tiered = name + ".tier2"
+	case evt.Tier == 3:
+		tiered = name + ".tier3"
+	}
+
+	if tiered != "" {
+		if tmpl := bot.template(tiered); tmpl.Format() != "" {
+			return tmpl
+		}
+	}
+
+	return bot.template(name)
+}
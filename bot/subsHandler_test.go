@@ -2,8 +2,11 @@ package bot
 
 import (
 	"medgebot/bot/bottest"
+	"medgebot/bot/viewer"
 	"medgebot/cache"
+	"strings"
 	"testing"
+	"time"
 )
 
 var subsTmpl = bottest.MakeTemplate("testSubs", "{{.Sender}} subbed for {{.Amount}} months!")
@@ -20,7 +23,9 @@ func TestSubHandler(t *testing.T) {
 	// Initialize Handler
 	bot.RegisterSubsHandler(
 		HandlerTemplate{template: subsTmpl},
-		HandlerTemplate{template: giftSubTmpl})
+		HandlerTemplate{template: giftSubTmpl},
+		HandlerTemplate{},
+		SubTierTemplates{})
 
 	// This must happen after Handler registration, else data race occurs
 	bot.Start()
@@ -48,7 +53,9 @@ func TestGiftSubHandler(t *testing.T) {
 	// Initialize Handler
 	bot.RegisterSubsHandler(
 		HandlerTemplate{template: subsTmpl},
-		HandlerTemplate{template: giftSubTmpl})
+		HandlerTemplate{template: giftSubTmpl},
+		HandlerTemplate{},
+		SubTierTemplates{})
 
 	// This must happen after Handler registration, else data race occurs
 	bot.Start()
@@ -76,7 +83,9 @@ func TestSubHandlerIgnoresInvalidEvents(t *testing.T) {
 	// Initialize Handler
 	bot.RegisterSubsHandler(
 		HandlerTemplate{template: subsTmpl},
-		HandlerTemplate{template: giftSubTmpl})
+		HandlerTemplate{template: giftSubTmpl},
+		HandlerTemplate{},
+		SubTierTemplates{})
 
 	// This must happen after Handler registration, else data race occurs
 	bot.Start()
@@ -93,3 +102,97 @@ func TestSubHandlerIgnoresInvalidEvents(t *testing.T) {
 		// If we don't receive a response, the Bot didn't erroneously parse the wrong message
 	}
 }
+
+func TestSubHandlerTierTemplates(t *testing.T) {
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+
+	prime, _ := ParseHandlerTemplate(TemplateSubsPrime, "{{.Sender}} used their Prime sub!")
+	tier3, _ := ParseHandlerTemplate(TemplateSubsTier3, "{{.Sender}} went tier {{.Tier}} for {{.Streak}} months in a row: {{.Message}}")
+	giftTier2, _ := ParseHandlerTemplate(TemplateGiftSubsTier2, "{{.Sender}} gifted {{.GiftMonths}} months of tier 2 to {{.Recipient}}!")
+	bot.RegisterSubsHandler(
+		HandlerTemplate{template: subsTmpl},
+		HandlerTemplate{template: giftSubTmpl},
+		HandlerTemplate{},
+		SubTierTemplates{Prime: prime, Tier3: tier3, GiftTier2: giftTier2})
+	bot.Start()
+
+	tests := []struct {
+		description string
+		evt         Event
+		expected    string
+	}{
+		{"Prime sub", Event{Type: SUB, Sender: "a", Amount: 1, Tier: 1, Prime: true}, "a used their Prime sub!"},
+		{"Tier 3 resub", Event{Type: SUB, Sender: "b", Amount: 9, Tier: 3, Streak: 4, Message: "100% worth it"}, "b went tier 3 for 4 months in a row: 100% worth it"},
+		{"Tier 2 sub uses the usual message", Event{Type: SUB, Sender: "c", Amount: 2, Tier: 2}, "c subbed for 2 months!"},
+		{"Tier 2 gift sub", Event{Type: GIFTSUB, Sender: "d", Recipient: "e", Tier: 2, GiftMonths: 3}, "d gifted 3 months of tier 2 to e!"},
+		{"Tier 3 gift sub uses the usual message", Event{Type: GIFTSUB, Sender: "f", Recipient: "g", Tier: 3, GiftMonths: 1}, "f gifted a sub to g!"},
+	}
+
+	for _, test := range tests {
+		bot.events <- test.evt
+
+		response := <-checker.events
+		if response.Message != test.expected {
+			t.Errorf("%s: expected [%s], got [%s]", test.description, test.expected, response.Message)
+		}
+	}
+}
+
+func TestGiftBombSendsOneMessage(t *testing.T) {
+	cache, _ := cache.InMemory(0)
+	bot := New(&cache)
+	checker := NewTestChatClient()
+	bot.SetChatClient(checker)
+
+	bombTmpl, _ := ParseHandlerTemplate(TemplateGiftBombs, "{{.Sender}} gifted {{.Amount}} subs!")
+	bot.RegisterSubsHandler(
+		HandlerTemplate{template: subsTmpl},
+		HandlerTemplate{template: giftSubTmpl},
+		bombTmpl,
+		SubTierTemplates{})
+	bot.Start()
+
+	bomb := NewMysteryGiftEvent()
+	bomb.Sender = "BlackMarvel"
+	bomb.Amount = 3
+	bomb.GiftOrigin = "bomb1"
+	bot.events <- bomb
+
+	for _, recipient := range []string{"nojoy", "saltymoth", "Fjoell"} {
+		evt := NewGiftSubEvent()
+		evt.Sender = "BlackMarvel"
+		evt.Recipient = recipient
+		evt.GiftOrigin = "bomb1"
+		bot.events <- evt
+	}
+
+	// A gift sub outside of the bomb is still thanked on its own
+	single := NewGiftSubEvent()
+	single.Sender = "ReallyFrank"
+	single.Recipient = "nojoy"
+	bot.events <- single
+
+	expectMessages(t, checker, "BlackMarvel gifted 3 subs!", "ReallyFrank gifted a sub to nojoy!")
+
+	select {
+	case resp := <-checker.events:
+		t.Fatalf("Expected a single message for the gift bomb, got extra: %+v", resp)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Each gift sub of the bomb is still recorded, the last one being the single gift sub
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		metric, _ := bot.dataStore.GetOrDefault(viewer.LastGiftSub, "")
+		if strings.Contains(metric, "ReallyFrank") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the last gift sub to be recorded, got: %s", metric)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
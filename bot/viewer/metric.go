@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"strings"
+	"time"
 )
 
 const (
@@ -22,9 +23,10 @@ const (
 
 // Metric represents a metric value tied to a Viewer
 type Metric struct {
-	Name      string `json:"name"`
-	Recipient string `json:"recipient,omitempty"`
-	Amount    int    `json:"amount"`
+	Name      string    `json:"name"`
+	Recipient string    `json:"recipient,omitempty"`
+	Amount    int       `json:"amount"`
+	Time      time.Time `json:"time"`
 }
 
 // String representation of a Metric
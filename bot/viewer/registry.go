@@ -0,0 +1,57 @@
+package viewer
+
+import "sync"
+
+// Definition describes a Metric served by the API and overlays
+type Definition struct {
+	Key   string `json:"key"`   // Cache key the Metric is stored at
+	Label string `json:"label"` // Shown before the Metric on overlays
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = []Definition{
+		{Key: LastSub, Label: "Last Sub"},
+		{Key: LastGiftSub, Label: "Last Gifter"},
+		{Key: LastBits, Label: "Last Bits"},
+		{Key: LastRaider, Label: "Last Raider"},
+	}
+)
+
+// Register adds a Metric so it is served by the API and overlays.
+// Registering a known key replaces its Label
+func Register(key, label string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for idx, def := range registry {
+		if def.Key == key {
+			registry[idx].Label = label
+			return
+		}
+	}
+
+	registry = append(registry, Definition{Key: key, Label: label})
+}
+
+// Registered returns every registered Metric, in registration order
+func Registered() []Definition {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return append([]Definition{}, registry...)
+}
+
+// Lookup returns the registered Metric with the given key
+func Lookup(key string) (Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, def := range registry {
+		if def.Key == key {
+			return def, true
+		}
+	}
+
+	return Definition{}, false
+}
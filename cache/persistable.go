@@ -4,17 +4,39 @@ import (
 	"fmt"
 	"io"
 	log "medgebot/logger"
+	"medgebot/telemetry"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+var (
+	cacheEntries = telemetry.NewGauge("medgebot_cache_entries",
+		"Keys held by each cache", "cache")
+	cacheFlushDuration = telemetry.NewHistogram("medgebot_cache_flush_duration_seconds",
+		"Time taken to rewrite each cache's persistence file", "cache")
+)
+
+// flushInterval is how often persistent caches rewrite their persistence file
+const flushInterval = 10 * time.Second
+
 // PersistableCache is a in-memory cache backed by a FS file, if persistent.
 // Key expiration is enabled by setting an expiration > 0. Disabled if <= 0
 type PersistableCache struct {
+	// Guards cache. A pointer so copies of the PersistableCache share it
+	mu *sync.Mutex
+
+	// name the cache is reported under in telemetry
+	name string
+
+	// Outcome of the last flush. A pointer so copies of the PersistableCache share it
+	flush *flushState
+
 	cache          map[string]Entry
 	persistent     bool
 	persistTarget  *os.File
@@ -23,6 +45,13 @@ type PersistableCache struct {
 	expiration     int64
 }
 
+// flushState records the outcome of the last flushCache, for Healthy
+type flushState struct {
+	mu  sync.Mutex
+	at  time.Time
+	err error
+}
+
 // Entry represents entries in the cache
 type Entry struct {
 	// key is duplicated in the entry for rehydration purposes
@@ -50,7 +79,15 @@ func createCache(file *os.File, keyExpirationSeconds int64) (PersistableCache, e
 	fieldSeparator := "|"
 
 	// To ensure we remove stale data, we rewrite state to the cache persistence target
+	name := "memory"
+	if file != nil {
+		name = filepath.Base(file.Name())
+	}
+
 	pc := PersistableCache{
+		mu:             &sync.Mutex{},
+		name:           name,
+		flush:          &flushState{},
 		persistTarget:  file,
 		persistent:     (file != nil),
 		cache:          cache,
@@ -69,7 +106,7 @@ func createCache(file *os.File, keyExpirationSeconds int64) (PersistableCache, e
 		go func(cache *PersistableCache) {
 			for {
 				select {
-				case <-time.After(10 * time.Second):
+				case <-time.After(flushInterval):
 					cache.flushCache()
 				}
 			}
@@ -81,7 +118,10 @@ func createCache(file *os.File, keyExpirationSeconds int64) (PersistableCache, e
 
 // Get the value at the given key. Returns error if key not found
 func (cache *PersistableCache) Get(key string) (string, error) {
-	if cache.Absent(key) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.absent(key) {
 		return "", errors.Errorf("Key not found: %s", key)
 	}
 
@@ -92,11 +132,19 @@ func (cache *PersistableCache) Get(key string) (string, error) {
 // GetOrDefault returns the value at the given key.
 // If key not present, PUT the defaultValue and return
 func (cache *PersistableCache) GetOrDefault(key string, defaultValue string) (string, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.getOrDefault(key, defaultValue)
+}
+
+// getOrDefault is GetOrDefault for callers holding the lock
+func (cache *PersistableCache) getOrDefault(key string, defaultValue string) (string, error) {
 	entry, ok := cache.cache[key]
 
 	// If key doesn't exist, PUT the defaultValue first
 	if !ok {
-		err := cache.Put(key, defaultValue)
+		err := cache.put(key, defaultValue)
 		return defaultValue, err
 	}
 
@@ -106,6 +154,14 @@ func (cache *PersistableCache) GetOrDefault(key string, defaultValue string) (st
 // Put the given key/value. If already present, the timestamp will
 // be updated
 func (cache *PersistableCache) Put(key, value string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.put(key, value)
+}
+
+// put is Put for callers holding the lock
+func (cache *PersistableCache) put(key, value string) error {
 	ts := time.Now().Unix()
 	entry := Entry{
 		value:     value,
@@ -114,6 +170,7 @@ func (cache *PersistableCache) Put(key, value string) error {
 
 	// Add to cache and write to persistence immediately
 	cache.cache[key] = entry
+	cacheEntries.Set(float64(len(cache.cache)), cache.name)
 
 	// TODO this isn't failing when the File disappears?
 	if cache.persistent {
@@ -130,32 +187,46 @@ func (cache *PersistableCache) Put(key, value string) error {
 // Append appends the given value to an existing key value. If not present, it delegates to
 // a simple cache.Put()
 func (cache *PersistableCache) Append(key, separator, value string) error {
-	entry, err := cache.GetOrDefault(key, "")
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, err := cache.getOrDefault(key, "")
 	if err != nil {
 		return errors.Wrap(err, "Get key")
 	}
 
 	if entry == "" {
-		return cache.Put(key, value)
+		return cache.put(key, value)
 	}
 
-	return cache.Put(key, fmt.Sprintf("%s%s%s", entry, separator, value))
+	return cache.put(key, fmt.Sprintf("%s%s%s", entry, separator, value))
 }
 
 // Absent is true if the key is either not present or expired
 func (cache *PersistableCache) Absent(key string) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.absent(key)
+}
+
+// absent is Absent for callers holding the lock
+func (cache *PersistableCache) absent(key string) bool {
 	entry, ok := cache.cache[key]
 	return !ok || cache.expired(entry.timestamp)
 }
 
 // Clear is a helper function to clear out a given key, if present
 func (cache *PersistableCache) Clear(key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
 	_, ok := cache.cache[key]
 	if !ok {
 		return
 	}
 
-	cache.Put(key, "")
+	cache.put(key, "")
 }
 
 // expired checks if the given key's timestamp is beyond the expiration threshold.
@@ -218,25 +289,23 @@ func (cache *PersistableCache) sanitize(value string) string {
 	return strings.ReplaceAll(value, "\n", " ")
 }
 
-// fromLine extracts an Entry from a cache persistence line
+// fromLine extracts an Entry from a cache persistence line.
+// The key is everything before the first separator and the timestamp everything
+// after the last, so values may contain the separator
 func (cache *PersistableCache) fromLine(line string) (Entry, error) {
-	// Constants regarding line composition
-	entryLineSize := 3
-	keyIdx := 0
-	valueIdx := 1
-	tsIdx := 2
+	keyEnd := strings.Index(line, cache.fieldSeparator)
+	tsStart := strings.LastIndex(line, cache.fieldSeparator)
 
-	tokens := strings.Split(line, cache.fieldSeparator)
-
-	if len(tokens) != entryLineSize {
-		return Entry{}, errors.Errorf("Invalid line length: %d", len(tokens))
+	if keyEnd < 0 || keyEnd == tsStart {
+		return Entry{}, errors.Errorf("Invalid line: missing fields")
 	}
 
-	key := tokens[keyIdx]
-	value := tokens[valueIdx]
-	ts, err := strconv.ParseInt(tokens[tsIdx], 10, 64)
+	key := line[:keyEnd]
+	value := line[keyEnd+len(cache.fieldSeparator) : tsStart]
+	tsStr := line[tsStart+len(cache.fieldSeparator):]
+	ts, err := strconv.ParseInt(tsStr, 10, 64)
 	if err != nil {
-		return Entry{}, errors.Errorf("invalid timestamp for key %s - %s", key, tokens[tsIdx])
+		return Entry{}, errors.Errorf("invalid timestamp for key %s - %s", key, tsStr)
 	}
 
 	return Entry{
@@ -253,10 +322,18 @@ func (cache *PersistableCache) flushCache() {
 		return
 	}
 
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	start := time.Now()
+	defer cacheFlushDuration.ObserveSince(start, cache.name)
+	cacheEntries.Set(float64(len(cache.cache)), cache.name)
+
 	// Truncate(0) clears the file contents
-	err := cache.persistTarget.Truncate(0)
-	if err != nil {
-		log.Error(err, "cache flush - truncate")
+	flushErr := cache.persistTarget.Truncate(0)
+	if flushErr != nil {
+		log.Error(flushErr, "cache flush - truncate")
+		flushErr = errors.Wrap(flushErr, "truncate")
 	}
 
 	for key, val := range cache.cache {
@@ -264,6 +341,33 @@ func (cache *PersistableCache) flushCache() {
 		_, err := cache.persistTarget.Write([]byte(line))
 		if err != nil {
 			log.Error(err, "cache flush line - %s", line)
+			flushErr = errors.Wrap(err, "write "+key)
 		}
 	}
+
+	cache.flush.mu.Lock()
+	cache.flush.at = start
+	cache.flush.err = flushErr
+	cache.flush.mu.Unlock()
+}
+
+// Healthy returns an error if the last flush to the persistence file failed, or if
+// flushes stopped happening. In memory caches are always healthy
+func (cache *PersistableCache) Healthy() error {
+	if !cache.persistent {
+		return nil
+	}
+
+	cache.flush.mu.Lock()
+	defer cache.flush.mu.Unlock()
+
+	if cache.flush.err != nil {
+		return errors.Wrapf(cache.flush.err, "cache %s flush failed", cache.name)
+	}
+
+	if since := time.Since(cache.flush.at); since > 3*flushInterval {
+		return errors.Errorf("cache %s last flushed %s ago", cache.name, since.Round(time.Second))
+	}
+
+	return nil
 }
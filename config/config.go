@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
@@ -13,6 +15,9 @@ import (
 type Config struct {
 	channel string
 	config  *viper.Viper
+
+	// Guards runtime changes made through the setters
+	mu *sync.Mutex
 }
 
 // Initialize configuration and read from config.yaml
@@ -32,6 +37,7 @@ func New(channel string, configPath string) (Config, error) {
 	return Config{
 		channel: channel,
 		config:  conf,
+		mu:      &sync.Mutex{},
 	}, nil
 }
 
@@ -65,6 +71,32 @@ func (c *Config) TwitchToken() string {
 	return os.Getenv("TWITCH_TOKEN")
 }
 
+// ServerBaseURL returns the externally reachable base URL of the HTTP server, used
+// to link overlays to the API. Empty means derive it from each request
+func (c *Config) ServerBaseURL() string {
+	baseURL := c.config.GetString(c.key("server.baseUrl"))
+	return baseURL
+}
+
+// OverlayDir returns the directory overlay themes are read from. Empty means the
+// embedded overlays are used as is
+func (c *Config) OverlayDir() string {
+	dir := c.config.GetString(c.key("server.overlayDir"))
+	return dir
+}
+
+// APITokens if Store type is ENV. Comma-separated name:token:scope1|scope2 entries
+func (c *Config) APITokens() string {
+	return os.Getenv("API_TOKENS")
+}
+
+// OverlayAuthRequired checks if overlay routes require a token with the overlay-read scope.
+// Overlays are public by default
+func (c *Config) OverlayAuthRequired() bool {
+	flagValue := c.config.GetBool(c.key("server.overlayAuth"))
+	return flagValue
+}
+
 // Feature Flags - built as opt-in
 
 // GreeterEnabled checks the Greeter feature flag
@@ -79,12 +111,56 @@ func (c *Config) CacheExpirationTime() int64 {
 	return value
 }
 
+// GreetedExpirationTime returns how many seconds a greeted viewer is remembered, so their next
+// greeting uses the returning message. Defaults to 30 days
+func (c *Config) GreetedExpirationTime() int64 {
+	value := c.config.GetInt64(c.key("greeter.returning.expirationTime"))
+	if value <= 0 {
+		return 30 * 24 * 60 * 60
+	}
+
+	return value
+}
+
 // GreetMessageFormat returns the text/template formatted String for Greet messages
 func (c *Config) GreetMessageFormat() string {
 	msgFormat := c.config.GetString(c.key("greeter.messageFormat"))
 	return msgFormat
 }
 
+// GreetReturningMessageFormat returns the text/template formatted String for greeting viewers
+// greeted before, once their greeting expired. Empty means the usual greeting
+func (c *Config) GreetReturningMessageFormat() string {
+	msgFormat := c.config.GetString(c.key("greeter.returning.messageFormat"))
+	return msgFormat
+}
+
+// GreetFirstMessageFormat returns the text/template formatted String for greeting chatters
+// sending their first ever message in the channel. Empty means the usual greeting
+func (c *Config) GreetFirstMessageFormat() string {
+	msgFormat := c.config.GetString(c.key("greeter.firstMessage.messageFormat"))
+	return msgFormat
+}
+
+// GreetingWindow returns how long greetings are collected before being sent together.
+// 0 means the Bot's default
+func (c *Config) GreetingWindow() time.Duration {
+	seconds := c.config.GetInt(c.key("greeter.batch.windowSeconds"))
+	return time.Duration(seconds) * time.Second
+}
+
+// GreetingMaxPerMessage returns the most viewers greeted in one message. 0 means the Bot's default
+func (c *Config) GreetingMaxPerMessage() int {
+	max := c.config.GetInt(c.key("greeter.batch.maxPerMessage"))
+	return max
+}
+
+// GreeterIgnore returns the usernames the Greeter never greets, ex: other bots
+func (c *Config) GreeterIgnore() []string {
+	ignored := c.config.GetStringSlice(c.key("greeter.ignore"))
+	return ignored
+}
+
 // ChannelPointsEnabled checks the Commands feature flag
 func (c *Config) ChannelPointsEnabled() bool {
 	flagValue := c.config.GetBool(c.key("channelPoints.enabled"))
@@ -130,6 +206,38 @@ func (c *Config) RaidsMessageFormat() string {
 	return msgFormat
 }
 
+// RaidTier replaces the raid message for raids of at least MinViewers
+type RaidTier struct {
+	MinViewers    int    `mapstructure:"minViewers"`
+	MessageFormat string `mapstructure:"messageFormat"`
+}
+
+// RaidTiers returns the raid messages for larger raids
+func (c *Config) RaidTiers() []RaidTier {
+	var tiers []RaidTier
+	c.config.UnmarshalKey(c.key("raids.tiers"), &tiers)
+	return tiers
+}
+
+// RaidShoutout checks if raiders are shouted out after the raid message
+func (c *Config) RaidShoutout() bool {
+	flagValue := c.config.GetBool(c.key("raids.shoutout"))
+	return flagValue
+}
+
+// RaidFollowUpDelay returns the delay in seconds between a Raid and the follow-up message
+func (c *Config) RaidFollowUpDelay() int {
+	delay := c.config.GetInt(c.key("raids.followUp.delaySeconds"))
+	return delay
+}
+
+// RaidFollowUpMessageFormat returns the text/template formatted String for the message
+// greeting raiders after the follow-up delay. Empty sends no follow-up
+func (c *Config) RaidFollowUpMessageFormat() string {
+	msgFormat := c.config.GetString(c.key("raids.followUp.messageFormat"))
+	return msgFormat
+}
+
 // BitsEnabled checks the Bits feature flag
 func (c *Config) BitsEnabled() bool {
 	flagValue := c.config.GetBool(c.key("bits.enabled"))
@@ -142,6 +250,19 @@ func (c *Config) BitsMessageFormat() string {
 	return msgFormat
 }
 
+// BitsTier replaces the bits message for cheers of at least MinBits
+type BitsTier struct {
+	MinBits       int    `mapstructure:"minBits"`
+	MessageFormat string `mapstructure:"messageFormat"`
+}
+
+// BitsTiers returns the bits messages for larger cheers
+func (c *Config) BitsTiers() []BitsTier {
+	var tiers []BitsTier
+	c.config.UnmarshalKey(c.key("bits.tiers"), &tiers)
+	return tiers
+}
+
 // SubsEnabled checks the Subs feature flag
 func (c *Config) SubsEnabled() bool {
 	flagValue := c.config.GetBool(c.key("subs.enabled"))
@@ -154,18 +275,204 @@ func (c *Config) SubsMessageFormat() string {
 	return msgFormat
 }
 
+// SubsTierMessageFormat returns the text/template formatted String for Subs messages of the
+// tier: prime, tier2 or tier3. Empty uses the Subs message
+func (c *Config) SubsTierMessageFormat(tier string) string {
+	msgFormat := c.config.GetString(c.key("subs." + tier + ".messageFormat"))
+	return msgFormat
+}
+
 // GiftSubsMessageFormat returns the text/template formatted String for Gift Subs messages
 func (c *Config) GiftSubsMessageFormat() string {
 	msgFormat := c.config.GetString(c.key("giftsubs.messageFormat"))
 	return msgFormat
 }
 
+// GiftBombsMessageFormat returns the text/template formatted String thanking a community gift
+// of several subs once. Empty thanks each gift sub instead
+func (c *Config) GiftBombsMessageFormat() string {
+	msgFormat := c.config.GetString(c.key("giftsubs.bomb.messageFormat"))
+	return msgFormat
+}
+
+// GiftSubsTierMessageFormat returns the text/template formatted String for Gift Subs messages
+// of the tier: tier2 or tier3. Empty uses the Gift Subs message
+func (c *Config) GiftSubsTierMessageFormat(tier string) string {
+	msgFormat := c.config.GetString(c.key("giftsubs." + tier + ".messageFormat"))
+	return msgFormat
+}
+
 // PollsEnabled checks the Subs feature flag
 func (c *Config) PollsEnabled() bool {
 	flagValue := c.config.GetBool(c.key("polls.enabled"))
 	return flagValue
 }
 
+// AlertsEnabled checks the Alerts feature flag
+func (c *Config) AlertsEnabled() bool {
+	flagValue := c.config.GetBool(c.key("alerts.enabled"))
+	return flagValue
+}
+
+// AlertConfig describes how the alert overlay renders one type of event
+type AlertConfig struct {
+	MessageFormat   string `mapstructure:"messageFormat"`
+	CSS             string `mapstructure:"css"`
+	Sound           string `mapstructure:"sound"`
+	DurationSeconds int    `mapstructure:"durationSeconds"`
+}
+
+// Alerts returns the alert overlay config, keyed by event type name (sub, giftsub, bits, raid, channelPoints)
+func (c *Config) Alerts() map[string]AlertConfig {
+	alerts := make(map[string]AlertConfig)
+	c.config.UnmarshalKey(c.key("alerts.types"), &alerts)
+	return alerts
+}
+
+// GoalsEnabled checks the Goals feature flag
+func (c *Config) GoalsEnabled() bool {
+	flagValue := c.config.GetBool(c.key("goals.enabled"))
+	return flagValue
+}
+
+// GoalsMessageFormat returns the text/template formatted String for Goal milestone announcements
+func (c *Config) GoalsMessageFormat() string {
+	val := c.config.GetString(c.key("goals.messageFormat"))
+	return val
+}
+
+// HypeEnabled checks the hype detector feature flag
+func (c *Config) HypeEnabled() bool {
+	flagValue := c.config.GetBool(c.key("hype.enabled"))
+	return flagValue
+}
+
+// HypeMessageFormat returns the text/template formatted String for hype level announcements
+func (c *Config) HypeMessageFormat() string {
+	msgFormat := c.config.GetString(c.key("hype.messageFormat"))
+	return msgFormat
+}
+
+// HypeWindow returns how long support counts towards hype. 0 means the Bot's default
+func (c *Config) HypeWindow() time.Duration {
+	seconds := c.config.GetInt(c.key("hype.windowSeconds"))
+	return time.Duration(seconds) * time.Second
+}
+
+// HypeLevels returns the points needed for each hype level. Empty means the Bot's default
+func (c *Config) HypeLevels() []int {
+	levels := c.config.GetIntSlice(c.key("hype.levels"))
+	return levels
+}
+
+// HypeSubPoints returns the hype points of a tier 1 sub. 0 means the Bot's default
+func (c *Config) HypeSubPoints() int {
+	points := c.config.GetInt(c.key("hype.subPoints"))
+	return points
+}
+
+// GoalConfig describes a sub or bits goal
+type GoalConfig struct {
+	Name       string `mapstructure:"name"`
+	Type       string `mapstructure:"type"`
+	Title      string `mapstructure:"title"`
+	Target     int    `mapstructure:"target"`
+	Milestones []int  `mapstructure:"milestones"`
+}
+
+// Goals returns the configured sub and bits goals
+func (c *Config) Goals() []GoalConfig {
+	var goals []GoalConfig
+	c.config.UnmarshalKey(c.key("goals.list"), &goals)
+	return goals
+}
+
+// HistorySize returns the number of events kept in the event history. 0 if not set
+func (c *Config) HistorySize() int {
+	val := c.config.GetInt(c.key("history.size"))
+	return val
+}
+
+// HealthMaxSilence returns how long IRC and PubSub may go without receiving a message
+// before /readyz fails. Defaults to 10 minutes
+func (c *Config) HealthMaxSilence() time.Duration {
+	seconds := c.config.GetInt(c.key("health.maxSilenceSeconds"))
+	if seconds <= 0 {
+		return 10 * time.Minute
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// HealthHandlerTimeout returns how long a handler may take on a single event before
+// /healthz fails. Defaults to 1 minute
+func (c *Config) HealthHandlerTimeout() time.Duration {
+	seconds := c.config.GetInt(c.key("health.handlerTimeoutSeconds"))
+	if seconds <= 0 {
+		return time.Minute
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// Runtime changes - persisted to config.yaml with Save()
+
+// SetFeatureEnabled sets the enabled flag for the given feature section
+func (c *Config) SetFeatureEnabled(feature string, enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config.Set(c.key(feature+".enabled"), enabled)
+}
+
+// SetMessageFormat sets the text/template formatted String for the given feature section
+func (c *Config) SetMessageFormat(feature, messageFormat string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config.Set(c.key(feature+".messageFormat"), messageFormat)
+}
+
+// SetKnownCommands replaces the commands the Bot knows how to respond to
+func (c *Config) SetKnownCommands(commands []KnownCommand) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Stored as maps so the written config.yaml uses the same keys as a hand-written one
+	known := make([]map[string]string, 0, len(commands))
+	for _, cmd := range commands {
+		entry := map[string]string{"prefix": cmd.Prefix}
+		if cmd.AliasFor != "" {
+			entry["aliasFor"] = cmd.AliasFor
+		} else {
+			entry["message"] = cmd.Message
+		}
+		known = append(known, entry)
+	}
+
+	c.config.Set(c.key("commands.known"), known)
+}
+
+// Settings returns the current configuration for the channel
+func (c *Config) Settings() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.config.GetStringMap(c.channel)
+}
+
+// Save writes the current configuration, including runtime changes, back to config.yaml
+func (c *Config) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.config.WriteConfig(); err != nil {
+		return errors.Wrap(err, "write config.yaml")
+	}
+
+	return nil
+}
+
 // key constructs valid channel-based config keys for Viper lookups
 func (c *Config) key(path string) string {
 	return fmt.Sprintf("%s.%s", c.channel, path)
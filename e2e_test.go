@@ -9,6 +9,7 @@ import (
 	"medgebot/irc/irctest"
 	"medgebot/ws/wstest"
 	"testing"
+	"time"
 )
 
 const (
@@ -35,7 +36,7 @@ func TestRaids(t *testing.T) {
 	chatBot.SetChatClient(ircClient)
 
 	raidTmpl := bot.NewHandlerTemplate(bottest.MakeTemplate("raids", "{{.Sender}} raid of {{.Amount}}"))
-	chatBot.RegisterRaidHandler(raidTmpl, 1)
+	chatBot.RegisterRaidHandler(raidTmpl, bot.RaidOptions{Delay: time.Second})
 
 	// We must Start the bot AFTER the handler is registered
 	chatBot.Start()
@@ -43,7 +44,7 @@ func TestRaids(t *testing.T) {
 	// We should see "USER raid of RAID_SIZE" eventually come through the IRC client
 	raidSize := 5
 	expectedMessage := fmt.Sprintf("PRIVMSG #medgelabs :%s raid of %d", USER, raidSize)
-	ws.SendAndWait(irctest.MakeRaidMessage(USER, raidSize, CHANNEL))
+	ws.SendAndWait(irctest.MakeRaidMessage(USER, raidSize, CHANNEL) + "\r\n")
 
 	if !ws.Received(expectedMessage) {
 		t.Fatalf("Did not see expected Raid Message.\nWS Dump:\n%s", ws.String())
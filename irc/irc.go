@@ -5,23 +5,37 @@ import (
 	"io"
 	"medgebot/bot"
 	log "medgebot/logger"
+	"medgebot/telemetry"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
-const (
-	// MaxMessageSize defines the maximum size of an IRC message that can be received.
-	// This is used to size the message buffer slice for the io.Read method
-	MaxMessageSize = 1024 // bytes
+var (
+	ircConnects = telemetry.NewCounter("medgebot_irc_connects_total",
+		"Times the IRC client started, including after reconnects")
+	ircMessages = telemetry.NewCounter("medgebot_irc_messages_received_total",
+		"Messages received from IRC, by command", "command")
 )
 
 // Irc client
 type Irc struct {
 	sync.Mutex
 	conn           io.ReadWriteCloser
+	lines          *LineReader
 	inboundEvents  chan bot.Event
 	outboundEvents chan<- bot.Event
+
+	// Connection state reported by Healthy and Ready. Reset by Start
+	stateMu       sync.Mutex
+	channel       string
+	authenticated bool
+	joined        bool
+	authErr       error
+	readErr       error
+	lastMessage   time.Time
 }
 
 type Config struct {
@@ -33,11 +47,22 @@ type Config struct {
 func NewClient(conn io.ReadWriteCloser) *Irc {
 	return &Irc{
 		conn:          conn,
+		lines:         NewLineReader(conn),
 		inboundEvents: make(chan bot.Event),
 	}
 }
 
 func (irc *Irc) Start(config Config) error {
+	ircConnects.Inc()
+
+	irc.stateMu.Lock()
+	irc.channel = strings.TrimPrefix(config.Channel, "#")
+	irc.authenticated = false
+	irc.joined = false
+	irc.authErr = nil
+	irc.readErr = nil
+	irc.stateMu.Unlock()
+
 	if err := irc.Authenticate(config.Nick, config.Password); err != nil {
 		return errors.Errorf("FATAL: irc authentication failure - %s", err)
 	}
@@ -61,6 +86,9 @@ func (irc *Irc) Start(config Config) error {
 		for {
 			if err := irc.read(); err != nil {
 				log.Error(err, "irc read")
+				irc.stateMu.Lock()
+				irc.readErr = err
+				irc.stateMu.Unlock()
 				break
 			}
 		}
@@ -161,24 +189,21 @@ func (irc *Irc) sendNick(nick string) error {
 
 // Read reads from the IRC stream, one line at a time
 func (irc *Irc) read() error {
-	buff := make([]byte, MaxMessageSize)
-	len, err := irc.conn.Read(buff)
+	str, err := irc.lines.ReadLine()
 	if err != nil {
 		return errors.Wrap(err, "ERROR: read irc")
 	}
 
-	if len == 0 {
-		log.Warn("Empty message buffer")
-		return errors.New("Empty message buffer")
-	}
-
-	// Remove NULL bytes (bytes left over in the buffer), courtesy of ReallyFrank!
-	buff = buff[:len]
-	str := string(buff)
-
 	// trace inbound IRC message
 	log.Info(str)
-	msg := parseIrcLine(str)
+	msg, err := parseIrcLine(str)
+	if err != nil {
+		// One bad line shouldn't stop the read loop
+		log.Error(err, "parse irc line")
+		return nil
+	}
+	ircMessages.Inc(msg.Command)
+	irc.trackState(msg)
 
 	// Intercept for PING/PONG
 	if msg.Command == "PING" {
@@ -195,11 +220,15 @@ func (irc *Irc) read() error {
 			evt := bot.NewBitsEvent()
 			evt.Sender = msg.BitsSender()
 			evt.Amount = msg.BitsAmount()
+			evt.Message = msg.CheerMessage()
+			evt.Anonymous = msg.IsAnonymous()
 			irc.sendEvent(evt)
 		} else {
 			evt := bot.NewChatEvent()
 			evt.Sender = msg.User
 			evt.Message = msg.Contents
+			evt.Moderator = msg.IsModerator()
+			evt.FirstMessage = msg.IsFirstMessage()
 			irc.sendEvent(evt)
 		}
 
@@ -215,11 +244,57 @@ func (irc *Irc) read() error {
 			evt := bot.NewSubEvent()
 			evt.Sender = msg.Subscriber()
 			evt.Amount = msg.SubMonths()
+			evt.Tier = msg.SubTier()
+			evt.Prime = msg.IsPrimeSub()
+			evt.Streak = msg.SubStreak()
+			evt.Message = msg.Contents
 			irc.sendEvent(evt)
 		case msg.IsGiftSubscriptionMessage():
 			evt := bot.NewGiftSubEvent()
 			evt.Sender = msg.GiftSender()
 			evt.Recipient = msg.GiftRecipient()
+			evt.Anonymous = msg.IsAnonymous()
+			evt.Tier = msg.SubTier()
+			evt.GiftMonths = msg.GiftMonths()
+			evt.GiftOrigin = msg.GiftOrigin()
+			irc.sendEvent(evt)
+		case msg.IsMysteryGiftMessage():
+			evt := bot.NewMysteryGiftEvent()
+			evt.Sender = msg.GiftSender()
+			evt.Amount = msg.MysteryGiftCount()
+			evt.Anonymous = msg.IsAnonymous()
+			evt.Tier = msg.SubTier()
+			evt.GiftOrigin = msg.GiftOrigin()
+			irc.sendEvent(evt)
+		case msg.IsGiftUpgradeMessage():
+			evt := bot.NewSubUpgradeEvent()
+			evt.Sender = msg.User
+			evt.Recipient = msg.UpgradeGifter()
+			evt.Anonymous = msg.IsAnonymous()
+			irc.sendEvent(evt)
+		case msg.IsPrimeUpgradeMessage():
+			evt := bot.NewSubUpgradeEvent()
+			evt.Sender = msg.User
+			irc.sendEvent(evt)
+		case msg.IsBitsBadgeMessage():
+			evt := bot.NewBitsBadgeEvent()
+			evt.Sender = msg.User
+			evt.Amount = msg.BitsBadgeTier()
+			irc.sendEvent(evt)
+		case msg.IsAnnouncementMessage():
+			evt := bot.NewAnnouncementEvent()
+			evt.Sender = msg.User
+			evt.Message = msg.Contents
+			irc.sendEvent(evt)
+		case msg.IsUnraidMessage():
+			evt := bot.NewUnraidEvent()
+			evt.Sender = msg.User
+			irc.sendEvent(evt)
+		case msg.IsRitualMessage():
+			evt := bot.NewRitualEvent()
+			evt.Sender = msg.User
+			evt.Title = msg.RitualName()
+			evt.Message = msg.Contents
 			irc.sendEvent(evt)
 		default:
 			log.Warn("Unknown USERNOTICE: " + msg.String())
@@ -232,9 +307,79 @@ func (irc *Irc) read() error {
 	return nil
 }
 
+// trackState updates the connection state from a received message
+func (irc *Irc) trackState(msg Message) {
+	irc.stateMu.Lock()
+	defer irc.stateMu.Unlock()
+
+	irc.lastMessage = time.Now()
+
+	switch msg.Command {
+	// RPL_WELCOME is only sent once the PASS and NICK are accepted
+	case "001":
+		irc.authenticated = true
+	case "JOIN", "ROOMSTATE":
+		if strings.EqualFold(msg.Channel, irc.channel) {
+			irc.joined = true
+		}
+	case "NOTICE":
+		if strings.Contains(msg.Contents, "authentication failed") ||
+			strings.Contains(msg.Contents, "Improperly formatted auth") {
+			irc.authErr = errors.New(msg.Contents)
+		}
+	case "CAP":
+		// Without tags or commands, most Events can't be parsed
+		if msg.IsCapNak() {
+			log.Warn("irc capabilities rejected: %s", strings.Join(msg.Capabilities(), " "))
+		}
+	}
+}
+
+// Healthy returns an error if authentication was rejected or the read loop stopped,
+// in which case no more messages will be received without a restart
+func (irc *Irc) Healthy() error {
+	irc.stateMu.Lock()
+	defer irc.stateMu.Unlock()
+
+	if irc.authErr != nil {
+		return errors.Wrap(irc.authErr, "irc authentication")
+	}
+
+	if irc.readErr != nil {
+		return errors.Wrap(irc.readErr, "irc read loop stopped")
+	}
+
+	return nil
+}
+
+// Ready returns an error unless the client is healthy, authenticated, joined to the
+// channel and has received a message within maxSilence. Twitch PINGs about every 5 minutes
+func (irc *Irc) Ready(maxSilence time.Duration) error {
+	if err := irc.Healthy(); err != nil {
+		return err
+	}
+
+	irc.stateMu.Lock()
+	defer irc.stateMu.Unlock()
+
+	if !irc.authenticated {
+		return errors.New("irc not authenticated")
+	}
+
+	if !irc.joined {
+		return errors.Errorf("irc not joined to #%s", irc.channel)
+	}
+
+	if silence := time.Since(irc.lastMessage); silence > maxSilence {
+		return errors.Errorf("irc last message %s ago", silence.Round(time.Second))
+	}
+
+	return nil
+}
+
 // Write a message to the IRC stream
 func (irc *Irc) write(message Message) error {
-	msgStr := fmt.Sprintf("%s %s", message.Command, message.Contents)
+	msgStr := fmt.Sprintf("%s %s\r\n", message.Command, message.Contents)
 
 	// Lock since WriteMessage requires only one concurrent execution
 	if _, err := irc.conn.Write([]byte(msgStr)); err != nil {
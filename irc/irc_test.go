@@ -1,9 +1,11 @@
 package irc
 
 import (
+	"errors"
 	"medgebot/bot"
 	"medgebot/irc/irctest"
 	"medgebot/ws/wstest"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -48,7 +50,7 @@ func TestMessageReceivedFromServer(t *testing.T) {
 	irc.SetDestination(testBot)
 	irc.Start(config)
 
-	conn.Send(irctest.MakeChatMessage("testuser", "Chat!", "medgelabs"))
+	conn.SendLine(irctest.MakeChatMessage("testuser", "Chat!", "medgelabs"))
 
 	// Wait for message on bot Event channel
 	select {
@@ -60,3 +62,179 @@ func TestMessageReceivedFromServer(t *testing.T) {
 		t.Fatalf("Failed to receive expected message")
 	}
 }
+
+func TestUserNoticeEvents(t *testing.T) {
+	conn := wstest.NewWebsocket()
+	irc := NewClient(conn)
+
+	testBot := make(chan bot.Event)
+	irc.SetDestination(testBot)
+	irc.Start(Config{
+		Nick:     "medgelabs",
+		Password: "oauth:secret",
+		Channel:  "#medgelabs",
+	})
+
+	bomb := irctest.MakeGiftBombMessages("ReallyFrank", []string{"Fjoell"}, "bomb1", "medgelabs")
+	tests := []struct {
+		description string
+		line        string
+		expected    bot.Event
+	}{
+		{
+			"Anonymous gift sub", irctest.MakeAnonGiftSubMessage("Fjoell", "medgelabs"),
+			bot.Event{Type: bot.GIFTSUB, Sender: bot.AnonymousGifter, Recipient: "Fjoell", Anonymous: true, Tier: 1, GiftMonths: 1},
+		},
+		{
+			"Tier 2 gift sub", irctest.MakeTierGiftSubMessage("ReallyFrank", "Fjoell", 3, "2000", "medgelabs"),
+			bot.Event{Type: bot.GIFTSUB, Sender: "ReallyFrank", Recipient: "Fjoell", Tier: 2, GiftMonths: 3},
+		},
+		{
+			"Prime sub", irctest.MakePrimeSubMessage("saltymoth", "medgelabs"),
+			bot.Event{Type: bot.SUB, Sender: "saltymoth", Amount: 1, Tier: 1, Prime: true},
+		},
+		{
+			"Tier 3 resub", irctest.MakeTierResubMessage("saltymoth", 10, 4, "3000", "Ten months!", "medgelabs"),
+			bot.Event{Type: bot.SUB, Sender: "saltymoth", Amount: 10, Tier: 3, Streak: 4, Message: "Ten months!"},
+		},
+		{
+			"Mystery gift", irctest.MakeMysteryGiftMessage("ReallyFrank", 5, "medgelabs"),
+			bot.Event{Type: bot.MYSTERY_GIFT, Sender: "ReallyFrank", Amount: 5, Tier: 1},
+		},
+		{
+			"Gift bomb", bomb[0],
+			bot.Event{Type: bot.MYSTERY_GIFT, Sender: "ReallyFrank", Amount: 1, Tier: 1, GiftOrigin: "bomb1"},
+		},
+		{
+			"Gift bomb gift sub", bomb[1],
+			bot.Event{Type: bot.GIFTSUB, Sender: "ReallyFrank", Recipient: "Fjoell", Tier: 1, GiftMonths: 1, GiftOrigin: "bomb1"},
+		},
+		{
+			"Cheer", irctest.MakeCheerMessage("BlackMarvel", 200, "Cheer100 two hundred", "medgelabs"),
+			bot.Event{Type: bot.BITS, Sender: "BlackMarvel", Amount: 200, Message: "two hundred"},
+		},
+		{
+			"Anonymous cheer", irctest.MakeAnonCheerMessage(100, "hi", "medgelabs"),
+			bot.Event{Type: bot.BITS, Sender: bot.AnonymousCheerer, Amount: 100, Message: "hi", Anonymous: true},
+		},
+		{
+			"Gift upgrade", irctest.MakeGiftPaidUpgradeMessage("saltymoth", "ReallyFrank", "medgelabs"),
+			bot.Event{Type: bot.SUB_UPGRADE, Sender: "saltymoth", Recipient: "ReallyFrank"},
+		},
+		{
+			"Anonymous gift upgrade", irctest.MakeAnonGiftPaidUpgradeMessage("saltymoth", "medgelabs"),
+			bot.Event{Type: bot.SUB_UPGRADE, Sender: "saltymoth", Anonymous: true},
+		},
+		{
+			"Prime upgrade", irctest.MakePrimePaidUpgradeMessage("saltymoth", "medgelabs"),
+			bot.Event{Type: bot.SUB_UPGRADE, Sender: "saltymoth"},
+		},
+		{
+			"Bits badge", irctest.MakeBitsBadgeTierMessage("BlackMarvel", 1000, "medgelabs"),
+			bot.Event{Type: bot.BITS_BADGE, Sender: "BlackMarvel", Amount: 1000},
+		},
+		{
+			"Announcement", irctest.MakeAnnouncementMessage("ReallyFrank", "Stream starting!", "medgelabs"),
+			bot.Event{Type: bot.ANNOUNCEMENT, Sender: "ReallyFrank", Message: "Stream starting!"},
+		},
+		{
+			"Unraid", irctest.MakeUnraidMessage("medgelabs", "medgelabs"),
+			bot.Event{Type: bot.UNRAID, Sender: "medgelabs"},
+		},
+		{
+			"Ritual", irctest.MakeRitualMessage("nojoy", "HeyGuys", "medgelabs"),
+			bot.Event{Type: bot.RITUAL, Sender: "nojoy", Title: "new_chatter", Message: "HeyGuys"},
+		},
+	}
+
+	for _, test := range tests {
+		conn.SendLine(test.line)
+
+		select {
+		case evt := <-testBot:
+			if !reflect.DeepEqual(evt, test.expected) {
+				t.Fatalf("%s: expected %+v, got %+v", test.description, test.expected, evt)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("%s: failed to receive expected event", test.description)
+		}
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	conn := wstest.NewWebsocket()
+	config := Config{
+		Nick:     "medgelabs",
+		Password: "oauth:secret",
+		Channel:  "#medgelabs",
+	}
+	irc := NewClient(conn)
+	irc.SetDestination(make(chan bot.Event, 10))
+	irc.Start(config)
+
+	if err := irc.Ready(time.Minute); err == nil {
+		t.Fatalf("Expected not ready before authentication")
+	}
+
+	conn.SendLine(irctest.MakeWelcomeMessage("medgelabs"))
+	conn.SendLine(irctest.MakeJoinMessage("medgelabs", "medgelabs"))
+
+	waitFor(t, func() error { return irc.Ready(time.Minute) })
+
+	if err := irc.Ready(0); err == nil {
+		t.Fatalf("Expected not ready when silent for longer than maxSilence")
+	}
+}
+
+func TestAuthenticationFailure(t *testing.T) {
+	conn := wstest.NewWebsocket()
+	irc := NewClient(conn)
+	irc.SetDestination(make(chan bot.Event, 10))
+	irc.Start(Config{Nick: "medgelabs", Password: "oauth:wrong", Channel: "#medgelabs"})
+
+	conn.SendLine(irctest.MakeAuthFailedMessage())
+
+	waitFor(t, func() error {
+		if irc.Healthy() == nil {
+			return errors.New("still healthy")
+		}
+		return nil
+	})
+}
+
+func TestReadFailureIsUnhealthy(t *testing.T) {
+	irc := NewClient(&failingConn{})
+	irc.Start(Config{Nick: "medgelabs", Password: "oauth:secret", Channel: "#medgelabs"})
+
+	waitFor(t, func() error {
+		if irc.Healthy() == nil {
+			return errors.New("still healthy")
+		}
+		return nil
+	})
+}
+
+// failingConn accepts writes, but every read fails as if retries were exhausted
+type failingConn struct{}
+
+func (c *failingConn) Read(dst []byte) (int, error)   { return 0, errors.New("retries exhausted") }
+func (c *failingConn) Write(data []byte) (int, error) { return len(data), nil }
+func (c *failingConn) Close() error                   { return nil }
+
+// waitFor retries check until it passes, failing the test after a few seconds
+func waitFor(t *testing.T, check func() error) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		err := check()
+		if err == nil {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met in time - %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
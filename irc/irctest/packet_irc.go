@@ -26,6 +26,25 @@ func MakeChatMessage(sender, content, channel string) string {
 	return makeIrcMessage(sender, content, "PRIVMSG", channel, tags)
 }
 
+// MakeModChatMessage generates a well-formed Chat IRC message sent by a moderator
+func MakeModChatMessage(sender, content, channel string) string {
+	tags := make(map[string]string)
+	tags["display-name"] = sender
+	tags["badges"] = "moderator/1"
+	tags["mod"] = "1"
+
+	return makeIrcMessage(sender, content, "PRIVMSG", channel, tags)
+}
+
+// MakeFirstChatMessage generates a well-formed Chat IRC message that is the sender's first in the channel
+func MakeFirstChatMessage(sender, content, channel string) string {
+	tags := make(map[string]string)
+	tags["display-name"] = sender
+	tags["first-msg"] = "1"
+
+	return makeIrcMessage(sender, content, "PRIVMSG", channel, tags)
+}
+
 // MakeBitsMessage generates a well-formed Bits Cheer IRC message
 func MakeBitsMessage(sender string, bits int, channel string) string {
 	tags := make(map[string]string)
@@ -35,6 +54,26 @@ func MakeBitsMessage(sender string, bits int, channel string) string {
 	return makeIrcMessage(sender, fmt.Sprintf("Cheer%d", bits), "PRIVMSG", channel, tags)
 }
 
+// MakeCheerMessage generates a well-formed Bits event IRC message with the cheer text
+// following the cheermote
+func MakeCheerMessage(sender string, bits int, text, channel string) string {
+	tags := make(map[string]string)
+	tags["display-name"] = sender
+	tags["bits"] = strconv.Itoa(bits)
+
+	return makeIrcMessage(strings.ToLower(sender), fmt.Sprintf("Cheer%d %s", bits, text), "PRIVMSG", channel, tags)
+}
+
+// MakeAnonCheerMessage generates a well-formed anonymous Bits event IRC message
+func MakeAnonCheerMessage(bits int, text, channel string) string {
+	tags := make(map[string]string)
+	tags["display-name"] = "AnAnonymousCheerer"
+	tags["login"] = "ananonymouscheerer"
+	tags["bits"] = strconv.Itoa(bits)
+
+	return makeIrcMessage("ananonymouscheerer", fmt.Sprintf("Anon%d %s", bits, text), "PRIVMSG", channel, tags)
+}
+
 // MakeSubMessage generates a well-formed Subscription event IRC message
 func MakeSubMessage(subscriber string, months int, channel string) string {
 	tags := make(map[string]string)
@@ -59,6 +98,35 @@ func MakeResubMessage(subscriber string, months int, channel string) string {
 	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
 }
 
+// MakePrimeSubMessage generates a well-formed Subscription event IRC message for a Prime sub
+func MakePrimeSubMessage(subscriber, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "sub"
+	tags["display-name"] = subscriber
+	tags["msg-param-cumulative-months"] = "1"
+	tags["msg-param-sub-plan"] = "Prime"
+	tags["msg-param-sub-plan-name"] = "Prime"
+
+	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
+}
+
+// MakeTierResubMessage generates a well-formed Re-Subscription event IRC message for the sub
+// plan ("1000", "2000", "3000" or "Prime"). A streak of 0 is not shared
+func MakeTierResubMessage(subscriber string, months, streak int, plan, message, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "resub"
+	tags["display-name"] = subscriber
+	tags["msg-param-cumulative-months"] = strconv.Itoa(months)
+	tags["msg-param-sub-plan"] = plan
+	tags["msg-param-should-share-streak"] = "0"
+	if streak > 0 {
+		tags["msg-param-should-share-streak"] = "1"
+		tags["msg-param-streak-months"] = strconv.Itoa(streak)
+	}
+
+	return makeIrcMessage("", message, "USERNOTICE", channel, tags)
+}
+
 // MakeGiftSubMessage generates a well-formed Gift Subscription event IRC message
 func MakeGiftSubMessage(sender, recipient, channel string) string {
 	tags := make(map[string]string)
@@ -73,6 +141,168 @@ func MakeGiftSubMessage(sender, recipient, channel string) string {
 	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
 }
 
+// MakeTierGiftSubMessage generates a well-formed Gift Subscription event IRC message for
+// months of the sub plan ("1000", "2000" or "3000")
+func MakeTierGiftSubMessage(sender, recipient string, months int, plan, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "subgift"
+	tags["display-name"] = sender
+	tags["msg-param-gift-months"] = strconv.Itoa(months)
+	tags["msg-param-recipient-display-name"] = recipient
+	tags["msg-param-recipient-user-name"] = recipient
+	tags["msg-param-sub-plan"] = plan
+
+	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
+}
+
+// MakeAnonGiftSubMessage generates a well-formed anonymous Gift Subscription event IRC message
+func MakeAnonGiftSubMessage(recipient, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "anonsubgift"
+	tags["display-name"] = "AnAnonymousGifter"
+	tags["login"] = "ananonymousgifter"
+	tags["msg-param-months"] = "1"
+	tags["msg-param-recipient-display-name"] = recipient
+	tags["msg-param-recipient-user-name"] = recipient
+	tags["msg-param-sub-plan"] = "1000"
+
+	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
+}
+
+// MakeMysteryGiftMessage generates a well-formed community gift of count subs IRC message
+func MakeMysteryGiftMessage(sender string, count int, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "submysterygift"
+	tags["display-name"] = sender
+	tags["login"] = strings.ToLower(sender)
+	tags["msg-param-mass-gift-count"] = strconv.Itoa(count)
+	tags["msg-param-sub-plan"] = "1000"
+
+	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
+}
+
+// MakeGiftBombMessages generates the well-formed IRC messages of a community gift: the mystery
+// gift, then a Gift Subscription per recipient, all sharing the origin ID
+func MakeGiftBombMessages(sender string, recipients []string, origin, channel string) []string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "submysterygift"
+	tags["display-name"] = sender
+	tags["login"] = strings.ToLower(sender)
+	tags["msg-param-mass-gift-count"] = strconv.Itoa(len(recipients))
+	tags["msg-param-origin-id"] = origin
+	tags["msg-param-sub-plan"] = "1000"
+	lines := []string{makeIrcMessage("", "", "USERNOTICE", channel, tags)}
+
+	for _, recipient := range recipients {
+		tags := make(map[string]string)
+		tags["msg-id"] = "subgift"
+		tags["display-name"] = sender
+		tags["login"] = strings.ToLower(sender)
+		tags["msg-param-gift-months"] = "1"
+		tags["msg-param-origin-id"] = origin
+		tags["msg-param-recipient-display-name"] = recipient
+		tags["msg-param-recipient-user-name"] = strings.ToLower(recipient)
+		tags["msg-param-sub-plan"] = "1000"
+		lines = append(lines, makeIrcMessage("", "", "USERNOTICE", channel, tags))
+	}
+
+	return lines
+}
+
+// MakeGiftPaidUpgradeMessage generates a well-formed IRC message for a sub gifted by gifter
+// continued as a paid sub
+func MakeGiftPaidUpgradeMessage(user, gifter, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "giftpaidupgrade"
+	tags["display-name"] = user
+	tags["login"] = strings.ToLower(user)
+	tags["msg-param-sender-name"] = gifter
+	tags["msg-param-sender-login"] = strings.ToLower(gifter)
+
+	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
+}
+
+// MakeAnonGiftPaidUpgradeMessage generates a well-formed IRC message for an anonymously
+// gifted sub continued as a paid sub
+func MakeAnonGiftPaidUpgradeMessage(user, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "anongiftpaidupgrade"
+	tags["display-name"] = user
+	tags["login"] = strings.ToLower(user)
+
+	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
+}
+
+// MakePrimePaidUpgradeMessage generates a well-formed IRC message for a Prime sub continued
+// as a paid sub
+func MakePrimePaidUpgradeMessage(user, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "primepaidupgrade"
+	tags["display-name"] = user
+	tags["login"] = strings.ToLower(user)
+	tags["msg-param-sub-plan"] = "1000"
+
+	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
+}
+
+// MakeBitsBadgeTierMessage generates a well-formed IRC message for a bits badge tier unlocked
+func MakeBitsBadgeTierMessage(user string, threshold int, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "bitsbadgetier"
+	tags["display-name"] = user
+	tags["login"] = strings.ToLower(user)
+	tags["msg-param-threshold"] = strconv.Itoa(threshold)
+
+	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
+}
+
+// MakeAnnouncementMessage generates a well-formed IRC message for a mod's /announce
+func MakeAnnouncementMessage(sender, content, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "announcement"
+	tags["display-name"] = sender
+	tags["login"] = strings.ToLower(sender)
+	tags["msg-param-color"] = "PRIMARY"
+
+	return makeIrcMessage("", content, "USERNOTICE", channel, tags)
+}
+
+// MakeUnraidMessage generates a well-formed IRC message for the broadcaster canceling a raid
+func MakeUnraidMessage(broadcaster, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "unraid"
+	tags["display-name"] = broadcaster
+	tags["login"] = strings.ToLower(broadcaster)
+
+	return makeIrcMessage("", "", "USERNOTICE", channel, tags)
+}
+
+// MakeRitualMessage generates a well-formed IRC message for a new chatter ritual
+func MakeRitualMessage(user, content, channel string) string {
+	tags := make(map[string]string)
+	tags["msg-id"] = "ritual"
+	tags["display-name"] = user
+	tags["login"] = strings.ToLower(user)
+	tags["msg-param-ritual-name"] = "new_chatter"
+
+	return makeIrcMessage("", content, "USERNOTICE", channel, tags)
+}
+
+// MakeWelcomeMessage generates the RPL_WELCOME reply sent once authentication succeeds
+func MakeWelcomeMessage(nick string) string {
+	return fmt.Sprintf(":tmi.twitch.tv 001 %s :Welcome, GLHF!", nick)
+}
+
+// MakeJoinMessage generates the JOIN sent back when nick joins the channel
+func MakeJoinMessage(nick, channel string) string {
+	return fmt.Sprintf(":%s!%s@%s.tmi.twitch.tv JOIN #%s", nick, nick, nick, channel)
+}
+
+// MakeAuthFailedMessage generates the NOTICE sent when the PASS is rejected
+func MakeAuthFailedMessage() string {
+	return ":tmi.twitch.tv NOTICE * :Login authentication failed"
+}
+
 // Helper for creating an IRC message
 func makeIrcMessage(sender, body, command, channel string, tags map[string]string) string {
 	var sb strings.Builder
@@ -80,7 +310,7 @@ func makeIrcMessage(sender, body, command, channel string, tags map[string]strin
 	// Tags
 	sb.WriteString("@")
 	for k, v := range tags {
-		sb.WriteString(k + "=" + v + ";")
+		sb.WriteString(k + "=" + escapeTagValue(v) + ";")
 	}
 	sb.WriteString(" :")
 
@@ -120,3 +350,17 @@ func HasTag(msg, tag, tagValue string) bool {
 func HasCommand(msg, command string) bool {
 	return strings.Contains(msg, command)
 }
+
+// tagEscaper escapes tag values as IRCv3 requires
+var tagEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	";", "\\:",
+	" ", "\\s",
+	"\r", "\\r",
+	"\n", "\\n",
+)
+
+// escapeTagValue escapes a tag value, so it may contain spaces and semicolons
+func escapeTagValue(value string) string {
+	return tagEscaper.Replace(value)
+}
@@ -0,0 +1,98 @@
+package irc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// FrameReader is a connection reading whole messages, ex: websocket frames. A frame may hold
+// several IRC lines, and a line may be split across frames
+type FrameReader interface {
+	ReadFrame() ([]byte, error)
+}
+
+// LineReader reads lines from a connection, however the data is split across reads or frames
+// and however long the lines are
+type LineReader struct {
+	frames FrameReader
+	stream *bufio.Reader // Used when the connection isn't a FrameReader
+
+	// Lines read from the last frame, not returned yet
+	pending []string
+
+	// The last frame's tail without a line ending, continued by the next frame
+	partial string
+}
+
+// NewLineReader creates a LineReader over the connection
+func NewLineReader(conn io.Reader) *LineReader {
+	reader := &LineReader{}
+	if frames, ok := conn.(FrameReader); ok {
+		reader.frames = frames
+	} else {
+		reader.stream = bufio.NewReader(conn)
+	}
+
+	return reader
+}
+
+// ReadLine returns the next non-empty line, without its line ending
+func (r *LineReader) ReadLine() (string, error) {
+	for len(r.pending) == 0 {
+		if r.stream != nil {
+			line, err := r.stream.ReadString('\n')
+			if line = strings.TrimRight(line, "\r\n"); line != "" {
+				return line, nil
+			}
+			if err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		frame, err := r.frames.ReadFrame()
+		if err != nil {
+			// The connection ended the last line
+			if line := strings.TrimRight(r.partial, "\r"); line != "" {
+				r.partial = ""
+				return line, nil
+			}
+			return "", err
+		}
+
+		lines := strings.Split(r.partial+string(frame), "\n")
+		r.partial = lines[len(lines)-1]
+		for _, line := range lines[:len(lines)-1] {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				r.pending = append(r.pending, line)
+			}
+		}
+	}
+
+	line := r.pending[0]
+	r.pending = r.pending[1:]
+	return line, nil
+}
+
+// ReadFrame returns the next whole non-empty message, ex: a PubSub JSON message that may span
+// several lines. Without frames to read, or while a line read from frames isn't finished,
+// each line is a message
+func (r *LineReader) ReadFrame() ([]byte, error) {
+	if r.frames == nil || len(r.pending) > 0 || r.partial != "" {
+		line, err := r.ReadLine()
+		return []byte(line), err
+	}
+
+	for {
+		frame, err := r.frames.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(bytes.TrimSpace(frame)) > 0 {
+			return frame, nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package irc
+
+import (
+	"io"
+	"medgebot/bot"
+	"medgebot/irc/irctest"
+	"medgebot/ws/wstest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chunkedReader returns its chunks one Read at a time, like a stream split by the network
+type chunkedReader struct {
+	chunks []string
+}
+
+func (r *chunkedReader) Read(dst []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(dst, r.chunks[0])
+	r.chunks[0] = r.chunks[0][n:]
+	if r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+
+	return n, nil
+}
+
+// chunkedFrames returns each chunk as one frame, like websocket messages
+type chunkedFrames struct {
+	chunkedReader
+}
+
+func (r *chunkedFrames) ReadFrame() ([]byte, error) {
+	if len(r.chunks) == 0 {
+		return nil, io.EOF
+	}
+
+	frame := r.chunks[0]
+	r.chunks = r.chunks[1:]
+	return []byte(frame), nil
+}
+
+// readAllLines reads lines until the reader fails
+func readAllLines(reader *LineReader) []string {
+	lines := make([]string, 0)
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestLineReaderStream(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	reader := NewLineReader(&chunkedReader{chunks: []string{
+		"PING :a\r\nPING :b\r\nPRIV",
+		"MSG #medgelabs :split\r",
+		"\n\r\n:tmi.twitch.tv " + long[:5000],
+		long[5000:] + "\r\n",
+		"no line ending at the end",
+	}})
+
+	expected := []string{
+		"PING :a",
+		"PING :b",
+		"PRIVMSG #medgelabs :split",
+		":tmi.twitch.tv " + long,
+		"no line ending at the end",
+	}
+	if lines := readAllLines(reader); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("Expected lines %q, got %q", expected, lines)
+	}
+}
+
+func TestLineReaderFrames(t *testing.T) {
+	conn := wstest.NewWebsocket()
+	conn.Send("PING :a\r\nPING :b\r\n")
+	conn.Send("PING :c\r\n")
+	conn.Send("{\n  \"type\": \"PONG\"\n}")
+
+	reader := NewLineReader(conn)
+	for _, expected := range []string{"PING :a", "PING :b", "PING :c"} {
+		line, err := reader.ReadLine()
+		if err != nil || line != expected {
+			t.Fatalf("Expected line [%s], got [%s] (%v)", expected, line, err)
+		}
+	}
+
+	frame, err := reader.ReadFrame()
+	if err != nil || string(frame) != "{\n  \"type\": \"PONG\"\n}" {
+		t.Fatalf("Expected the whole frame, got [%s] (%v)", frame, err)
+	}
+}
+
+func TestLineReaderLineSplitAcrossFrames(t *testing.T) {
+	reader := NewLineReader(&chunkedFrames{chunkedReader{chunks: []string{
+		"PING :a\r\nPRIVMSG #medgelabs",
+		" :split across",
+		" frames\r",
+		"\nPING :b\r\nPING :c",
+	}}})
+
+	expected := []string{
+		"PING :a",
+		"PRIVMSG #medgelabs :split across frames",
+		"PING :b",
+		"PING :c",
+	}
+	if lines := readAllLines(reader); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("Expected lines %q, got %q", expected, lines)
+	}
+}
+
+func TestReadSeveralLinesPerFrame(t *testing.T) {
+	conn := wstest.NewWebsocket()
+	irc := NewClient(conn)
+
+	testBot := make(chan bot.Event)
+	irc.SetDestination(testBot)
+	irc.Start(Config{
+		Nick:     "medgelabs",
+		Password: "oauth:secret",
+		Channel:  "#medgelabs",
+	})
+
+	// Longer than a tagged line would fit in a fixed size buffer
+	long := strings.Repeat("Science! ", 300)
+	conn.SendLine(irctest.MakeChatMessage("first", "one", "medgelabs") + "\r\n" +
+		irctest.MakeChatMessage("second", long, "medgelabs"))
+
+	for _, expected := range []bot.Event{
+		{Type: bot.CHAT_MSG, Sender: "first", Message: "one"},
+		{Type: bot.CHAT_MSG, Sender: "second", Message: long},
+	} {
+		select {
+		case evt := <-testBot:
+			if !reflect.DeepEqual(evt, expected) {
+				t.Fatalf("Expected %+v, got %+v", expected, evt)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Failed to receive the chat message from %s", expected.Sender)
+		}
+	}
+}
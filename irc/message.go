@@ -2,9 +2,13 @@ package irc
 
 import (
 	"fmt"
+	"medgebot/bot"
 	log "medgebot/logger"
+	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 const (
@@ -13,15 +17,42 @@ const (
 	MSG_GIFTSUB
 	MSG_BITS
 	MSG_CHAT
+	MSG_MYSTERY_GIFT
+	MSG_GIFT_UPGRADE
+	MSG_PRIME_UPGRADE
+	MSG_BITS_BADGE
+	MSG_ANNOUNCEMENT
+	MSG_UNRAID
+	MSG_RITUAL
+)
+
+// Logins Twitch sends anonymous gift subs and cheers from
+const (
+	anonymousGifterLogin  = "ananonymousgifter"
+	anonymousCheererLogin = "ananonymouscheerer"
 )
 
+// cheermotePrefixes are Twitch's global cheermotes. Channel custom cheermotes are left in the text
+var cheermotePrefixes = []string{
+	"Cheer", "DoodleCheer", "BibleThump", "cheerwhal", "Corgo", "uni", "ShowLove", "Party",
+	"SeemsGood", "Pride", "Kappa", "FrankerZ", "HeyGuys", "DansGame", "EleGiggle", "TriHard",
+	"Kreygasm", "4Head", "SwiftRage", "NotLikeThis", "FailFish", "VoHiYo", "PJSalt",
+	"MrDestructoid", "bday", "RIPCheer", "Shamrock", "BitBoss", "Streamlabs", "Muxy",
+	"HolidayCheer", "Goal", "Anon", "Charity",
+}
+
+// cheermote matches a cheermote word in a cheer, ex: Cheer100 or Kappa1000.
+// Words that only look like one, ex: abc123 or mp4, are not matched
+var cheermote = regexp.MustCompile(`(?i)^(` + strings.Join(cheermotePrefixes, "|") + `)[0-9]+$`)
+
 // Message represents a line of text from the IRC stream
 type Message struct {
 	Tags     map[string]string
-	User     string
-	Command  string
-	Channel  string
-	Contents string
+	User     string   // display-name tag, else the nick (or server) from the prefix
+	Command  string   // Uppercase command, or a 3 digit numeric reply, ex: 001
+	Params   []string // Every parameter, the trailing one included
+	Channel  string   // First parameter naming a channel, without the #
+	Contents string   // Trailing parameter, or the last parameter if it isn't the Channel
 }
 
 func NewMessage() Message {
@@ -41,69 +72,189 @@ func (msg *Message) AddTag(tag, value string) {
 	msg.Tags[tag] = value
 }
 
-// Attempt to parse a line from IRC to a Message
-func parseIrcLine(message string) Message {
-	if strings.TrimSpace(message) == "" {
-		log.Warn("attempt to parse empty IRC line")
-		return NewMessage()
+// parseIrcLine parses a line from IRC to a Message, following the IRCv3 message format:
+//
+//	[@tags] [:prefix] COMMAND [params...] [:trailing]
+//
+// Tag values are unescaped. Returns an error if the line has no valid command
+func parseIrcLine(line string) (Message, error) {
+	msg := NewMessage()
+
+	rest := strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(rest) == "" {
+		return msg, errors.New("empty IRC line")
 	}
 
-	// TrimSpace to get rid of /r/n
-	msgStr := strings.TrimSpace(string(message))
-	tokens := strings.Split(msgStr, " ")
+	if strings.HasPrefix(rest, "@") {
+		var tags string
+		tags, rest = nextToken(rest[1:])
+		parseTags(&msg, tags)
+	}
 
-	// If tags are present, parse them out
-	msg := NewMessage()
-	cursor := 0
+	prefix := ""
+	if strings.HasPrefix(rest, ":") {
+		prefix, rest = nextToken(rest[1:])
+	}
+
+	msg.Command, rest = nextToken(rest)
+	if msg.Command == "" {
+		return msg, errors.Errorf("missing command in IRC line [%s]", line)
+	}
+	if !validCommand(msg.Command) {
+		return msg, errors.Errorf("invalid command %q in IRC line [%s]", msg.Command, line)
+	}
+	msg.Command = strings.ToUpper(msg.Command)
+
+	trailing := false
+	for rest != "" {
+		if strings.HasPrefix(rest, ":") {
+			msg.Params = append(msg.Params, rest[1:])
+			trailing = true
+			break
+		}
+
+		var param string
+		param, rest = nextToken(rest)
+		msg.Params = append(msg.Params, param)
+	}
+
+	for _, param := range msg.Params {
+		if strings.HasPrefix(param, "#") {
+			msg.Channel = strings.TrimPrefix(param, "#")
+			break
+		}
+	}
+
+	if count := len(msg.Params); count > 0 {
+		last := msg.Params[count-1]
+		if trailing || last != "#"+msg.Channel {
+			msg.Contents = last
+		}
+	}
+
+	msg.User = msg.Tag("display-name")
+	if msg.User == "" {
+		msg.User = prefixNick(prefix)
+	}
+
+	return msg, nil
+}
+
+// nextToken splits off the space-delimited token at the start of the line, returning it and
+// the rest of the line without its leading spaces
+func nextToken(line string) (string, string) {
+	line = strings.TrimLeft(line, " ")
+	end := strings.IndexByte(line, ' ')
+	if end < 0 {
+		return line, ""
+	}
+
+	return line[:end], strings.TrimLeft(line[end:], " ")
+}
+
+// validCommand checks the command is a word of letters or a 3 digit numeric reply
+func validCommand(command string) bool {
+	numeric := len(command) == 3
+	letters := true
+	for _, r := range command {
+		isDigit := r >= '0' && r <= '9'
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		numeric = numeric && isDigit
+		letters = letters && isLetter
+	}
 
-	// Tags
-	if strings.HasPrefix(tokens[cursor], "@") {
-		tagsSlice := strings.Split(strings.TrimLeft(tokens[cursor], "@"), ";")
-		for _, tag := range tagsSlice {
-			parts := strings.Split(tag, "=")
+	return numeric || letters
+}
 
-			// Random chance of empty tag? No panicking
-			if len(parts) < 2 {
-				continue
-			}
+// parseTags adds each key=value tag, separated by semicolons, to the Message. Tags without
+// a value are empty, and empty keys are skipped
+func parseTags(msg *Message, tags string) {
+	for _, tag := range strings.Split(tags, ";") {
+		parts := strings.SplitN(tag, "=", 2)
+		if parts[0] == "" {
+			continue
+		}
 
-			msg.AddTag(parts[0], parts[1])
+		value := ""
+		if len(parts) == 2 {
+			value = unescapeTagValue(parts[1])
 		}
-		cursor++
+		msg.AddTag(parts[0], value)
+	}
+}
+
+// tagEscapes maps the character after a backslash in an escaped tag value to what it stands for
+var tagEscapes = map[byte]byte{
+	':':  ';',
+	's':  ' ',
+	'\\': '\\',
+	'r':  '\r',
+	'n':  '\n',
+}
+
+// unescapeTagValue reverses the IRCv3 tag value escaping. A backslash before any other
+// character is dropped, as is a trailing backslash
+func unescapeTagValue(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
 	}
 
-	// Parse Username, if present
-	if strings.HasPrefix(tokens[cursor], ":") {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		if i == len(value) {
+			break
+		}
 
-		nameTag := msg.Tag("display-name")
-		if nameTag != "" {
-			msg.User = nameTag
+		if unescaped, ok := tagEscapes[value[i]]; ok {
+			sb.WriteByte(unescaped)
 		} else {
-			// Parse from the prefix before the command (not ideal)
-			rawUsername := strings.Split(tokens[cursor], ":")[1]
-			username := strings.Split(rawUsername, "!")[0]
-			msg.User = username
+			sb.WriteByte(value[i])
 		}
+	}
+
+	return sb.String()
+}
 
-		cursor++
+// prefixNick returns the nick of a nick!user@host prefix, or the prefix itself for a server
+func prefixNick(prefix string) string {
+	if end := strings.IndexAny(prefix, "!@"); end >= 0 {
+		return prefix[:end]
 	}
 
-	// Next cursor point should be Command
-	msg.Command = tokens[cursor]
-	cursor++
+	return prefix
+}
+
+// IsNumeric checks if the message is a numeric reply, ex: 001 once authenticated
+func (msg Message) IsNumeric() bool {
+	return len(msg.Command) == 3 && msg.Command[0] >= '0' && msg.Command[0] <= '9'
+}
+
+// IsCapAck checks if the message acknowledges a CAP REQ
+func (msg Message) IsCapAck() bool {
+	return msg.Command == "CAP" && len(msg.Params) > 1 && msg.Params[1] == "ACK"
+}
+
+// IsCapNak checks if the message rejects a CAP REQ
+func (msg Message) IsCapNak() bool {
+	return msg.Command == "CAP" && len(msg.Params) > 1 && msg.Params[1] == "NAK"
+}
 
-	// TODO this caused a panic on some kind of message
-	// Then, Channel
-	msg.Channel = strings.TrimPrefix(tokens[cursor], "#")
-	cursor++
+// Capabilities returns the capabilities of a CAP ACK or NAK, ex: twitch.tv/tags
+func (msg Message) Capabilities() []string {
+	if msg.Command != "CAP" || len(msg.Params) < 3 {
+		return nil
+	}
 
-	// The rest should be the combined String beyond the ":"
-	combinedContents := strings.Join(tokens[cursor:], " ")
-	msg.Contents = strings.TrimPrefix(combinedContents, ":")
-	return msg
+	return strings.Fields(msg.Params[2])
 }
 
-// Parse a msgType from Tags on a USERNOTICE to one of our iota constants, or MSG_SYSTEM if
+// Parse a msgType from Tags on a USERNOTICE to one of our iota constants, or MSG_CHAT if
 // unknown
 func (msg Message) parseUserNoticeMessageType() int {
 	msgType := msg.Tag("msg-id")
@@ -113,13 +264,57 @@ func (msg Message) parseUserNoticeMessageType() int {
 		return MSG_RAID
 	case "sub", "resub":
 		return MSG_SUB
-	case "subgift":
+	case "subgift", "anonsubgift":
 		return MSG_GIFTSUB
+	case "submysterygift", "anonsubmysterygift":
+		return MSG_MYSTERY_GIFT
+	case "giftpaidupgrade", "anongiftpaidupgrade":
+		return MSG_GIFT_UPGRADE
+	case "primepaidupgrade":
+		return MSG_PRIME_UPGRADE
+	case "bitsbadgetier":
+		return MSG_BITS_BADGE
+	case "announcement":
+		return MSG_ANNOUNCEMENT
+	case "unraid":
+		return MSG_UNRAID
+	case "ritual":
+		return MSG_RITUAL
 	default:
 		return MSG_CHAT
 	}
 }
 
+// intTag returns the tag as a number, or 0 if missing or invalid
+func (msg Message) intTag(tag string) int {
+	str := msg.Tag(tag)
+	value, err := strconv.Atoi(str)
+	if err != nil {
+		log.Error(err, "invalid %s [%s]", tag, str)
+		return 0
+	}
+
+	return value
+}
+
+// IsAnonymous checks if a gift or upgrade USERNOTICE was sent by an anonymous gifter,
+// or a cheer by an anonymous cheerer
+func (msg Message) IsAnonymous() bool {
+	if strings.HasPrefix(msg.Tag("msg-id"), "anon") {
+		return true
+	}
+
+	for _, login := range []string{anonymousGifterLogin, anonymousCheererLogin} {
+		if strings.EqualFold(msg.Tag("login"), login) ||
+			strings.EqualFold(msg.Tag("display-name"), login) ||
+			strings.EqualFold(msg.User, login) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // IsRaidMessage checks if message is a Raid message
 func (msg Message) IsRaidMessage() bool {
 	return msg.parseUserNoticeMessageType() == MSG_RAID
@@ -153,6 +348,18 @@ func (msg Message) BitsSender() string {
 	return msg.Tag("display-name")
 }
 
+// CheerMessage returns the text of a Bits message without its cheermotes
+func (msg Message) CheerMessage() string {
+	words := make([]string, 0)
+	for _, word := range strings.Fields(msg.Contents) {
+		if !cheermote.MatchString(word) {
+			words = append(words, word)
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
 // Return the amount of bits donated in a Bits message
 func (msg Message) BitsAmount() int {
 	bitsStr := msg.Tag("bits")
@@ -187,6 +394,43 @@ func (msg Message) SubMonths() int {
 	return months
 }
 
+// SubTier returns the tier, 1 to 3, of a Subscription or Gift Subscription. Prime subs are tier 1.
+// Defaults to 1 if the sub plan is missing or unknown
+func (msg Message) SubTier() int {
+	switch msg.Tag("msg-param-sub-plan") {
+	case "2000":
+		return 2
+	case "3000":
+		return 3
+	default:
+		return 1
+	}
+}
+
+// IsPrimeSub checks if a Subscription was paid for with Prime Gaming
+func (msg Message) IsPrimeSub() bool {
+	return msg.Tag("msg-param-sub-plan") == "Prime"
+}
+
+// SubStreak returns the consecutive months subscribed for a Subscription message,
+// or 0 if the subscriber chose not to share it
+func (msg Message) SubStreak() int {
+	if msg.Tag("msg-param-should-share-streak") != "1" {
+		return 0
+	}
+
+	return msg.intTag("msg-param-streak-months")
+}
+
+// GiftMonths returns the months gifted at once in a Gift Subscription, 1 if not given
+func (msg Message) GiftMonths() int {
+	if msg.Tag("msg-param-gift-months") == "" {
+		return 1
+	}
+
+	return msg.intTag("msg-param-gift-months")
+}
+
 // Check if message is a Sub/Resub message
 func (msg Message) IsGiftSubscriptionMessage() bool {
 	return msg.parseUserNoticeMessageType() == MSG_GIFTSUB
@@ -197,9 +441,95 @@ func (msg Message) GiftRecipient() string {
 	return msg.Tag("msg-param-recipient-display-name")
 }
 
-// Return the Sender of a Gift Subscription
+// Return the Sender of a Gift Subscription, or of a Mystery Gift
 func (msg Message) GiftSender() string {
-	return msg.Tag("display-name")
+	if sender := msg.Tag("display-name"); sender != "" {
+		return sender
+	}
+
+	// Anonymous gifts may come with no display-name
+	return bot.AnonymousGifter
+}
+
+// GiftOrigin returns the ID shared by a community gift and each of its Gift Subscriptions.
+// Empty for a single Gift Subscription
+func (msg Message) GiftOrigin() string {
+	return msg.Tag("msg-param-origin-id")
+}
+
+// IsMysteryGiftMessage checks if message is a community gift of several subs
+func (msg Message) IsMysteryGiftMessage() bool {
+	return msg.parseUserNoticeMessageType() == MSG_MYSTERY_GIFT
+}
+
+// MysteryGiftCount returns the number of subs gifted in a Mystery Gift message
+func (msg Message) MysteryGiftCount() int {
+	return msg.intTag("msg-param-mass-gift-count")
+}
+
+// IsGiftUpgradeMessage checks if message is a gifted sub continued as a paid sub
+func (msg Message) IsGiftUpgradeMessage() bool {
+	return msg.parseUserNoticeMessageType() == MSG_GIFT_UPGRADE
+}
+
+// UpgradeGifter returns who gifted the sub being continued. Empty if anonymous
+func (msg Message) UpgradeGifter() string {
+	return msg.Tag("msg-param-sender-name")
+}
+
+// IsPrimeUpgradeMessage checks if message is a Prime sub continued as a paid sub
+func (msg Message) IsPrimeUpgradeMessage() bool {
+	return msg.parseUserNoticeMessageType() == MSG_PRIME_UPGRADE
+}
+
+// IsBitsBadgeMessage checks if message is a bits badge tier being unlocked
+func (msg Message) IsBitsBadgeMessage() bool {
+	return msg.parseUserNoticeMessageType() == MSG_BITS_BADGE
+}
+
+// BitsBadgeTier returns the tier unlocked in a Bits Badge message, ex: 1000
+func (msg Message) BitsBadgeTier() int {
+	return msg.intTag("msg-param-threshold")
+}
+
+// IsAnnouncementMessage checks if message is a mod's /announce message
+func (msg Message) IsAnnouncementMessage() bool {
+	return msg.parseUserNoticeMessageType() == MSG_ANNOUNCEMENT
+}
+
+// IsUnraidMessage checks if message is a raid being canceled
+func (msg Message) IsUnraidMessage() bool {
+	return msg.parseUserNoticeMessageType() == MSG_UNRAID
+}
+
+// IsRitualMessage checks if message is a chat ritual
+func (msg Message) IsRitualMessage() bool {
+	return msg.parseUserNoticeMessageType() == MSG_RITUAL
+}
+
+// RitualName returns the ritual in a Ritual message, ex: new_chatter
+func (msg Message) RitualName() string {
+	return msg.Tag("msg-param-ritual-name")
+}
+
+// IsModerator checks if the sender of a chat message is a moderator or the broadcaster
+func (msg Message) IsModerator() bool {
+	if msg.Tag("mod") == "1" {
+		return true
+	}
+
+	for _, badge := range strings.Split(msg.Tag("badges"), ",") {
+		if strings.HasPrefix(badge, "broadcaster/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsFirstMessage checks if a chat message is the sender's first ever in the channel
+func (msg Message) IsFirstMessage() bool {
+	return msg.Tag("first-msg") == "1"
 }
 
 func (msg Message) String() string {
@@ -0,0 +1,64 @@
+//go:build go1.18
+// +build go1.18
+
+package irc
+
+import (
+	"medgebot/irc/irctest"
+	"strings"
+	"testing"
+)
+
+func FuzzParseIrcLine(f *testing.F) {
+	seeds := []string{
+		"",
+		CHAT_MSG_BASE,
+		irctest.MakeChatMessage(assistant, "Yes, we can test", channel),
+		irctest.MakeRaidMessage(assistant, 5, channel),
+		irctest.MakeTierResubMessage(assistant, 12, 5, "2000", "Still here!", channel),
+		irctest.MakeWelcomeMessage("medgelabs"),
+		":tmi.twitch.tv CAP * ACK :twitch.tv/tags twitch.tv/commands",
+		"PING :tmi.twitch.tv\r\n",
+		`@system-msg=a\sb\:c\\;empty;= :tmi.twitch.tv USERNOTICE #medgelabs`,
+		"@display-name=medgelabs; tmi.twitch.tv PRIVMSG :Trailing semicolon",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		msg, err := parseIrcLine(line)
+		if err != nil {
+			return
+		}
+
+		if !validCommand(msg.Command) {
+			t.Fatalf("Parsed invalid command %q from %q", msg.Command, line)
+		}
+
+		if msg.Contents != "" && msg.Params[len(msg.Params)-1] != msg.Contents {
+			t.Fatalf("Contents %q is not the last parameter of %q", msg.Contents, line)
+		}
+
+		if msg.Channel != "" && strings.Contains(msg.Channel, " ") {
+			t.Fatalf("Channel %q contains a space, from %q", msg.Channel, line)
+		}
+	})
+}
+
+func FuzzTagValueRoundTrip(f *testing.F) {
+	for _, seed := range []string{"medgelabs", "5 raiders; from \\ RAIDER", "a\r\nb", `\s\:`, ""} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		msg, err := parseIrcLine(irctest.MakeAnnouncementMessage(value, "hi", channel))
+		if err != nil {
+			t.Fatalf("Failed to parse a line with tag value %q: %v", value, err)
+		}
+
+		if msg.Tag("display-name") != value {
+			t.Fatalf("Expected tag value %q after unescaping, got %q", value, msg.Tag("display-name"))
+		}
+	})
+}
@@ -23,6 +23,16 @@ func TestTags(t *testing.T) {
 	}
 }
 
+// parseLine parses a line expected to be valid, for test tables
+func parseLine(line string) Message {
+	msg, err := parseIrcLine(line)
+	if err != nil {
+		panic(err)
+	}
+
+	return msg
+}
+
 func TestParseIrcLine(t *testing.T) {
 	tests := []struct {
 		description string
@@ -35,6 +45,7 @@ func TestParseIrcLine(t *testing.T) {
 			},
 			User:     assistant,
 			Command:  "PRIVMSG",
+			Params:   []string{"#medgelabs", "Yes, we can test"},
 			Channel:  channel,
 			Contents: "Yes, we can test",
 		}},
@@ -42,6 +53,7 @@ func TestParseIrcLine(t *testing.T) {
 			Tags:     map[string]string{},
 			User:     "assistant1",
 			Command:  "PRIVMSG",
+			Params:   []string{"#medgelabs", "Yes, we can test"},
 			Channel:  channel,
 			Contents: "Yes, we can test",
 		}},
@@ -52,15 +64,111 @@ func TestParseIrcLine(t *testing.T) {
 			},
 			User:     assistant,
 			Command:  "PRIVMSG",
+			Params:   []string{"#medgelabs", "Cheer1"},
 			Channel:  channel,
 			Contents: "Cheer1",
 		}},
-		{description: "Empty line should not explode", input: "", expected: NewMessage()},
+		{description: "Escaped tag values are unescaped", input: `@system-msg=5\sraiders\sfrom\sRAIDER;semi=a\:b;slash=C:\\lab;lines=a\r\nb;other=\x;trailing=ab\;empty= :tmi.twitch.tv USERNOTICE #medgelabs`, expected: Message{
+			Tags: map[string]string{
+				"system-msg": "5 raiders from RAIDER",
+				"semi":       "a;b",
+				"slash":      `C:\lab`,
+				"lines":      "a\r\nb",
+				"other":      "x",
+				"trailing":   "ab",
+				"empty":      "",
+			},
+			User:    "tmi.twitch.tv",
+			Command: "USERNOTICE",
+			Params:  []string{"#medgelabs"},
+			Channel: channel,
+		}},
+		{description: "Tags without a value are empty", input: "@emote-only;subs-only=0 :tmi.twitch.tv ROOMSTATE #medgelabs", expected: Message{
+			Tags: map[string]string{
+				"emote-only": "",
+				"subs-only":  "0",
+			},
+			User:    "tmi.twitch.tv",
+			Command: "ROOMSTATE",
+			Params:  []string{"#medgelabs"},
+			Channel: channel,
+		}},
+		{description: "Numeric reply", input: irctest.MakeWelcomeMessage("medgelabs"), expected: Message{
+			Tags:     map[string]string{},
+			User:     "tmi.twitch.tv",
+			Command:  "001",
+			Params:   []string{"medgelabs", "Welcome, GLHF!"},
+			Contents: "Welcome, GLHF!",
+		}},
+		{description: "NAMES reply", input: ":medgelabs.tmi.twitch.tv 353 medgelabs = #medgelabs :medgelabs assistant1", expected: Message{
+			Tags:     map[string]string{},
+			User:     "medgelabs.tmi.twitch.tv",
+			Command:  "353",
+			Params:   []string{"medgelabs", "=", "#medgelabs", "medgelabs assistant1"},
+			Channel:  channel,
+			Contents: "medgelabs assistant1",
+		}},
+		{description: "CAP ACK", input: ":tmi.twitch.tv CAP * ACK :twitch.tv/tags twitch.tv/commands", expected: Message{
+			Tags:     map[string]string{},
+			User:     "tmi.twitch.tv",
+			Command:  "CAP",
+			Params:   []string{"*", "ACK", "twitch.tv/tags twitch.tv/commands"},
+			Contents: "twitch.tv/tags twitch.tv/commands",
+		}},
+		{description: "PING without a prefix", input: "PING :tmi.twitch.tv\r\n", expected: Message{
+			Tags:     map[string]string{},
+			Command:  "PING",
+			Params:   []string{"tmi.twitch.tv"},
+			Contents: "tmi.twitch.tv",
+		}},
+		{description: "Command without parameters", input: ":tmi.twitch.tv RECONNECT", expected: Message{
+			Tags:    map[string]string{},
+			User:    "tmi.twitch.tv",
+			Command: "RECONNECT",
+		}},
+		{description: "JOIN has no Contents", input: irctest.MakeJoinMessage("medgelabs", "medgelabs"), expected: Message{
+			Tags:    map[string]string{},
+			User:    "medgelabs",
+			Command: "JOIN",
+			Params:  []string{"#medgelabs"},
+			Channel: channel,
+		}},
+		{description: "CLEARCHAT of a user", input: "@ban-duration=600;room-id=1 :tmi.twitch.tv CLEARCHAT #medgelabs :spammer", expected: Message{
+			Tags: map[string]string{
+				"ban-duration": "600",
+				"room-id":      "1",
+			},
+			User:     "tmi.twitch.tv",
+			Command:  "CLEARCHAT",
+			Params:   []string{"#medgelabs", "spammer"},
+			Channel:  channel,
+			Contents: "spammer",
+		}},
+		{description: "WHISPER to the bot", input: "@display-name=Fjoell :fjoell!fjoell@fjoell.tmi.twitch.tv WHISPER medgelabs :psst", expected: Message{
+			Tags: map[string]string{
+				"display-name": "Fjoell",
+			},
+			User:     "Fjoell",
+			Command:  "WHISPER",
+			Params:   []string{"medgelabs", "psst"},
+			Contents: "psst",
+		}},
+		{description: "Trailing keeps colons and extra spaces", input: ":a!a@a.tmi.twitch.tv PRIVMSG #medgelabs ::)  hi  ", expected: Message{
+			Tags:     map[string]string{},
+			User:     "a",
+			Command:  "PRIVMSG",
+			Params:   []string{"#medgelabs", ":)  hi  "},
+			Channel:  channel,
+			Contents: ":)  hi  ",
+		}},
 	}
 
 	for _, test := range tests {
 		t.Run(test.description, func(tt *testing.T) {
-			result := parseIrcLine(test.input)
+			result, err := parseIrcLine(test.input)
+			if err != nil {
+				tt.Fatalf("Unexpected error: %v", err)
+			}
 			if !reflect.DeepEqual(result, test.expected) {
 				tt.Fatalf("Parsed Message invalid. Expected:\n %+v \nGot:\n %+v", test.expected, result)
 			}
@@ -68,8 +176,51 @@ func TestParseIrcLine(t *testing.T) {
 	}
 }
 
+func TestParseIrcLineErrors(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+	}{
+		{"Empty line", ""},
+		{"Only line endings", "\r\n"},
+		{"Tags only", "@display-name=medgelabs"},
+		{"Prefix only", ":tmi.twitch.tv"},
+		{"Tags and prefix only", "@display-name=medgelabs :tmi.twitch.tv "},
+		{"Invalid command", ":tmi.twitch.tv PRIV-MSG #medgelabs :hi"},
+		{"Numeric of the wrong length", ":tmi.twitch.tv 0001 medgelabs :hi"},
+	}
+
+	for _, test := range tests {
+		if msg, err := parseIrcLine(test.input); err == nil {
+			t.Errorf("%s: expected an error, got %+v", test.description, msg)
+		}
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	ack := parseLine(":tmi.twitch.tv CAP * ACK :twitch.tv/tags twitch.tv/commands")
+	if !ack.IsCapAck() || ack.IsCapNak() {
+		t.Fatalf("Expected a CAP ACK")
+	}
+	if !reflect.DeepEqual(ack.Capabilities(), []string{"twitch.tv/tags", "twitch.tv/commands"}) {
+		t.Fatalf("Unexpected capabilities: %v", ack.Capabilities())
+	}
+
+	nak := parseLine(":tmi.twitch.tv CAP * NAK :twitch.tv/bogus")
+	if !nak.IsCapNak() || nak.IsCapAck() {
+		t.Fatalf("Expected a CAP NAK")
+	}
+
+	if !parseLine(irctest.MakeWelcomeMessage("medgelabs")).IsNumeric() {
+		t.Fatalf("Expected 001 to be a numeric reply")
+	}
+	if parseLine(CHAT_MSG_BASE).IsNumeric() {
+		t.Fatalf("Expected PRIVMSG not to be a numeric reply")
+	}
+}
+
 func TestRaidMessageParsing(t *testing.T) {
-	parsed := parseIrcLine(irctest.MakeRaidMessage(assistant, 1, channel))
+	parsed := parseLine(irctest.MakeRaidMessage(assistant, 1, channel))
 
 	if !parsed.IsRaidMessage() {
 		t.Fatalf("RAID_MSG not recognized as a Raid")
@@ -87,7 +238,7 @@ func TestRaidMessageParsing(t *testing.T) {
 
 func TestRaidMessageParsingInvalidRaidSize(t *testing.T) {
 	// Invalid raid size value should default to 0
-	invalid := parseIrcLine(irctest.MakeRaidMessage(assistant, 1, channel))
+	invalid := parseLine(irctest.MakeRaidMessage(assistant, 1, channel))
 	invalid.AddTag("msg-param-viewerCount", "asdf")
 	if invalid.RaidSize() != 0 {
 		t.Fatalf("Invalid raid size should have defaulted to 0. Got: %d", invalid.RaidSize())
@@ -95,7 +246,7 @@ func TestRaidMessageParsingInvalidRaidSize(t *testing.T) {
 }
 
 func TestBitsMessageParsing(t *testing.T) {
-	parsed := parseIrcLine(irctest.MakeBitsMessage(assistant, 1, "medgelabs"))
+	parsed := parseLine(irctest.MakeBitsMessage(assistant, 1, "medgelabs"))
 
 	if !parsed.IsBitsMessage() {
 		t.Fatalf("BITS_MSG not recognized as Bits cheering")
@@ -110,9 +261,35 @@ func TestBitsMessageParsing(t *testing.T) {
 	}
 }
 
+func TestCheerMessageParsing(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		message     string
+		anonymous   bool
+	}{
+		{"Cheermote only", irctest.MakeBitsMessage(assistant, 100, channel), "", false},
+		{"Cheer text", irctest.MakeCheerMessage(assistant, 100, "Great stream!", channel), "Great stream!", false},
+		{"Several cheermotes", irctest.MakeCheerMessage(assistant, 100, "Kappa50 keep going Cheer50", channel), "keep going", false},
+		{"Cheermote case", irctest.MakeCheerMessage(assistant, 100, "cheer100 PogChamp", channel), "PogChamp", false},
+		{"Words ending in digits", irctest.MakeCheerMessage(assistant, 100, "Uploaded the abc123 mp4 for you", channel), "Uploaded the abc123 mp4 for you", false},
+		{"Anonymous cheer", irctest.MakeAnonCheerMessage(500, "From a fan", channel), "From a fan", true},
+	}
+
+	for _, test := range tests {
+		parsed := parseLine(test.input)
+		if parsed.CheerMessage() != test.message {
+			t.Errorf("%s: expected message [%s], got [%s]", test.description, test.message, parsed.CheerMessage())
+		}
+		if parsed.IsAnonymous() != test.anonymous {
+			t.Errorf("%s: expected anonymous %v", test.description, test.anonymous)
+		}
+	}
+}
+
 func TestBitsMessageParsingInvalidBitAmount(t *testing.T) {
 	// Invalid bits value should default to 0
-	invalid := parseIrcLine(irctest.MakeBitsMessage(assistant, 1, channel))
+	invalid := parseLine(irctest.MakeBitsMessage(assistant, 1, channel))
 	invalid.AddTag("bits", "asdf")
 	if invalid.BitsAmount() != 0 {
 		t.Fatalf("Invalid bits amount should have defaulted to 0. Got: %d", invalid.BitsAmount())
@@ -120,7 +297,7 @@ func TestBitsMessageParsingInvalidBitAmount(t *testing.T) {
 }
 
 func TestSubMessageParsing(t *testing.T) {
-	parsed := parseIrcLine(irctest.MakeSubMessage(assistant, 1, "medgelabs"))
+	parsed := parseLine(irctest.MakeSubMessage(assistant, 1, "medgelabs"))
 
 	if !parsed.IsSubscriptionMessage() {
 		t.Fatalf("Subscription Message not recognized as sub event")
@@ -137,7 +314,7 @@ func TestSubMessageParsing(t *testing.T) {
 
 func TestSubMessageParsingInvalidSubMonths(t *testing.T) {
 	// Invalid sub months value should default to 0
-	invalid := parseIrcLine(irctest.MakeSubMessage(assistant, 1, channel))
+	invalid := parseLine(irctest.MakeSubMessage(assistant, 1, channel))
 	invalid.AddTag("msg-param-cumulative-months", "asdf")
 	if invalid.SubMonths() != 0 {
 		t.Fatalf("Invalid Sub Months should have defaulted to 0. Got: %d", invalid.SubMonths())
@@ -145,7 +322,7 @@ func TestSubMessageParsingInvalidSubMonths(t *testing.T) {
 }
 
 func TestResubMessageParsing(t *testing.T) {
-	parsed := parseIrcLine(irctest.MakeResubMessage(assistant, 2, "medgelabs"))
+	parsed := parseLine(irctest.MakeResubMessage(assistant, 2, "medgelabs"))
 
 	if !parsed.IsSubscriptionMessage() {
 		t.Fatalf("Re-Subscription Message not recognized as sub event")
@@ -160,8 +337,40 @@ func TestResubMessageParsing(t *testing.T) {
 	}
 }
 
+func TestSubDetailsParsing(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		tier        int
+		prime       bool
+		streak      int
+		message     string
+	}{
+		{"Tier 1 sub", irctest.MakeSubMessage(assistant, 1, channel), 1, false, 0, ""},
+		{"Prime sub", irctest.MakePrimeSubMessage(assistant, channel), 1, true, 0, ""},
+		{"Tier 2 resub with streak", irctest.MakeTierResubMessage(assistant, 12, 5, "2000", "Still here!", channel), 2, false, 5, "Still here!"},
+		{"Tier 3 resub without streak", irctest.MakeTierResubMessage(assistant, 12, 0, "3000", "", channel), 3, false, 0, ""},
+	}
+
+	for _, test := range tests {
+		parsed := parseLine(test.input)
+		if parsed.SubTier() != test.tier {
+			t.Errorf("%s: expected tier %d, got %d", test.description, test.tier, parsed.SubTier())
+		}
+		if parsed.IsPrimeSub() != test.prime {
+			t.Errorf("%s: expected Prime %v", test.description, test.prime)
+		}
+		if parsed.SubStreak() != test.streak {
+			t.Errorf("%s: expected streak %d, got %d", test.description, test.streak, parsed.SubStreak())
+		}
+		if parsed.Contents != test.message {
+			t.Errorf("%s: expected message [%s], got [%s]", test.description, test.message, parsed.Contents)
+		}
+	}
+}
+
 func TestGiftSubMessageParsing(t *testing.T) {
-	parsed := parseIrcLine(irctest.MakeGiftSubMessage("ReallyFrank", "Fjoell", "medgelabs"))
+	parsed := parseLine(irctest.MakeGiftSubMessage("ReallyFrank", "Fjoell", "medgelabs"))
 
 	if !parsed.IsGiftSubscriptionMessage() {
 		t.Fatalf("Gift Subscription Message not recognized as subgift event")
@@ -174,6 +383,50 @@ func TestGiftSubMessageParsing(t *testing.T) {
 	if parsed.GiftSender() != "ReallyFrank" {
 		t.Fatalf("Sender should be ReallyFrank, but got %s", parsed.GiftSender())
 	}
+
+	if parsed.GiftMonths() != 1 {
+		t.Fatalf("GiftMonths should be 1, but got %d", parsed.GiftMonths())
+	}
+
+	tiered := parseLine(irctest.MakeTierGiftSubMessage("ReallyFrank", "Fjoell", 6, "3000", channel))
+	if tiered.SubTier() != 3 || tiered.GiftMonths() != 6 {
+		t.Fatalf("Expected 6 months of tier 3, got %d months of tier %d", tiered.GiftMonths(), tiered.SubTier())
+	}
+}
+
+func TestModeratorDetection(t *testing.T) {
+	tests := []struct {
+		description string
+		message     Message
+		expected    bool
+	}{
+		{"Viewer", parseLine(irctest.MakeChatMessage(assistant, "hi", channel)), false},
+		{"Moderator", parseLine(irctest.MakeModChatMessage(assistant, "hi", channel)), true},
+	}
+
+	broadcaster := parseLine(irctest.MakeChatMessage("medgelabs", "hi", channel))
+	broadcaster.AddTag("badges", "broadcaster/1,subscriber/0")
+	tests = append(tests, struct {
+		description string
+		message     Message
+		expected    bool
+	}{"Broadcaster", broadcaster, true})
+
+	for _, test := range tests {
+		if test.message.IsModerator() != test.expected {
+			t.Errorf("%s: expected IsModerator %v", test.description, test.expected)
+		}
+	}
+}
+
+func TestFirstMessageDetection(t *testing.T) {
+	if parseLine(irctest.MakeChatMessage(assistant, "hi", channel)).IsFirstMessage() {
+		t.Errorf("Expected a regular chat message not to be a first message")
+	}
+
+	if !parseLine(irctest.MakeFirstChatMessage(assistant, "hi", channel)).IsFirstMessage() {
+		t.Errorf("Expected first-msg tag to mark a first message")
+	}
 }
 
 func TestParseUserNoticeMessageType(t *testing.T) {
@@ -187,11 +440,20 @@ func TestParseUserNoticeMessageType(t *testing.T) {
 		{description: "Sub Message", input: irctest.MakeSubMessage(assistant, 1, channel), expected: MSG_SUB},
 		{description: "ReSub Message", input: irctest.MakeResubMessage(assistant, 2, channel), expected: MSG_SUB},
 		{description: "GiftSub Message", input: irctest.MakeGiftSubMessage("ReallyFrank", "Fjoell", channel), expected: MSG_GIFTSUB},
+		{description: "Anonymous GiftSub Message", input: irctest.MakeAnonGiftSubMessage("Fjoell", channel), expected: MSG_GIFTSUB},
+		{description: "Mystery Gift Message", input: irctest.MakeMysteryGiftMessage("ReallyFrank", 5, channel), expected: MSG_MYSTERY_GIFT},
+		{description: "Gift Upgrade Message", input: irctest.MakeGiftPaidUpgradeMessage(assistant, "ReallyFrank", channel), expected: MSG_GIFT_UPGRADE},
+		{description: "Anonymous Gift Upgrade Message", input: irctest.MakeAnonGiftPaidUpgradeMessage(assistant, channel), expected: MSG_GIFT_UPGRADE},
+		{description: "Prime Upgrade Message", input: irctest.MakePrimePaidUpgradeMessage(assistant, channel), expected: MSG_PRIME_UPGRADE},
+		{description: "Bits Badge Message", input: irctest.MakeBitsBadgeTierMessage(assistant, 1000, channel), expected: MSG_BITS_BADGE},
+		{description: "Announcement Message", input: irctest.MakeAnnouncementMessage(assistant, "Stream starting!", channel), expected: MSG_ANNOUNCEMENT},
+		{description: "Unraid Message", input: irctest.MakeUnraidMessage(assistant, channel), expected: MSG_UNRAID},
+		{description: "Ritual Message", input: irctest.MakeRitualMessage(assistant, "HeyGuys", channel), expected: MSG_RITUAL},
 	}
 
 	for _, test := range tests {
 		t.Run(test.description, func(tt *testing.T) {
-			result := parseIrcLine(test.input).parseUserNoticeMessageType()
+			result := parseLine(test.input).parseUserNoticeMessageType()
 			if result != test.expected {
 				tt.Fatalf("Expected %d message type, but got %d for %s", test.expected, result, test.description)
 			}
@@ -204,5 +466,5 @@ func TestParseUserNoticeMessageType(t *testing.T) {
 // This test should not panic in such an event
 func TestEmptyTagDoesntExplode(t *testing.T) {
 	line := "@display-name=medgelabs; tmi.twitch.tv PRIVMSG :Trailing semicolon causes empty tag. Should not explode"
-	_ = parseIrcLine(line)
+	_, _ = parseIrcLine(line)
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	htmltemplate "html/template"
 	"medgebot/bot"
 	"medgebot/cache"
 	"medgebot/config"
@@ -15,7 +16,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
-	"text/template"
+	"time"
 
 	_ "embed"
 )
@@ -28,6 +29,18 @@ var metricsHTML string
 //go:embed pollBox.html
 var pollHTML string
 
+// Alert HTML for the on-screen Alert box
+//go:embed alertBox.html
+var alertHTML string
+
+// Goal HTML for on-screen Goal progress bars
+//go:embed goalBar.html
+var goalHTML string
+
+// Admin dashboard HTML
+//go:embed admin/index.html
+var adminHTML string
+
 func main() {
 
 	// CLI argument processing
@@ -60,6 +73,7 @@ func main() {
 	// Initialize desired state for the bot
 	chatBot := bot.New(dataStore)
 	chatBot.RegisterReadLogger()
+	chatBot.RegisterEventHistory(conf.HistorySize())
 
 	// Initialize Secrets Store
 	store, err := secret.NewSecretStore(conf)
@@ -105,9 +119,9 @@ func main() {
 	chatBot.RegisterClient(ircClient)
 	chatBot.SetChatClient(ircClient)
 
-	// TODO pubsub is only used for ChannelPoints at this time.
-	// If we use pubsub for other features, it wouldn't make sense to
-	// guard pubsub creation behind this feature flag
+	// PubSub is only used for ChannelPoints at this time, so it is only started
+	// when the feature is on. Channel Points can't be turned on at runtime otherwise
+	var pubsubClient *pubsub.PubSub
 	if conf.ChannelPointsEnabled() || enableAll {
 		pubSubWs := ws.NewWebSocket("wss", "pubsub-edge.twitch.tv")
 		err = pubSubWs.Connect()
@@ -115,117 +129,270 @@ func main() {
 			log.Fatal(err, "pubsub ws connect")
 		}
 
-		pubsub := pubsub.NewClient(pubSubWs, conf.ChannelID(), password)
-		pubSubWs.SetPostReconnectFunc(pubsub.Start)
-		pubsub.Start()
-		chatBot.RegisterClient(pubsub)
+		pubsubClient = pubsub.NewClient(pubSubWs, conf.ChannelID(), password)
+		pubSubWs.SetPostReconnectFunc(pubsubClient.Start)
+		pubsubClient.Start()
+		chatBot.RegisterClient(pubsubClient)
+	} else {
+		chatBot.SetFeatureUnavailable(bot.FeatureChannelPoints, "needs PubSub, which was not started")
 	}
 
 	// Shoutout Command
 	chatBot.HandleShoutoutCommand()
 
-	// Feature Toggles
-	if conf.CommandsEnabled() || enableAll {
-		cmds := conf.KnownCommands()
-		var commands []bot.Command
+	// Features - every handler is registered so features can be turned on and off
+	// at runtime through the admin API. The feature toggles decide the initial state
+	cmds := conf.KnownCommands()
+	var commands []bot.Command
 
-		for _, cmd := range cmds {
-			cmdTemplate, err := template.New(cmd.Prefix).Parse(cmd.Message)
-			if err != nil {
-				log.Fatal(err, "parse known Command [%+v]", cmd)
-			}
+	for _, cmd := range cmds {
+		command, err := bot.NewCommand(cmd.Prefix, cmd.Message, cmd.AliasFor)
+		if err != nil {
+			log.Fatal(err, "parse known Command [%+v]", cmd)
+		}
 
-			cmd := bot.Command{
-				Prefix:          cmd.Prefix,
-				IsAlias:         cmd.AliasFor != "",
-				AliasFor:        cmd.AliasFor,
-				MessageTemplate: bot.NewHandlerTemplate(cmdTemplate),
-			}
+		commands = append(commands, command)
+	}
 
-			commands = append(commands, cmd)
-		}
+	chatBot.HandleCommands(commands)
+
+	// Cache for the auto greeter
+	greeterCache := mustCreateFileCache("greeter.txt", conf.CacheExpirationTime())
+	greetedCache := mustCreateFileCache("greeted.txt", conf.GreetedExpirationTime())
 
-		chatBot.HandleCommands(commands)
+	// Greeter config
+	greetTempl, err := bot.ParseGreeterTemplate(bot.TemplateGreeter, conf.GreetMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid Greeter message in config")
 	}
 
-	// if config.greeterEnabled() {
-	if conf.GreeterEnabled() || enableAll {
-		// Cache for the auto greeter
-		greeterCache := mustCreateFileCache("greeter.txt", conf.CacheExpirationTime())
+	greetReturningTempl, err := bot.ParseGreeterTemplate(bot.TemplateGreeterReturning, conf.GreetReturningMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid Greeter returning message in config")
+	}
 
-		// pre-seed names we want ignored
-		greeterCache.Put("streamlabs", "")
-		greeterCache.Put("nightbot", "")
-		greeterCache.Put("soundalerts", "")
-		greeterCache.Put("jtv", "")
-		greeterCache.Put(strings.TrimPrefix(channel, "#"), "") // Prevent greeting the broadcaster
+	greetFirstMessageTempl, err := bot.ParseGreeterTemplate(bot.TemplateGreeterFirstMessage, conf.GreetFirstMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid Greeter first message in config")
+	}
 
-		// Greeter config
-		greetMessageFormat := conf.GreetMessageFormat()
-		greetTempl, err := template.New("greeter").Parse(greetMessageFormat)
-		if err != nil {
-			log.Fatal(err, "invalid Greeter message in config")
-		}
+	greeterIgnore := append(conf.GreeterIgnore(), strings.TrimPrefix(channel, "#")) // Prevent greeting the broadcaster
+	chatBot.RegisterGreeter(greeterCache, greetedCache, greeterIgnore, bot.Greetings{
+		First:        greetTempl,
+		Returning:    greetReturningTempl,
+		FirstMessage: greetFirstMessageTempl,
+	}, bot.GreetingBatch{
+		Window:        conf.GreetingWindow(),
+		MaxPerMessage: conf.GreetingMaxPerMessage(),
+	})
 
-		chatBot.RegisterGreeter(greeterCache, bot.NewHandlerTemplate(greetTempl))
+	raidTempl, err := bot.ParseRaidTemplate(bot.TemplateRaids, conf.RaidsMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid raid message in config")
 	}
 
-	if conf.RaidsEnabled() || enableAll {
-		raidMessageFormat := conf.RaidsMessageFormat()
-		raidDelay := conf.RaidDelay()
+	raidFollowUpTempl, err := bot.ParseRaidTemplate(bot.TemplateRaidsFollowUp, conf.RaidFollowUpMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid raid follow-up message in config")
+	}
 
-		raidTempl, err := template.New("raids").Parse(raidMessageFormat)
+	var raidTiers []bot.RaidTier
+	for _, tierConf := range conf.RaidTiers() {
+		tierTempl, err := bot.ParseRaidTemplate(bot.TemplateRaids, tierConf.MessageFormat)
 		if err != nil {
-			log.Fatal(err, "invalid raid message in config")
+			log.Fatal(err, "invalid raid tier message in config")
 		}
-		chatBot.RegisterRaidHandler(
-			bot.NewHandlerTemplate(raidTempl), raidDelay)
+		raidTiers = append(raidTiers, bot.RaidTier{MinViewers: tierConf.MinViewers, Template: tierTempl})
 	}
 
-	if conf.BitsEnabled() || enableAll {
-		bitsMessageFormat := conf.BitsMessageFormat()
+	chatBot.RegisterRaidHandler(raidTempl, bot.RaidOptions{
+		Delay:         time.Duration(conf.RaidDelay()) * time.Second,
+		Tiers:         raidTiers,
+		Shoutout:      conf.RaidShoutout(),
+		FollowUp:      raidFollowUpTempl,
+		FollowUpDelay: time.Duration(conf.RaidFollowUpDelay()) * time.Second,
+	})
+
+	bitsTempl, err := bot.ParseHandlerTemplate(bot.TemplateBits, conf.BitsMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid bits message in config")
+	}
 
-		bitsTempl, err := template.New("bits").Parse(bitsMessageFormat)
+	var bitsTiers []bot.BitsTier
+	for _, tierConf := range conf.BitsTiers() {
+		tierTempl, err := bot.ParseHandlerTemplate(bot.TemplateBits, tierConf.MessageFormat)
 		if err != nil {
-			log.Fatal(err, "invalid bits message in config")
+			log.Fatal(err, "invalid bits tier message in config")
 		}
+		bitsTiers = append(bitsTiers, bot.BitsTier{MinBits: tierConf.MinBits, Template: tierTempl})
+	}
+	chatBot.RegisterBitsHandler(bitsTempl, bitsTiers)
+
+	subsTempl, err := bot.ParseHandlerTemplate(bot.TemplateSubs, conf.SubsMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid subs message in config")
+	}
 
-		chatBot.RegisterBitsHandler(
-			bot.NewHandlerTemplate(bitsTempl))
+	giftSubsTempl, err := bot.ParseHandlerTemplate(bot.TemplateGiftSubs, conf.GiftSubsMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid gift subs message in config")
+	}
+
+	giftBombsTempl, err := bot.ParseHandlerTemplate(bot.TemplateGiftBombs, conf.GiftBombsMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid gift bomb message in config")
 	}
 
-	if conf.SubsEnabled() || enableAll {
-		subsMessageFormat := conf.SubsMessageFormat()
-		subsTempl, err := template.New("subs").Parse(subsMessageFormat)
+	var subTiers bot.SubTierTemplates
+	subTierFormats := []struct {
+		name   string
+		format string
+		dest   *bot.HandlerTemplate
+	}{
+		{bot.TemplateSubsPrime, conf.SubsTierMessageFormat("prime"), &subTiers.Prime},
+		{bot.TemplateSubsTier2, conf.SubsTierMessageFormat("tier2"), &subTiers.Tier2},
+		{bot.TemplateSubsTier3, conf.SubsTierMessageFormat("tier3"), &subTiers.Tier3},
+		{bot.TemplateGiftSubsTier2, conf.GiftSubsTierMessageFormat("tier2"), &subTiers.GiftTier2},
+		{bot.TemplateGiftSubsTier3, conf.GiftSubsTierMessageFormat("tier3"), &subTiers.GiftTier3},
+	}
+	for _, tier := range subTierFormats {
+		*tier.dest, err = bot.ParseHandlerTemplate(tier.name, tier.format)
 		if err != nil {
-			log.Fatal(err, "invalid subs message in config")
+			log.Fatal(err, "invalid %s message in config", tier.name)
 		}
+	}
+	chatBot.RegisterSubsHandler(subsTempl, giftSubsTempl, giftBombsTempl, subTiers)
 
-		giftSubsMessageFormat := conf.GiftSubsMessageFormat()
-		giftSubsTempl, err := template.New("giftsubs").Parse(giftSubsMessageFormat)
+	var goals []bot.Goal
+	for _, goalConf := range conf.Goals() {
+		goal, err := bot.NewGoal(goalConf.Name, goalConf.Type, goalConf.Title, goalConf.Target, goalConf.Milestones)
 		if err != nil {
-			log.Fatal(err, "invalid subs message in config")
+			log.Fatal(err, "invalid goal in config")
 		}
+		goals = append(goals, goal)
+	}
 
-		chatBot.RegisterSubsHandler(
-			bot.NewHandlerTemplate(subsTempl), bot.NewHandlerTemplate(giftSubsTempl))
+	goalsTempl, err := bot.ParseGoalTemplate(conf.GoalsMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid goals message in config")
 	}
+	chatBot.RegisterGoalHandler(goals, goalsTempl)
 
-	if conf.PollsEnabled() || enableAll {
-		chatBot.RegisterPollHandler()
+	hypeTempl, err := bot.ParseHandlerTemplate(bot.TemplateHype, conf.HypeMessageFormat())
+	if err != nil {
+		log.Fatal(err, "invalid hype message in config")
 	}
+	chatBot.RegisterHypeDetector(hypeTempl, bot.HypeOptions{
+		Window:    conf.HypeWindow(),
+		Levels:    conf.HypeLevels(),
+		SubPoints: conf.HypeSubPoints(),
+	})
 
-	// Start the Bot only after all handlers are loaded
-	if err := chatBot.Start(); err != nil {
-		log.Fatal(err, "bot connect")
+	chatBot.RegisterPollHandler()
+	chatBot.RegisterChannelPointHandler()
+
+	// Feature Toggles
+	toggles := map[string]bool{
+		bot.FeatureCommands:      conf.CommandsEnabled(),
+		bot.FeatureGreeter:       conf.GreeterEnabled(),
+		bot.FeatureRaids:         conf.RaidsEnabled(),
+		bot.FeatureBits:          conf.BitsEnabled(),
+		bot.FeatureSubs:          conf.SubsEnabled(),
+		bot.FeaturePolls:         conf.PollsEnabled(),
+		bot.FeatureGoals:         conf.GoalsEnabled(),
+		bot.FeatureHype:          conf.HypeEnabled(),
+		bot.FeatureChannelPoints: conf.ChannelPointsEnabled(),
+	}
+	for feature, enabled := range toggles {
+		if err := chatBot.SetFeatureEnabled(feature, enabled || enableAll); err != nil {
+			log.Fatal(err, "toggle feature %s", feature)
+		}
 	}
 
-	// Start HTTP server
+	// HTTP server
 	// NOTE: Make sure the cache is the same as the Bot
 	debugClient := server.DebugClient{}
 	chatBot.RegisterClient(&debugClient)
 
-	srv := server.New(&chatBot, dataStore, &debugClient, metricsHTML, pollHTML)
+	srv := server.New(&chatBot, dataStore, &debugClient, server.Views{
+		MetricLabel: metricsHTML,
+		Poll:        pollHTML,
+		Alert:       alertHTML,
+		Goal:        goalHTML,
+		Admin:       adminHTML,
+	})
+	srv.SetBaseURL(conf.ServerBaseURL())
+	srv.RequireOverlayAuth(conf.OverlayAuthRequired())
+	srv.SetConfig(&conf)
+
+	if overlayDir := conf.OverlayDir(); overlayDir != "" {
+		if err := srv.SetOverlayDir(overlayDir); err != nil {
+			log.Fatal(err, "load overlay directory")
+		}
+	}
+
+	apiTokens, err := store.APITokens()
+	if err != nil {
+		log.Fatal(err, "Get API tokens from store")
+	}
+	if len(apiTokens) == 0 {
+		log.Warn("No API tokens configured. Admin and debug HTTP routes are disabled")
+	}
+	srv.SetAPITokens(apiTokens)
+
+	if conf.AlertsEnabled() || enableAll {
+		styles := make(map[int]server.AlertStyle)
+		for typeName, alertConf := range conf.Alerts() {
+			eventType, ok := bot.ParseEventType(typeName)
+			if !ok {
+				log.Fatal(nil, "unknown alert type in config - %s", typeName)
+			}
+
+			alertTempl, err := htmltemplate.New(typeName).Parse(alertConf.MessageFormat)
+			if err != nil {
+				log.Fatal(err, "invalid %s alert message in config", typeName)
+			}
+
+			styles[eventType] = server.AlertStyle{
+				Template: alertTempl,
+				CSS:      alertConf.CSS,
+				Sound:    alertConf.Sound,
+				Duration: time.Duration(alertConf.DurationSeconds) * time.Second,
+			}
+		}
+
+		if err := srv.EnableAlerts(styles); err != nil {
+			log.Fatal(err, "enable alerts")
+		}
+	}
+
+	// Health checks. Liveness failures need a restart, readiness may recover on its own
+	maxSilence := conf.HealthMaxSilence()
+	handlerTimeout := conf.HealthHandlerTimeout()
+
+	srv.AddLivenessCheck("bot", func() error {
+		return chatBot.Healthy(handlerTimeout)
+	})
+	srv.AddLivenessCheck("cache", dataStore.Healthy)
+	srv.AddLivenessCheck("greeterCache", greeterCache.Healthy)
+	srv.AddLivenessCheck("greetedCache", greetedCache.Healthy)
+	srv.AddLivenessCheck("irc", ircClient.Healthy)
+	srv.AddReadinessCheck("irc", func() error {
+		return ircClient.Ready(maxSilence)
+	})
+	if pubsubClient != nil {
+		srv.AddLivenessCheck("pubsub", pubsubClient.Healthy)
+		srv.AddReadinessCheck("pubsub", func() error {
+			return pubsubClient.Ready(maxSilence)
+		})
+	}
+
+	// Start the Bot only after all handlers are loaded
+	if err := chatBot.Start(); err != nil {
+		log.Fatal(err, "bot connect")
+	}
+
+	// Start HTTP server
 	if err := http.ListenAndServe(fmt.Sprintf("%s:%s", listenAddr, listenPort), srv); err != nil {
 		log.Fatal(err, "start HTTP server")
 	}
@@ -1 +1 @@
-curl -X POST -d '{"question": "Is the Poll working?", "answers": ["Yes", "No"]}' http://localhost:8080/poll
+curl -X POST -H "Authorization: Bearer $API_TOKEN" -d '{"question": "Is the Poll working?", "answers": ["Yes", "No"], "minutes": 3}' http://localhost:8080/poll
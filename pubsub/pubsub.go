@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"medgebot/bot"
+	"medgebot/irc"
 	log "medgebot/logger"
+	"medgebot/telemetry"
 	"strings"
 	"sync"
 	"time"
@@ -16,21 +18,25 @@ import (
 )
 
 const (
-	// MaxMessageSize defines the maximum size of a message that can be received.
-	// This is used to size the message buffer slice for the io.Read method
-	MaxMessageSize = 2048 // bytes
-
 	// PingInterval for the Ping/Pong loop
 	PingInterval = 4 * time.Minute
 
 	ChannelPointTopic = "channel-points-channel-v1"
 )
 
+var (
+	pubsubConnects = telemetry.NewCounter("medgebot_pubsub_connects_total",
+		"Times the PubSub client started, including after reconnects")
+	pubsubMessages = telemetry.NewCounter("medgebot_pubsub_messages_received_total",
+		"Messages received from PubSub, by type", "type")
+)
+
 // PubSub wraps the websocket connection to Twitch PubSub and acts as a
 // Producer of ChannelPoint messages. We do NOT send messages to PubSub
 type PubSub struct {
 	sync.Mutex
 	conn           io.ReadWriteCloser
+	frames         *irc.LineReader
 	outboundEvents chan<- bot.Event
 
 	// For reconnect purposes
@@ -38,12 +44,20 @@ type PubSub struct {
 	authToken    string
 	serverScheme string
 	serverHost   string
+
+	// Connection state reported by Healthy and Ready. Reset by Start
+	stateMu     sync.Mutex
+	listening   bool
+	listenErr   error
+	readErr     error
+	lastMessage time.Time
 }
 
 // NewClient creates a non-connected Client
 func NewClient(conn io.ReadWriteCloser, channelID, authToken string) *PubSub {
 	return &PubSub{
 		conn:      conn,
+		frames:    irc.NewLineReader(conn),
 		channelID: channelID,
 		authToken: authToken,
 	}
@@ -51,11 +65,22 @@ func NewClient(conn io.ReadWriteCloser, channelID, authToken string) *PubSub {
 
 // Start actually connects to PubSub and starts the read loops
 func (client *PubSub) Start() error {
+	pubsubConnects.Inc()
+
+	client.stateMu.Lock()
+	client.listening = false
+	client.listenErr = nil
+	client.readErr = nil
+	client.stateMu.Unlock()
+
 	// Read loop for receiving messages
 	go func() {
 		for {
 			if err := client.read(); err != nil {
 				log.Error(err, "pubsub read")
+				client.stateMu.Lock()
+				client.readErr = err
+				client.stateMu.Unlock()
 				break
 			}
 		}
@@ -119,19 +144,10 @@ func (client *PubSub) ping() {
 
 // Read reads from the PubSub stream
 func (client *PubSub) read() error {
-	buff := make([]byte, MaxMessageSize)
-	len, err := client.conn.Read(buff)
+	buff, err := client.frames.ReadFrame()
 	if err != nil {
 		return errors.Wrap(err, "read pubsub")
 	}
-
-	if len == 0 {
-		log.Warn("Empty message buffer from PubSub")
-		return errors.New("Empty message buffer")
-	}
-
-	// Remove NULL bytes (bytes left over in the buffer), courtesy of ReallyFrank!
-	buff = buff[:len]
 	str := string(buff)
 	log.Info("PubSub: " + str)
 
@@ -141,10 +157,24 @@ func (client *PubSub) read() error {
 		log.Warn("type field not found. Got message: " + str)
 		return errors.Wrap(err, "pubsub unknown message format")
 	}
+	pubsubMessages.Inc(msgType)
+
+	client.stateMu.Lock()
+	client.lastMessage = time.Now()
+	client.stateMu.Unlock()
 
 	switch msgType {
 	case "RESPONSE":
-		// Ignore as this is a response to the LISTEN command
+		// Response to the LISTEN command. A non-empty error means we aren't subscribed
+		listenErr, _ := jsonparser.GetString(buff, "error")
+
+		client.stateMu.Lock()
+		if listenErr != "" {
+			client.listenErr = errors.New(listenErr)
+		} else {
+			client.listening = true
+		}
+		client.stateMu.Unlock()
 
 	case "PONG":
 		// Ignore, as this is handled by the PingPong goroutine
@@ -184,6 +214,44 @@ func (client *PubSub) read() error {
 	return nil
 }
 
+// Healthy returns an error if the LISTEN was rejected or the read loop stopped,
+// in which case no more messages will be received without a restart
+func (client *PubSub) Healthy() error {
+	client.stateMu.Lock()
+	defer client.stateMu.Unlock()
+
+	if client.listenErr != nil {
+		return errors.Wrap(client.listenErr, "pubsub listen rejected")
+	}
+
+	if client.readErr != nil {
+		return errors.Wrap(client.readErr, "pubsub read loop stopped")
+	}
+
+	return nil
+}
+
+// Ready returns an error unless the client is healthy, listening and has received a
+// message within maxSilence. A PONG is expected every PingInterval
+func (client *PubSub) Ready(maxSilence time.Duration) error {
+	if err := client.Healthy(); err != nil {
+		return err
+	}
+
+	client.stateMu.Lock()
+	defer client.stateMu.Unlock()
+
+	if !client.listening {
+		return errors.New("pubsub not listening")
+	}
+
+	if silence := time.Since(client.lastMessage); silence > maxSilence {
+		return errors.Errorf("pubsub last message %s ago", silence.Round(time.Second))
+	}
+
+	return nil
+}
+
 func (client *PubSub) handleChannelPointRedemption(msg ChannelPointRedemption) {
 	evt := bot.NewPointsEvent()
 	evt.Title = msg.Data.Redemption.Reward.Title
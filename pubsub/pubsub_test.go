@@ -1,9 +1,11 @@
 package pubsub
 
 import (
+	"encoding/json"
 	"fmt"
 	"medgebot/bot"
 	"medgebot/ws/wstest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -87,3 +89,78 @@ func TestMessageReceivedFromServer(t *testing.T) {
 		t.Fatalf("Timeout while waiting to receive expected message")
 	}
 }
+
+func TestLargeMessageNotTruncated(t *testing.T) {
+	conn := wstest.NewWebsocket()
+	client := NewClient(conn, "testChannelID", "testAuthToken")
+	testBot := make(chan bot.Event)
+	client.SetDestination(testBot)
+	client.Start()
+
+	// Well over the size of a fixed read buffer
+	userInput := strings.Repeat("Hydrate! ", 500)
+	redemption := fmt.Sprintf(`{"type": "reward-redeemed", "data": {"redemption": {
+		"user": {"display_name": "testUser"},
+		"reward": {"title": "Say something", "cost": 100},
+		"user_input": %q
+	}}}`, userInput)
+	message, _ := json.Marshal(redemption)
+	conn.Send(fmt.Sprintf(`{"type": "MESSAGE", "data": {"topic": "%s.testChannelID", "message": %s}}`,
+		ChannelPointTopic, message))
+
+	select {
+	case evt := <-testBot:
+		if evt.Message != userInput {
+			t.Fatalf("Expected the whole user input (%d bytes), got %d bytes", len(userInput), len(evt.Message))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timeout while waiting to receive expected message")
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	conn := wstest.NewWebsocket()
+	client := NewClient(conn, "testChannelID", "testAuthToken")
+	client.SetDestination(make(chan bot.Event, 10))
+	client.Start()
+
+	if err := client.Ready(time.Minute); err == nil {
+		t.Fatalf("Expected not ready before the LISTEN response")
+	}
+
+	conn.Send(`{"type": "RESPONSE", "nonce": "", "error": ""}`)
+	waitFor(t, func() error { return client.Ready(time.Minute) })
+}
+
+func TestListenRejected(t *testing.T) {
+	conn := wstest.NewWebsocket()
+	client := NewClient(conn, "testChannelID", "badAuthToken")
+	client.SetDestination(make(chan bot.Event, 10))
+	client.Start()
+
+	conn.Send(`{"type": "RESPONSE", "nonce": "", "error": "ERR_BADAUTH"}`)
+	waitFor(t, func() error {
+		if client.Healthy() == nil {
+			return fmt.Errorf("still healthy")
+		}
+		return nil
+	})
+}
+
+// waitFor retries check until it passes, failing the test after a few seconds
+func waitFor(t *testing.T, check func() error) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		err := check()
+		if err == nil {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met in time - %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
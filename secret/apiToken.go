@@ -0,0 +1,45 @@
+package secret
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// APIToken is a bearer token for the HTTP API, limited to the given scopes.
+// Name identifies who holds the token in audit logs
+type APIToken struct {
+	Name   string
+	Token  string
+	Scopes []string
+}
+
+// ParseAPITokens parses a comma-separated list of name:token:scope1|scope2 entries.
+// An empty string is valid and results in no tokens
+func ParseAPITokens(str string) ([]APIToken, error) {
+	var tokens []APIToken
+	for _, entry := range strings.Split(str, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return nil, errors.Errorf("invalid API token entry, expected name:token:scopes - got %d fields", len(parts))
+		}
+
+		name, token, scopes := parts[0], parts[1], parts[2]
+		if name == "" || token == "" || scopes == "" {
+			return nil, errors.Errorf("API token entry %s has an empty name, token or scopes", name)
+		}
+
+		tokens = append(tokens, APIToken{
+			Name:   name,
+			Token:  token,
+			Scopes: strings.Split(scopes, "|"),
+		})
+	}
+
+	return tokens, nil
+}
@@ -8,11 +8,13 @@ import (
 // environment variables
 type EnvStore struct {
 	twitchToken string
+	apiTokens   string
 }
 
-func NewEnvStore(twitchToken string) EnvStore {
+func NewEnvStore(twitchToken, apiTokens string) EnvStore {
 	return EnvStore{
 		twitchToken: twitchToken,
+		apiTokens:   apiTokens,
 	}
 }
 
@@ -23,3 +25,9 @@ func (s EnvStore) TwitchToken() (string, error) {
 
 	return s.twitchToken, nil
 }
+
+// APITokens parses the API tokens from the env. No tokens is not an error,
+// but leaves every protected HTTP route inaccessible
+func (s EnvStore) APITokens() ([]APIToken, error) {
+	return ParseAPITokens(s.apiTokens)
+}
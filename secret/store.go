@@ -3,4 +3,5 @@ package secret
 // Store fetches secrets needed for Bot integrations
 type Store interface {
 	TwitchToken() (string, error)
+	APITokens() ([]APIToken, error)
 }
@@ -22,7 +22,8 @@ func NewSecretStore(config config.Config) (Store, error) {
 	switch strings.ToLower(storeType) {
 	case ENV:
 		twitchToken := config.TwitchToken()
-		store := NewEnvStore(twitchToken)
+		apiTokens := config.APITokens()
+		store := NewEnvStore(twitchToken, apiTokens)
 		return &store, nil
 	default:
 		return nil, fmt.Errorf("Invalid storeType - %s. Valid values are: %v", storeType, []string{ENV})
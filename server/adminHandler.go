@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot"
+	"medgebot/config"
+	"medgebot/logger"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+)
+
+// SetConfig links the Config that runtime changes made through the admin API are
+// persisted to. Without it, changes are lost on restart
+func (s *Server) SetConfig(conf *config.Config) {
+	s.config = conf
+}
+
+// persist applies the change to the Config and saves it. Responds with an error
+// and returns false if it could not be saved
+func (s *Server) persist(w http.ResponseWriter, change func(conf *config.Config)) bool {
+	if s.config == nil {
+		logger.Warn("No Config set on Server. Admin change will not survive a restart")
+		return true
+	}
+
+	change(s.config)
+	if err := s.config.Save(); err != nil {
+		logger.Error(err, "persist admin change")
+		s.WriteError(w, 500, "Change applied but could not be saved")
+		return false
+	}
+
+	return true
+}
+
+// fetchConfig returns the current configuration for the channel
+func (s *Server) fetchConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.config == nil {
+			s.WriteError(w, 404, "No config loaded")
+			return
+		}
+
+		s.WriteJSON(w, 200, s.config.Settings())
+	}
+}
+
+// fetchFeatures returns the on/off state of each feature
+func (s *Server) fetchFeatures() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.WriteJSON(w, 200, s.bot.Features())
+	}
+}
+
+// updateFeature turns a feature on or off
+func (s *Server) updateFeature() http.HandlerFunc {
+	type request struct {
+		Enabled *bool `json:"enabled"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		feature := chi.URLParam(r, "feature")
+
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			s.WriteError(w, 400, "request body needs enabled: true|false")
+			return
+		}
+
+		if err := s.bot.SetFeatureEnabled(feature, *req.Enabled); err != nil {
+			status := 404
+			if errors.Cause(err) == bot.ErrFeatureUnavailable {
+				status = 409
+			}
+			s.WriteError(w, status, err.Error())
+			return
+		}
+
+		if !s.persist(w, func(conf *config.Config) {
+			conf.SetFeatureEnabled(feature, *req.Enabled)
+		}) {
+			return
+		}
+
+		s.WriteJSON(w, 200, s.bot.Features())
+	}
+}
+
+// fetchTemplates returns the messageFormat of each message template
+func (s *Server) fetchTemplates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.WriteJSON(w, 200, s.bot.MessageFormats())
+	}
+}
+
+// updateTemplate validates and replaces a message template
+func (s *Server) updateTemplate() http.HandlerFunc {
+	type request struct {
+		MessageFormat string `json:"messageFormat"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		name := chi.URLParam(r, "name")
+
+		if _, known := s.bot.MessageFormats()[name]; !known {
+			s.WriteError(w, 404, "Unknown template "+name)
+			return
+		}
+
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.WriteError(w, 400, "Invalid request body")
+			return
+		}
+
+		if err := s.bot.SetMessageFormat(name, req.MessageFormat); err != nil {
+			s.WriteError(w, 400, err.Error())
+			return
+		}
+
+		if !s.persist(w, func(conf *config.Config) {
+			conf.SetMessageFormat(name, req.MessageFormat)
+		}) {
+			return
+		}
+
+		s.WriteJSON(w, 200, s.bot.MessageFormats())
+	}
+}
+
+// commandBody is the request and response body for Commands
+type commandBody struct {
+	Prefix   string `json:"prefix"`
+	Message  string `json:"message,omitempty"`
+	AliasFor string `json:"aliasFor,omitempty"`
+}
+
+func toCommandBody(cmd bot.Command) commandBody {
+	body := commandBody{
+		Prefix:   cmd.Prefix,
+		AliasFor: cmd.AliasFor,
+	}
+	if !cmd.IsAlias {
+		body.Message = cmd.MessageFormat()
+	}
+
+	return body
+}
+
+// persistCommands saves the Bot's current Commands
+func (s *Server) persistCommands(w http.ResponseWriter) bool {
+	var known []config.KnownCommand
+	for _, cmd := range s.bot.Commands() {
+		body := toCommandBody(cmd)
+		known = append(known, config.KnownCommand{
+			Prefix:   body.Prefix,
+			Message:  body.Message,
+			AliasFor: body.AliasFor,
+		})
+	}
+
+	return s.persist(w, func(conf *config.Config) {
+		conf.SetKnownCommands(known)
+	})
+}
+
+// fetchCommands lists the known Commands
+func (s *Server) fetchCommands() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		commands := []commandBody{}
+		for _, cmd := range s.bot.Commands() {
+			commands = append(commands, toCommandBody(cmd))
+		}
+
+		s.WriteJSON(w, 200, commands)
+	}
+}
+
+// saveCommand creates a Command, or replaces it if routed with a {prefix}
+func (s *Server) saveCommand() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		var req commandBody
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.WriteError(w, 400, "Invalid request body")
+			return
+		}
+
+		// PUT /api/commands/{prefix} updates, so the path decides which Command
+		replacing := chi.URLParam(r, "prefix") != ""
+		if replacing {
+			req.Prefix = chi.URLParam(r, "prefix")
+		} else if _, exists := s.bot.Command(req.Prefix); exists {
+			s.WriteError(w, 409, "Command already exists: "+req.Prefix)
+			return
+		}
+
+		cmd, err := bot.NewCommand(req.Prefix, req.Message, req.AliasFor)
+		if err != nil {
+			s.WriteError(w, 400, err.Error())
+			return
+		}
+
+		s.bot.SetCommand(cmd)
+		if !s.persistCommands(w) {
+			return
+		}
+
+		status := 201
+		if replacing {
+			status = 200
+		}
+		s.WriteJSON(w, status, toCommandBody(cmd))
+	}
+}
+
+// deleteCommand removes a Command
+func (s *Server) deleteCommand() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		prefix := chi.URLParam(r, "prefix")
+		if !s.bot.RemoveCommand(prefix) {
+			s.WriteError(w, 404, "Unknown command "+prefix)
+			return
+		}
+
+		if !s.persistCommands(w) {
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
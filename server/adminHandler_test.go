@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"medgebot/bot"
+	"medgebot/cache"
+	"medgebot/config"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const adminTestConfig = `medgelabs:
+  bits:
+    enabled: true
+    messageFormat: "Thanks for the {{.Amount}} bits {{.Sender}}"
+  commands:
+    enabled: true
+    known:
+      - prefix: "!hello"
+        message: "WORLD"
+`
+
+// newAdminTestRouter routes the admin API to a Bot with bits and commands, persisting
+// changes to a config.yaml in dir
+func newAdminTestRouter(t *testing.T, dir string) *chi.Mux {
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(adminTestConfig), 0644); err != nil {
+		t.Fatalf("Failed to write config.yaml: %v", err)
+	}
+
+	conf, err := config.New("medgelabs", dir)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+
+	bitsTemplate, err := bot.ParseHandlerTemplate(bot.TemplateBits, conf.BitsMessageFormat())
+	if err != nil {
+		t.Fatalf("Failed to parse bits template: %v", err)
+	}
+	chatBot.RegisterBitsHandler(bitsTemplate, nil)
+
+	hello, _ := bot.NewCommand("!hello", "WORLD", "")
+	chatBot.HandleCommands([]bot.Command{hello})
+
+	srv := &Server{bot: &chatBot}
+	srv.SetConfig(&conf)
+
+	router := chi.NewRouter()
+	router.Get("/api/features", srv.fetchFeatures())
+	router.Put("/api/features/{feature}", srv.updateFeature())
+	router.Get("/api/templates", srv.fetchTemplates())
+	router.Put("/api/templates/{name}", srv.updateTemplate())
+	router.Get("/api/commands", srv.fetchCommands())
+	router.Post("/api/commands", srv.saveCommand())
+	router.Put("/api/commands/{prefix}", srv.saveCommand())
+	router.Delete("/api/commands/{prefix}", srv.deleteCommand())
+
+	return router
+}
+
+func sendAdmin(router *chi.Mux, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+// reloadConfig reads the config.yaml saved in dir, as the Bot would after a restart
+func reloadConfig(t *testing.T, dir string) *config.Config {
+	conf, err := config.New("medgelabs", dir)
+	if err != nil {
+		t.Fatalf("Failed to reload saved config: %v", err)
+	}
+
+	return &conf
+}
+
+func TestAdminFeatures(t *testing.T) {
+	dir := t.TempDir()
+	router := newAdminTestRouter(t, dir)
+
+	resp := sendAdmin(router, "PUT", "/api/features/bits", `{"enabled": false}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200 turning bits off, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var features map[string]bool
+	json.NewDecoder(sendAdmin(router, "GET", "/api/features", "").Body).Decode(&features)
+	if enabled, known := features[bot.FeatureBits]; !known || enabled {
+		t.Fatalf("Expected bits to be off, got %v", features)
+	}
+
+	if reloadConfig(t, dir).BitsEnabled() {
+		t.Fatalf("Expected bits to be saved as off")
+	}
+
+	if resp := sendAdmin(router, "PUT", "/api/features/bits", `{}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 without enabled, got %d", resp.Code)
+	}
+
+	if resp := sendAdmin(router, "PUT", "/api/features/nope", `{"enabled": true}`); resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for an unknown feature, got %d", resp.Code)
+	}
+}
+
+func TestAdminTemplates(t *testing.T) {
+	dir := t.TempDir()
+	router := newAdminTestRouter(t, dir)
+
+	format := "{{.Sender}} cheered {{.Amount}}!"
+	resp := sendAdmin(router, "PUT", "/api/templates/bits", `{"messageFormat": "`+format+`"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200 changing the bits template, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var formats map[string]string
+	json.NewDecoder(sendAdmin(router, "GET", "/api/templates", "").Body).Decode(&formats)
+	if formats[bot.TemplateBits] != format {
+		t.Fatalf("Expected the new bits template, got %v", formats)
+	}
+
+	if saved := reloadConfig(t, dir).BitsMessageFormat(); saved != format {
+		t.Fatalf("Expected the bits template to be saved, got %q", saved)
+	}
+
+	// Invalid templates are neither used nor saved
+	if resp := sendAdmin(router, "PUT", "/api/templates/bits", `{"messageFormat": "{{.NotAField}}"}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an invalid template, got %d", resp.Code)
+	}
+	if saved := reloadConfig(t, dir).BitsMessageFormat(); saved != format {
+		t.Fatalf("Expected the invalid template not to be saved, got %q", saved)
+	}
+
+	if resp := sendAdmin(router, "PUT", "/api/templates/nope", `{"messageFormat": "hi"}`); resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for an unknown template, got %d", resp.Code)
+	}
+}
+
+func TestAdminCommands(t *testing.T) {
+	dir := t.TempDir()
+	router := newAdminTestRouter(t, dir)
+
+	resp := sendAdmin(router, "POST", "/api/commands", `{"prefix": "!lurk", "message": "Enjoy the lurk {{.Sender}}"}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a command, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if resp := sendAdmin(router, "POST", "/api/commands", `{"prefix": "!lurk", "message": "again"}`); resp.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 creating an existing command, got %d", resp.Code)
+	}
+
+	resp = sendAdmin(router, "PUT", "/api/commands/!hi", `{"aliasFor": "!hello"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200 saving an alias, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if resp := sendAdmin(router, "DELETE", "/api/commands/!hello", ""); resp.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 deleting a command, got %d", resp.Code)
+	}
+
+	if resp := sendAdmin(router, "DELETE", "/api/commands/!nope", ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 deleting an unknown command, got %d", resp.Code)
+	}
+
+	var commands []commandBody
+	json.NewDecoder(sendAdmin(router, "GET", "/api/commands", "").Body).Decode(&commands)
+	expected := []commandBody{
+		{Prefix: "!hi", AliasFor: "!hello"},
+		{Prefix: "!lurk", Message: "Enjoy the lurk {{.Sender}}"},
+	}
+	if !reflect.DeepEqual(sortedCommands(commands), expected) {
+		t.Fatalf("Expected commands %+v, got %+v", expected, commands)
+	}
+
+	saved := make([]commandBody, 0)
+	for _, cmd := range reloadConfig(t, dir).KnownCommands() {
+		saved = append(saved, commandBody{Prefix: cmd.Prefix, Message: cmd.Message, AliasFor: cmd.AliasFor})
+	}
+	if !reflect.DeepEqual(sortedCommands(saved), expected) {
+		t.Fatalf("Expected saved commands %+v, got %+v", expected, saved)
+	}
+}
+
+// sortedCommands orders the commands by prefix, for comparison
+func sortedCommands(commands []commandBody) []commandBody {
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Prefix < commands[j].Prefix
+	})
+
+	return commands
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"html/template"
+	"medgebot/bot"
+	"medgebot/logger"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// AlertTopic is the stream topic alerts are pushed on
+	AlertTopic = "alert"
+
+	// alertHistorySize is the number of shown alerts kept for replay
+	alertHistorySize = 50
+
+	// defaultAlertDuration is used when an AlertStyle has no Duration
+	defaultAlertDuration = 5 * time.Second
+)
+
+// AlertStyle configures how alerts for one Event type are rendered.
+// Template is executed with the bot.Event, same as the chat message templates
+type AlertStyle struct {
+	Template *template.Template
+	CSS      string
+	Sound    string
+	Duration time.Duration
+}
+
+// alert is a rendered alert, as pushed to the alert overlay
+type alert struct {
+	ID         int       `json:"id"`
+	Type       string    `json:"type"`
+	HTML       string    `json:"html"`
+	CSS        string    `json:"css"`
+	Sound      string    `json:"sound,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	Created    time.Time `json:"created"`
+}
+
+// alertQueue shows queued alerts one at a time
+type alertQueue struct {
+	sync.Mutex
+	styles  map[int]AlertStyle
+	pending []alert
+	current *alert
+	history []alert
+	nextID  int
+
+	// wake signals the run loop that an alert was queued
+	wake chan struct{}
+
+	// skip ends the current alert early. Only sent while an alert is current, and
+	// drained when the next one is, so a skip never carries over to another alert
+	skip chan struct{}
+}
+
+func newAlertQueue(styles map[int]AlertStyle) *alertQueue {
+	return &alertQueue{
+		styles:  styles,
+		pending: make([]alert, 0),
+		history: make([]alert, 0),
+		nextID:  1,
+		wake:    make(chan struct{}, 1),
+		skip:    make(chan struct{}, 1),
+	}
+}
+
+// enqueue renders the Event with its AlertStyle and queues it. Events without
+// an AlertStyle are ignored
+func (q *alertQueue) enqueue(evt bot.Event) {
+	style, ok := q.styles[evt.Type]
+	if !ok || style.Template == nil {
+		return
+	}
+
+	var html strings.Builder
+	if err := style.Template.Execute(&html, evt); err != nil {
+		logger.Error(err, "alert template execute for %s", evt.TypeName())
+		return
+	}
+
+	duration := style.Duration
+	if duration <= 0 {
+		duration = defaultAlertDuration
+	}
+
+	q.Lock()
+	q.push(alert{
+		Type:       evt.TypeName(),
+		HTML:       html.String(),
+		CSS:        style.CSS,
+		Sound:      style.Sound,
+		DurationMs: duration.Milliseconds(),
+		Created:    time.Now(),
+	})
+	q.Unlock()
+}
+
+// push assigns the alert an ID and queues it. Caller must hold the lock
+func (q *alertQueue) push(a alert) {
+	a.ID = q.nextID
+	q.nextID++
+	q.pending = append(q.pending, a)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// replay queues a copy of a previously shown alert. Returns false if not in history
+func (q *alertQueue) replay(id int) bool {
+	q.Lock()
+	defer q.Unlock()
+
+	for _, a := range q.history {
+		if a.ID == id {
+			a.Created = time.Now()
+			q.push(a)
+			return true
+		}
+	}
+
+	return false
+}
+
+// skipCurrent ends the current alert, if one is showing
+func (q *alertQueue) skipCurrent() bool {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.current == nil {
+		return false
+	}
+
+	select {
+	case q.skip <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+// next pops the next pending alert and marks it as current
+func (q *alertQueue) next() (alert, bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.pending) == 0 {
+		return alert{}, false
+	}
+
+	// Drop any stale skip left from the previous alert, before this one can be skipped
+	select {
+	case <-q.skip:
+	default:
+	}
+
+	a := q.pending[0]
+	q.pending = q.pending[1:]
+	q.current = &a
+	return a, true
+}
+
+// finish moves the current alert to the history
+func (q *alertQueue) finish() {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.current == nil {
+		return
+	}
+
+	q.history = append(q.history, *q.current)
+	if len(q.history) > alertHistorySize {
+		q.history = q.history[len(q.history)-alertHistorySize:]
+	}
+	q.current = nil
+}
+
+// run shows alerts one at a time, calling show when an alert starts and
+// hide when it ends. Blocks forever
+func (q *alertQueue) run(show func(alert), hide func()) {
+	for {
+		a, ok := q.next()
+		if !ok {
+			<-q.wake
+			continue
+		}
+
+		show(a)
+		q.wait(a)
+		q.finish()
+		hide()
+	}
+}
+
+// wait blocks until the current alert's duration is over, or it is skipped
+func (q *alertQueue) wait(a alert) {
+	select {
+	case <-time.After(time.Duration(a.DurationMs) * time.Millisecond):
+	case <-q.skip:
+	}
+}
+
+// alertState is the body of the alert endpoints
+type alertState struct {
+	Current *alert  `json:"current"`
+	Pending []alert `json:"pending"`
+	History []alert `json:"history"`
+}
+
+// state returns a copy of the queue's current state
+func (q *alertQueue) state() alertState {
+	q.Lock()
+	defer q.Unlock()
+
+	state := alertState{
+		Pending: append([]alert{}, q.pending...),
+		History: append([]alert{}, q.history...),
+	}
+	if q.current != nil {
+		current := *q.current
+		state.Current = &current
+	}
+
+	return state
+}
+
+// EnableAlerts queues alerts for every Event type with an AlertStyle and
+// shows them on the alert overlay. Must be called before the Bot is started
+func (s *Server) EnableAlerts(styles map[int]AlertStyle) error {
+	s.alerts = newAlertQueue(styles)
+
+	err := s.bot.RegisterHandler(bot.NewHandler(s.alerts.enqueue).Named("server.alerts"))
+	if err != nil {
+		return err
+	}
+
+	go s.alerts.run(
+		func(a alert) {
+			s.hub.publish(push{Topic: AlertTopic, Data: a})
+		},
+		func() {
+			s.hub.publish(push{Topic: AlertTopic, Data: struct{}{}})
+		},
+	)
+
+	return nil
+}
+
+// currentAlert returns the alert being shown, or an empty object if none
+func (s *Server) currentAlert() interface{} {
+	if s.alerts == nil {
+		return struct{}{}
+	}
+
+	if current := s.alerts.state().Current; current != nil {
+		return current
+	}
+
+	return struct{}{}
+}
+
+// alertView renders the alert overlay
+func (s *Server) alertView(apiPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := RefreshingView{
+			ApiEndpoint:    s.overlayURL(r, apiPath),
+			StreamEndpoint: s.overlayURL(r, "/api/stream"),
+			AssetsEndpoint: s.requestBaseURL(r) + "/assets",
+			Topic:          AlertTopic,
+		}
+		s.renderView(w, AlertView, data)
+	}
+}
+
+// fetchCurrentAlert returns the alert currently being shown
+func (s *Server) fetchCurrentAlert() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.WriteJSON(w, 200, s.currentAlert())
+	}
+}
+
+// fetchAlerts returns the current, pending and recently shown alerts
+func (s *Server) fetchAlerts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.alerts == nil {
+			s.WriteError(w, 404, "Alerts not enabled")
+			return
+		}
+
+		s.WriteJSON(w, 200, s.alerts.state())
+	}
+}
+
+// skipAlert ends the alert currently being shown
+func (s *Server) skipAlert() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.alerts == nil {
+			s.WriteError(w, 404, "Alerts not enabled")
+			return
+		}
+
+		if !s.alerts.skipCurrent() {
+			s.WriteError(w, 409, "No alert showing")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// replayAlert queues a previously shown alert again
+func (s *Server) replayAlert() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.alerts == nil {
+			s.WriteError(w, 404, "Alerts not enabled")
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			s.WriteError(w, 400, "Invalid alert ID")
+			return
+		}
+
+		if !s.alerts.replay(id) {
+			s.WriteError(w, 404, "Alert not found in history")
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
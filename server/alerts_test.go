@@ -0,0 +1,127 @@
+package server
+
+import (
+	"html/template"
+	"medgebot/bot"
+	"testing"
+	"time"
+)
+
+func TestAlertQueueShowsAlertsInOrder(t *testing.T) {
+	queue := newAlertQueue(map[int]AlertStyle{
+		bot.SUB: {
+			Template: template.Must(template.New("sub").Parse("{{.Sender}} subbed")),
+			Duration: time.Millisecond,
+		},
+	})
+
+	shown := make(chan alert, 2)
+	go queue.run(func(a alert) { shown <- a }, func() {})
+
+	first := bot.NewSubEvent()
+	first.Sender = "saltymoth"
+	queue.enqueue(first)
+
+	second := bot.NewSubEvent()
+	second.Sender = "<b>Przemko9856</b>"
+	queue.enqueue(second)
+
+	// No AlertStyle for bits, so it should never be shown
+	queue.enqueue(bot.NewBitsEvent())
+
+	for _, expected := range []string{"saltymoth subbed", "&lt;b&gt;Przemko9856&lt;/b&gt; subbed"} {
+		select {
+		case a := <-shown:
+			if a.HTML != expected {
+				t.Fatalf("Expected alert %q, got %q", expected, a.HTML)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for alert %q", expected)
+		}
+	}
+
+	if state := queue.state(); len(state.Pending) != 0 {
+		t.Fatalf("Expected no pending alerts, got %+v", state.Pending)
+	}
+}
+
+func TestAlertQueueSkipAndReplay(t *testing.T) {
+	queue := newAlertQueue(map[int]AlertStyle{
+		bot.RAID: {
+			Template: template.Must(template.New("raid").Parse("{{.Sender}} raid")),
+			Duration: time.Hour,
+		},
+	})
+
+	shown := make(chan alert, 2)
+	hidden := make(chan struct{}, 2)
+	go queue.run(func(a alert) { shown <- a }, func() { hidden <- struct{}{} })
+
+	evt := bot.NewRaidEvent()
+	evt.Sender = "shito86"
+	queue.enqueue(evt)
+
+	first := <-shown
+	if !queue.skipCurrent() {
+		t.Fatalf("Expected an alert to be showing to skip")
+	}
+
+	select {
+	case <-hidden:
+	case <-time.After(time.Second):
+		t.Fatalf("Skipped alert was not hidden")
+	}
+
+	if !queue.replay(first.ID) {
+		t.Fatalf("Expected alert %d to be in history", first.ID)
+	}
+
+	select {
+	case replayed := <-shown:
+		if replayed.HTML != first.HTML || replayed.ID == first.ID {
+			t.Fatalf("Replay should show the same alert with a new ID. Got %+v", replayed)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Replayed alert was not shown")
+	}
+}
+
+func TestAlertQueueSkipRightAfterDequeue(t *testing.T) {
+	queue := newAlertQueue(map[int]AlertStyle{
+		bot.RAID: {
+			Template: template.Must(template.New("raid").Parse("{{.Sender}} raid")),
+			Duration: time.Hour,
+		},
+	})
+
+	// A skip with nothing showing is ignored
+	if queue.skipCurrent() {
+		t.Fatalf("Expected nothing showing to skip")
+	}
+
+	evt := bot.NewRaidEvent()
+	evt.Sender = "shito86"
+	queue.enqueue(evt)
+
+	a, ok := queue.next()
+	if !ok {
+		t.Fatalf("Expected the raid alert to be dequeued")
+	}
+
+	// Skipped before the run loop starts waiting on it
+	if !queue.skipCurrent() {
+		t.Fatalf("Expected the dequeued alert to be skippable")
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		queue.wait(a)
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatalf("Skip right after the alert was dequeued was lost")
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"medgebot/logger"
+	"medgebot/secret"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// API token scopes
+const (
+	// ScopeOverlayRead allows reading overlay views and their API/stream endpoints
+	ScopeOverlayRead = "overlay-read"
+
+	// ScopeAdmin allows changing Bot state, and implies every other scope
+	ScopeAdmin = "admin"
+
+	// ScopeDebug allows injecting fake events into the Bot
+	ScopeDebug = "debug"
+
+	// ScopeMetrics allows scraping the Prometheus /metrics endpoint
+	ScopeMetrics = "metrics"
+)
+
+type contextKey string
+
+// tokenNameKey holds the authenticated APIToken.Name on the request context
+const tokenNameKey contextKey = "tokenName"
+
+// SetAPITokens sets the tokens accepted by protected routes. With no tokens,
+// admin and debug routes reject every request
+func (s *Server) SetAPITokens(tokens []secret.APIToken) {
+	s.tokens = tokens
+}
+
+// RequireOverlayAuth protects overlay routes with the overlay-read scope.
+// Overlays are public by default
+func (s *Server) RequireOverlayAuth(required bool) {
+	s.overlayAuthRequired = required
+}
+
+// requestToken returns the bearer token from the Authorization header, or the
+// token query param since browser sources and EventSource cannot set headers
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+
+	return r.URL.Query().Get("token")
+}
+
+// authenticate finds the APIToken matching the given token value
+func (s *Server) authenticate(token string) (secret.APIToken, bool) {
+	if token == "" {
+		return secret.APIToken{}, false
+	}
+
+	for _, known := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(known.Token), []byte(token)) == 1 {
+			return known, true
+		}
+	}
+
+	return secret.APIToken{}, false
+}
+
+// hasScope checks if the APIToken was granted the scope, directly or through admin
+func hasScope(token secret.APIToken, scope string) bool {
+	for _, granted := range token.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requireScope rejects requests without a token granted the given scope
+func (s *Server) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := s.authenticate(requestToken(r))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				s.WriteError(w, http.StatusUnauthorized, "Missing or invalid API token")
+				return
+			}
+
+			if !hasScope(token, scope) {
+				logger.Warn("AUDIT: %s denied %s %s - missing scope %s", token.Name, r.Method, r.URL.Path, scope)
+				s.WriteError(w, http.StatusForbidden, "API token missing scope "+scope)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), tokenNameKey, token.Name)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// overlayAuth requires the overlay-read scope only if overlay auth is turned on
+func (s *Server) overlayAuth(next http.Handler) http.Handler {
+	protected := s.requireScope(ScopeOverlayRead)(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.overlayAuthRequired {
+			protected.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// audit logs who made a request to a protected route and the outcome.
+// Must be used after requireScope
+func (s *Server) audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// Handlers that never call WriteHeader respond 200
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		name, _ := r.Context().Value(tokenNameKey).(string)
+		logger.Info("AUDIT: %s %s %s -> %d", name, r.Method, r.URL.Path, status)
+	})
+}
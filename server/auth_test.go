@@ -0,0 +1,67 @@
+package server
+
+import (
+	"medgebot/secret"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireScope(t *testing.T) {
+	srv := &Server{
+		tokens: []secret.APIToken{
+			{Name: "dashboard", Token: "adminToken", Scopes: []string{ScopeAdmin}},
+			{Name: "obs", Token: "overlayToken", Scopes: []string{ScopeOverlayRead}},
+		},
+	}
+
+	protected := srv.requireScope(ScopeDebug)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		description string
+		header      string
+		query       string
+		expected    int
+	}{
+		{description: "No token", expected: http.StatusUnauthorized},
+		{description: "Unknown token", header: "Bearer nope", expected: http.StatusUnauthorized},
+		{description: "Token without scope", header: "Bearer overlayToken", expected: http.StatusForbidden},
+		{description: "Admin implies every scope", header: "Bearer adminToken", expected: http.StatusNoContent},
+		{description: "Token in query param", query: "?token=adminToken", expected: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(tt *testing.T) {
+			req := httptest.NewRequest("GET", "/debug/sub"+test.query, nil)
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+
+			resp := httptest.NewRecorder()
+			protected.ServeHTTP(resp, req)
+
+			if resp.Code != test.expected {
+				tt.Fatalf("Expected status %d, got %d", test.expected, resp.Code)
+			}
+		})
+	}
+}
+
+func TestParsedAPITokensAuthenticate(t *testing.T) {
+	tokens, err := secret.ParseAPITokens("dashboard:s3cret:admin, obs:0v3rlay:overlay-read|debug")
+	if err != nil {
+		t.Fatalf("Failed to parse API tokens: %v", err)
+	}
+
+	srv := &Server{tokens: tokens}
+	token, ok := srv.authenticate("0v3rlay")
+	if !ok || token.Name != "obs" {
+		t.Fatalf("Expected obs token, got %+v", token)
+	}
+
+	if !hasScope(token, ScopeDebug) || hasScope(token, ScopeAdmin) {
+		t.Fatalf("Unexpected scopes for obs token: %+v", token.Scopes)
+	}
+}
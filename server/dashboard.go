@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ChatTopic is the stream topic chat messages, to and from the Bot, are pushed on
+	ChatTopic = "chat"
+
+	// EventTopic is the stream topic every non-chat Event is pushed on
+	EventTopic = "event"
+)
+
+// eventBody is the JSON representation of a bot.Event
+type eventBody struct {
+	Type      string `json:"type"`
+	Sender    string `json:"sender,omitempty"`
+	Recipient string `json:"recipient,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Amount    int    `json:"amount,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Moderator bool   `json:"moderator,omitempty"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+
+	Tier       int  `json:"tier,omitempty"`
+	Prime      bool `json:"prime,omitempty"`
+	Streak     int  `json:"streak,omitempty"`
+	GiftMonths int  `json:"giftMonths,omitempty"`
+
+	GiftOrigin   string   `json:"giftOrigin,omitempty"`
+	Contributors []string `json:"contributors,omitempty"`
+}
+
+func toEventBody(evt bot.Event) eventBody {
+	return eventBody{
+		Type:      evt.TypeName(),
+		Sender:    evt.Sender,
+		Recipient: evt.Recipient,
+		Message:   evt.Message,
+		Amount:    evt.Amount,
+		Title:     evt.Title,
+		Moderator: evt.Moderator,
+		Anonymous: evt.Anonymous,
+
+		Tier:       evt.Tier,
+		Prime:      evt.Prime,
+		Streak:     evt.Streak,
+		GiftMonths: evt.GiftMonths,
+
+		GiftOrigin:   evt.GiftOrigin,
+		Contributors: evt.Contributors,
+	}
+}
+
+// chatBody is a chat message pushed to the dashboard
+type chatBody struct {
+	Sender  string `json:"sender,omitempty"`
+	Message string `json:"message"`
+	FromBot bool   `json:"fromBot"`
+}
+
+// publishEvent pushes Events received by the Bot to the dashboard
+func (s *Server) publishEvent(evt bot.Event) {
+	if evt.IsChatEvent() {
+		s.hub.publish(push{
+			Topic: ChatTopic,
+			Data: chatBody{
+				Sender:  evt.Sender,
+				Message: evt.Message,
+			},
+		})
+		return
+	}
+
+	s.hub.publish(push{
+		Topic: EventTopic,
+		Data:  toEventBody(evt),
+	})
+}
+
+// publishSentMessage pushes messages the Bot sends to Chat to the dashboard
+func (s *Server) publishSentMessage(evt bot.Event) {
+	s.hub.publish(push{
+		Topic: ChatTopic,
+		Data: chatBody{
+			Message: evt.Message,
+			FromBot: true,
+		},
+	})
+}
+
+// adminView serves the admin dashboard. The page holds no data itself - it asks
+// for an admin token and uses it against the API and stream
+func (s *Server) adminView() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(s.adminHTML)
+	}
+}
+
+// sendChatMessage sends a message to Chat as the Bot
+func (s *Server) sendChatMessage() http.HandlerFunc {
+	type request struct {
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.WriteError(w, 400, "Invalid request body")
+			return
+		}
+
+		if strings.TrimSpace(req.Message) == "" {
+			s.WriteError(w, 400, "request body missing message")
+			return
+		}
+
+		s.bot.SendMessage("%s", req.Message)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"medgebot/bot"
+	"medgebot/cache"
+	"medgebot/secret"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// sseEvent is one Server-Sent Event read from a stream
+type sseEvent struct {
+	topic string
+	data  string
+}
+
+// newStreamTestServer serves the stream endpoints of a Server with an admin and an overlay token
+func newStreamTestServer(t *testing.T) (*Server, *httptest.Server) {
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+	srv := &Server{
+		bot:   &chatBot,
+		store: &store,
+		hub:   newHub(),
+		tokens: []secret.APIToken{
+			{Name: "dashboard", Token: "adminToken", Scopes: []string{ScopeAdmin}},
+			{Name: "obs", Token: "overlayToken", Scopes: []string{ScopeOverlayRead}},
+		},
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/stream", srv.streamEvents())
+	router.Get("/api/ws", srv.streamWebSocket())
+
+	httpServer := httptest.NewServer(router)
+	t.Cleanup(httpServer.Close)
+	return srv, httpServer
+}
+
+// readStream connects to the SSE stream at url, sending each event it receives
+// to the returned channel until the test ends
+func readStream(t *testing.T, url string) <-chan sseEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, _ := http.NewRequest("GET", url, nil)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("Failed to connect to %s: %v", url, err)
+	}
+
+	events := make(chan sseEvent, 100)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+
+		var evt sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				evt.topic = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				evt.data = strings.TrimPrefix(line, "data: ")
+			case line == "" && evt.topic != "":
+				events <- evt
+				evt = sseEvent{}
+			}
+		}
+	}()
+
+	return events
+}
+
+// nextEvent returns the next event from the stream, failing the test if none arrives
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	select {
+	case evt, ok := <-events:
+		if !ok {
+			t.Fatalf("Stream closed")
+		}
+		return evt
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for a stream event")
+	}
+
+	return sseEvent{}
+}
+
+// skipSnapshot reads the snapshot sent on connect. The goals topic is always its last push
+func skipSnapshot(t *testing.T, events <-chan sseEvent) {
+	for {
+		if nextEvent(t, events).topic == bot.GoalTopic {
+			return
+		}
+	}
+}
+
+func TestDashboardFeedOnlyForAdmins(t *testing.T) {
+	srv, httpServer := newStreamTestServer(t)
+
+	admin := readStream(t, httpServer.URL+"/api/stream?token=adminToken")
+	overlay := readStream(t, httpServer.URL+"/api/stream?token=overlayToken")
+	public := readStream(t, httpServer.URL+"/api/stream")
+	for _, stream := range []<-chan sseEvent{admin, overlay, public} {
+		skipSnapshot(t, stream)
+	}
+
+	chat := bot.NewChatEvent()
+	chat.Sender = "saltymoth"
+	chat.Message = "Hello lab"
+	srv.publishEvent(chat)
+
+	sub := bot.NewSubEvent()
+	sub.Sender = "Przemko9856"
+	sub.Tier = 2
+	srv.publishEvent(sub)
+
+	sent := bot.NewChatEvent()
+	sent.Message = "Welcome to the lab, @saltymoth!"
+	srv.publishSentMessage(sent)
+
+	// Pushed to everyone, marking the end of the test pushes
+	srv.publishState(bot.PollTopic)
+
+	var received chatBody
+	evt := nextEvent(t, admin)
+	json.Unmarshal([]byte(evt.data), &received)
+	if evt.topic != ChatTopic || received != (chatBody{Sender: "saltymoth", Message: "Hello lab"}) {
+		t.Fatalf("Expected the chat message, got %+v", evt)
+	}
+
+	var event eventBody
+	evt = nextEvent(t, admin)
+	json.Unmarshal([]byte(evt.data), &event)
+	if evt.topic != EventTopic || event.Type != "sub" || event.Sender != "Przemko9856" || event.Tier != 2 {
+		t.Fatalf("Expected the sub event, got %+v", evt)
+	}
+
+	received = chatBody{}
+	evt = nextEvent(t, admin)
+	json.Unmarshal([]byte(evt.data), &received)
+	if evt.topic != ChatTopic || !received.FromBot || received.Message != sent.Message {
+		t.Fatalf("Expected the Bot's message, got %+v", evt)
+	}
+
+	for name, stream := range map[string]<-chan sseEvent{"admin": admin, "overlay": overlay, "public": public} {
+		if evt := nextEvent(t, stream); evt.topic != bot.PollTopic {
+			t.Fatalf("Expected only the poll push for the %s client, got %+v", name, evt)
+		}
+	}
+}
+
+func TestDashboardTopicsFilteredForOverlays(t *testing.T) {
+	srv := &Server{
+		tokens: []secret.APIToken{
+			{Name: "dashboard", Token: "adminToken", Scopes: []string{ScopeAdmin}},
+			{Name: "obs", Token: "overlayToken", Scopes: []string{ScopeOverlayRead}},
+		},
+	}
+
+	tests := []struct {
+		query    string
+		topic    string
+		expected bool
+	}{
+		{"?token=adminToken", ChatTopic, true},
+		{"?token=adminToken&topics=poll", ChatTopic, false},
+		{"?token=overlayToken", EventTopic, false},
+		{"?token=overlayToken&topics=chat", ChatTopic, false},
+		{"?topics=poll", bot.PollTopic, true},
+		{"", bot.GoalTopic, true},
+	}
+
+	for _, test := range tests {
+		filter := srv.parseTopicFilter(httptest.NewRequest("GET", "/api/stream"+test.query, nil))
+		if filter.accepts(test.topic) != test.expected {
+			t.Errorf("%s: expected accepts(%s) %v", test.query, test.topic, test.expected)
+		}
+	}
+}
@@ -1,10 +1,215 @@
 package server
 
 import (
+	"encoding/json"
+	"fmt"
 	"medgebot/bot"
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
+// maxScenarioDelay caps the pause between scenario Events
+const maxScenarioDelay = 10 * time.Second
+
+// Scenario produces a named sequence of Events to rehearse with
+type Scenario func() []bot.Event
+
+// scenarios are the sequences that can be played with POST /debug/scenarios/{name}
+var scenarios = map[string]Scenario{
+	"subbomb": subBombScenario,
+	"raid":    raidScenario,
+	"hype":    hypeScenario,
+}
+
+// subBombScenario is one user gifting 20 subs to chat
+func subBombScenario() []bot.Event {
+	return giftBomb("srycantthnkof1", 20)
+}
+
+// giftBomb is the mystery gift Twitch sends when the sender gifts amount subs at once,
+// followed by a gift sub for each recipient, all sharing the mystery gift's GiftOrigin
+func giftBomb(sender string, amount int) []bot.Event {
+	origin := fmt.Sprintf("%s-%d", sender, time.Now().UnixNano())
+
+	mystery := bot.NewMysteryGiftEvent()
+	mystery.Sender = sender
+	mystery.Amount = amount
+	mystery.Tier = 1
+	mystery.GiftOrigin = origin
+
+	events := []bot.Event{mystery}
+	for i := 1; i <= amount; i++ {
+		evt := bot.NewGiftSubEvent()
+		evt.Sender = sender
+		evt.Recipient = fmt.Sprintf("GiftedViewer%02d", i)
+		evt.Tier = 1
+		evt.GiftOrigin = origin
+		events = append(events, evt)
+	}
+
+	return events
+}
+
+// raidScenario is a raid of 500 viewers, followed by some of them chatting
+func raidScenario() []bot.Event {
+	raid := bot.NewRaidEvent()
+	raid.Sender = "SpookyGhostMachine"
+	raid.Amount = 500
+
+	events := []bot.Event{raid}
+	for i := 1; i <= 5; i++ {
+		chat := bot.NewChatEvent()
+		chat.Sender = fmt.Sprintf("Raider%d", i)
+		chat.Message = "spookyRaid spookyRaid spookyRaid"
+		events = append(events, chat)
+	}
+
+	return events
+}
+
+// hypeScenario is a burst of every kind of support event
+func hypeScenario() []bot.Event {
+	sub := bot.NewSubEvent()
+	sub.Sender = "saltymoth"
+	sub.Amount = 5
+	sub.Message = "I am a willing test subject"
+
+	bits := bot.NewBitsEvent()
+	bits.Sender = "Przemko9856"
+	bits.Amount = 1000
+	bits.Message = "Take my bits"
+
+	points := bot.NewPointsEvent()
+	points.Sender = "saltymoth"
+	points.Title = "Hydrate"
+	points.Amount = 500
+
+	events := []bot.Event{sub, bits, points}
+	return append(events, giftBomb("srycantthnkof1", 5)...)
+}
+
+// ScenarioNames lists the known scenarios, sorted
+func ScenarioNames() []string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// toEvent converts an eventBody from the API into a bot.Event
+func (body eventBody) toEvent() (bot.Event, error) {
+	eventType, ok := bot.ParseEventType(body.Type)
+	if !ok {
+		return bot.Event{}, fmt.Errorf("unknown event type %q", body.Type)
+	}
+
+	return bot.Event{
+		Type:      eventType,
+		Sender:    body.Sender,
+		Recipient: body.Recipient,
+		Message:   body.Message,
+		Amount:    body.Amount,
+		Title:     body.Title,
+		Moderator: body.Moderator,
+		Anonymous: body.Anonymous,
+
+		Tier:       body.Tier,
+		Prime:      body.Prime,
+		Streak:     body.Streak,
+		GiftMonths: body.GiftMonths,
+
+		GiftOrigin:   body.GiftOrigin,
+		Contributors: body.Contributors,
+	}, nil
+}
+
+// debugEvent sends the Event in the request body to the Bot.
+// The body is a single event, or a list of events sent in order
+func (s *Server) debugEvent(client *DebugClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		var raw json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			s.WriteError(w, 400, "Invalid request body")
+			return
+		}
+
+		var bodies []eventBody
+		if err := json.Unmarshal(raw, &bodies); err != nil {
+			var single eventBody
+			if err := json.Unmarshal(raw, &single); err != nil {
+				s.WriteError(w, 400, "Request body must be an event or list of events")
+				return
+			}
+			bodies = []eventBody{single}
+		}
+
+		events := make([]bot.Event, 0, len(bodies))
+		for _, body := range bodies {
+			evt, err := body.toEvent()
+			if err != nil {
+				s.WriteError(w, 400, err.Error())
+				return
+			}
+			events = append(events, evt)
+		}
+
+		go client.Play(events, 0)
+		s.WriteJSON(w, 202, bodies)
+	}
+}
+
+// fetchScenarios lists the scenarios that can be played
+func (s *Server) fetchScenarios() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.WriteJSON(w, 200, ScenarioNames())
+	}
+}
+
+// debugScenario plays the named scenario. An optional ?delayMs= query param
+// spaces the Events out, to watch alerts and messages as they would arrive live
+func (s *Server) debugScenario(client *DebugClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		scenario, ok := scenarios[name]
+		if !ok {
+			s.WriteError(w, 404, "Unknown scenario "+name)
+			return
+		}
+
+		var delay time.Duration
+		if param := r.URL.Query().Get("delayMs"); param != "" {
+			ms, err := strconv.Atoi(param)
+			if err != nil || ms < 0 {
+				s.WriteError(w, 400, "delayMs must be a positive number")
+				return
+			}
+
+			delay = time.Duration(ms) * time.Millisecond
+			if delay > maxScenarioDelay {
+				delay = maxScenarioDelay
+			}
+		}
+
+		events := scenario()
+		go client.Play(events, delay)
+
+		bodies := make([]eventBody, 0, len(events))
+		for _, evt := range events {
+			bodies = append(bodies, toEventBody(evt))
+		}
+		s.WriteJSON(w, 202, bodies)
+	}
+}
+
 func (s *Server) debugSub(client *DebugClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		client.SendSub()
@@ -33,6 +238,21 @@ func (c *DebugClient) SetDestination(events chan<- bot.Event) {
 	c.events = events
 }
 
+// Send sends the Event to the Bot as if it came from Twitch
+func (c *DebugClient) Send(evt bot.Event) {
+	c.events <- evt
+}
+
+// Play sends the Events to the Bot in order, waiting delay between each
+func (c *DebugClient) Play(events []bot.Event, delay time.Duration) {
+	for idx, evt := range events {
+		if idx > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+		c.Send(evt)
+	}
+}
+
 // SendBit sends a mock Bit event to the Bot for testing
 func (c *DebugClient) SendBit() {
 	evt := bot.NewBitsEvent()
@@ -40,7 +260,7 @@ func (c *DebugClient) SendBit() {
 	evt.Amount = 100
 	evt.Message = "I am a willing test subject"
 
-	c.events <- evt
+	c.Send(evt)
 }
 
 // SendSub sends a mock Subscription event to the Bot for testing
@@ -50,7 +270,7 @@ func (c *DebugClient) SendSub() {
 	evt.Amount = 5
 	evt.Message = "I am a willing test subject"
 
-	c.events <- evt
+	c.Send(evt)
 }
 
 // SendGiftSub sends a mock Gift Sub event to the Bot for testing
@@ -59,5 +279,5 @@ func (c *DebugClient) SendGiftSub() {
 	evt.Sender = "srycantthnkof1"
 	evt.Recipient = "SpookyGhostMachine"
 
-	c.events <- evt
+	c.Send(evt)
 }
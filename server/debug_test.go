@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func debugRouter(events chan bot.Event) *chi.Mux {
+	client := &DebugClient{}
+	client.SetDestination(events)
+
+	srv := &Server{}
+	router := chi.NewRouter()
+	router.Post("/debug/events", srv.debugEvent(client))
+	router.Post("/debug/scenarios/{name}", srv.debugScenario(client))
+
+	return router
+}
+
+func receive(t *testing.T, events chan bot.Event) bot.Event {
+	select {
+	case evt := <-events:
+		return evt
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for event")
+	}
+
+	return bot.Event{}
+}
+
+func TestDebugEventSendsAnyType(t *testing.T) {
+	events := make(chan bot.Event, 10)
+	router := debugRouter(events)
+
+	body := `[
+		{"type": "raid", "sender": "SpookyGhostMachine", "amount": 500},
+		{"type": "channelPoints", "sender": "saltymoth", "title": "Hydrate", "amount": 100}
+	]`
+	req := httptest.NewRequest("POST", "/debug/events", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	raid := receive(t, events)
+	if !raid.IsRaidEvent() || raid.Sender != "SpookyGhostMachine" || raid.Amount != 500 {
+		t.Errorf("Unexpected raid event %+v", raid)
+	}
+
+	points := receive(t, events)
+	if !points.IsPointsEvent() || points.Title != "Hydrate" {
+		t.Errorf("Unexpected channel points event %+v", points)
+	}
+}
+
+func TestEventBodyRoundTrip(t *testing.T) {
+	gift := bot.NewGiftSubEvent()
+	gift.Sender = "srycantthnkof1"
+	gift.Recipient = "saltymoth"
+	gift.Tier = 3
+	gift.GiftMonths = 6
+	gift.GiftOrigin = "1234"
+
+	resub := bot.NewSubEvent()
+	resub.Sender = "Przemko9856"
+	resub.Amount = 12
+	resub.Message = "Still here!"
+	resub.Tier = 1
+	resub.Prime = true
+	resub.Streak = 5
+
+	for _, evt := range []bot.Event{gift, resub} {
+		encoded, err := json.Marshal(toEventBody(evt))
+		if err != nil {
+			t.Fatalf("Failed to encode %+v: %v", evt, err)
+		}
+
+		var body eventBody
+		if err := json.Unmarshal(encoded, &body); err != nil {
+			t.Fatalf("Failed to decode %s: %v", encoded, err)
+		}
+
+		decoded, err := body.toEvent()
+		if err != nil || !reflect.DeepEqual(decoded, evt) {
+			t.Fatalf("Expected %+v after the round trip through %s, got %+v (%v)", evt, encoded, decoded, err)
+		}
+	}
+}
+
+func TestDebugEventRejectsUnknownType(t *testing.T) {
+	events := make(chan bot.Event, 10)
+	router := debugRouter(events)
+
+	req := httptest.NewRequest("POST", "/debug/events", strings.NewReader(`{"type": "follow"}`))
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", resp.Code)
+	}
+
+	select {
+	case evt := <-events:
+		t.Fatalf("Expected no event, got %+v", evt)
+	default:
+	}
+}
+
+func TestDebugScenarioPlaysSubBomb(t *testing.T) {
+	events := make(chan bot.Event, 25)
+	router := debugRouter(events)
+
+	req := httptest.NewRequest("POST", "/debug/scenarios/subbomb", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", resp.Code)
+	}
+
+	mystery := receive(t, events)
+	if !mystery.IsMysteryGiftEvent() || mystery.Amount != 20 || mystery.GiftOrigin == "" {
+		t.Fatalf("Expected a mystery gift of 20 first, got %+v", mystery)
+	}
+
+	recipients := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		evt := receive(t, events)
+		if !evt.IsGiftSubEvent() || evt.GiftOrigin != mystery.GiftOrigin {
+			t.Fatalf("Expected gift sub from the mystery gift, got %+v", evt)
+		}
+		recipients[evt.Recipient] = true
+	}
+
+	if len(recipients) != 20 {
+		t.Errorf("Expected 20 different recipients, got %d", len(recipients))
+	}
+}
+
+func TestDebugScenarioUnknown(t *testing.T) {
+	router := debugRouter(make(chan bot.Event, 1))
+
+	req := httptest.NewRequest("POST", "/debug/scenarios/nope", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// goalBody is the API representation of a bot.Goal
+type goalBody struct {
+	bot.Goal
+	Percent int `json:"percent"`
+}
+
+func toGoalBody(goal bot.Goal) goalBody {
+	return goalBody{
+		Goal:    goal,
+		Percent: goal.Percent(),
+	}
+}
+
+// goalsState returns every Goal for the goals endpoint and stream topic
+func (s *Server) goalsState() []goalBody {
+	goals := []goalBody{}
+	for _, goal := range s.bot.Goals() {
+		goals = append(goals, toGoalBody(goal))
+	}
+
+	return goals
+}
+
+// goalView renders the progress bar overlay for the Goal named in the path
+func (s *Server) goalView() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		goal, ok := s.bot.Goal(name)
+		if !ok {
+			s.WriteError(w, 404, "Unknown goal "+name)
+			return
+		}
+
+		data := RefreshingView{
+			ApiEndpoint:    s.overlayURL(r, "/api/goals/"+goal.Name),
+			StreamEndpoint: s.overlayURL(r, "/api/stream"),
+			AssetsEndpoint: s.requestBaseURL(r) + "/assets",
+			Topic:          bot.GoalTopic,
+			Label:          goal.Title,
+			Name:           goal.Name,
+		}
+		s.renderView(w, GoalView, data)
+	}
+}
+
+// fetchGoals returns every Goal and its progress
+func (s *Server) fetchGoals() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.WriteJSON(w, 200, s.goalsState())
+	}
+}
+
+// fetchGoal returns the Goal named in the path
+func (s *Server) fetchGoal() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		goal, ok := s.bot.Goal(name)
+		if !ok {
+			s.WriteError(w, 404, "Unknown goal "+name)
+			return
+		}
+
+		s.WriteJSON(w, 200, toGoalBody(goal))
+	}
+}
+
+// saveGoal creates the Goal named in the path, or changes it. Fields left out of
+// the request keep their current value
+func (s *Server) saveGoal() http.HandlerFunc {
+	type request struct {
+		Type       *string `json:"type"`
+		Title      *string `json:"title"`
+		Target     *int    `json:"target"`
+		Current    *int    `json:"current"`
+		Milestones []int   `json:"milestones"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		name := chi.URLParam(r, "name")
+
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.WriteError(w, 400, "Invalid request body")
+			return
+		}
+
+		current, exists := s.bot.Goal(name)
+		if !exists && (req.Type == nil || req.Target == nil) {
+			s.WriteError(w, 400, "New goals need a type and target")
+			return
+		}
+
+		goalType, title, target, milestones := current.Type, current.Title, current.Target, current.Milestones
+		if req.Type != nil {
+			goalType = *req.Type
+		}
+		if req.Title != nil {
+			title = *req.Title
+		}
+		if req.Target != nil {
+			target = *req.Target
+		}
+		if req.Milestones != nil {
+			milestones = req.Milestones
+		}
+
+		goal, err := bot.NewGoal(name, goalType, title, target, milestones)
+		if err != nil {
+			s.WriteError(w, 400, err.Error())
+			return
+		}
+
+		goal.Current = current.Current
+		if req.Current != nil {
+			goal.Current = *req.Current
+		}
+		if goal.Current < 0 {
+			s.WriteError(w, 400, "current cannot be negative")
+			return
+		}
+
+		s.bot.SetGoal(goal)
+
+		status := 200
+		if !exists {
+			status = 201
+		}
+		updated, _ := s.bot.Goal(name)
+		s.WriteJSON(w, status, toGoalBody(updated))
+	}
+}
+
+// adjustGoal adds to, or with a negative amount removes from, a Goal's progress.
+// Milestones reached are announced in Chat
+func (s *Server) adjustGoal() http.HandlerFunc {
+	type request struct {
+		Amount int `json:"amount"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		name := chi.URLParam(r, "name")
+
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.WriteError(w, 400, "Invalid request body")
+			return
+		}
+
+		goal, err := s.bot.AdjustGoal(name, req.Amount)
+		if err != nil {
+			s.WriteError(w, 404, err.Error())
+			return
+		}
+
+		s.WriteJSON(w, 200, toGoalBody(goal))
+	}
+}
+
+// deleteGoal removes a Goal
+func (s *Server) deleteGoal() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if !s.bot.RemoveGoal(name) {
+			s.WriteError(w, 404, "Unknown goal "+name)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot"
+	"medgebot/cache"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestSaveAndAdjustGoal(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+	srv := &Server{bot: &chatBot}
+
+	router := chi.NewRouter()
+	router.Put("/api/goals/{name}", srv.saveGoal())
+	router.Post("/api/goals/{name}/progress", srv.adjustGoal())
+
+	send := func(method, path, body string) (int, goalBody) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		var goal goalBody
+		json.NewDecoder(resp.Body).Decode(&goal)
+		return resp.Code, goal
+	}
+
+	status, _ := send("PUT", "/api/goals/bits", `{"title": "10,000 bits"}`)
+	if status != http.StatusBadRequest {
+		t.Fatalf("Expected 400 creating a goal without type and target, got %d", status)
+	}
+
+	status, goal := send("PUT", "/api/goals/bits", `{"type": "bits", "title": "10,000 bits", "target": 10000}`)
+	if status != http.StatusCreated || goal.Target != 10000 {
+		t.Fatalf("Expected created goal, got %d %+v", status, goal)
+	}
+
+	status, goal = send("POST", "/api/goals/bits/progress", `{"amount": 2500}`)
+	if status != http.StatusOK || goal.Current != 2500 || goal.Percent != 25 {
+		t.Fatalf("Expected 25%% progress, got %d %+v", status, goal)
+	}
+
+	// Changing the target keeps progress
+	status, goal = send("PUT", "/api/goals/bits", `{"target": 5000}`)
+	if status != http.StatusOK || goal.Current != 2500 || goal.Percent != 50 {
+		t.Fatalf("Expected 50%% progress after target change, got %d %+v", status, goal)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+)
+
+// HealthCheck returns an error describing why a component is unhealthy
+type HealthCheck func() error
+
+// namedCheck is a HealthCheck reported under a name, ex: irc
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// healthResponse reports "ok", or the error, for each check
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// AddLivenessCheck adds a check to /healthz and /readyz. Liveness checks should only fail
+// when the Bot can't recover without a restart, ex: a read loop stopped
+func (s *Server) AddLivenessCheck(name string, check HealthCheck) {
+	s.livenessChecks = append(s.livenessChecks, namedCheck{name, check})
+}
+
+// AddReadinessCheck adds a check to /readyz only, for conditions that may recover on
+// their own, ex: waiting to join the channel
+func (s *Server) AddReadinessCheck(name string, check HealthCheck) {
+	s.readinessChecks = append(s.readinessChecks, namedCheck{name, check})
+}
+
+// healthz responds 200 if every liveness check passes, else 503
+func (s *Server) healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.writeHealth(w, s.livenessChecks)
+	}
+}
+
+// readyz responds 200 if every liveness and readiness check passes, else 503
+func (s *Server) readyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checks := append(append([]namedCheck{}, s.livenessChecks...), s.readinessChecks...)
+		s.writeHealth(w, checks)
+	}
+}
+
+// writeHealth runs the checks and responds with the result of each
+func (s *Server) writeHealth(w http.ResponseWriter, checks []namedCheck) {
+	resp := healthResponse{
+		Status: "ok",
+		Checks: make(map[string]string),
+	}
+	statusCode := http.StatusOK
+
+	for _, named := range checks {
+		if err := named.check(); err != nil {
+			resp.Status = "unavailable"
+			resp.Checks[named.name] = err.Error()
+			statusCode = http.StatusServiceUnavailable
+			continue
+		}
+
+		// A failing liveness check also reported for readiness keeps its error
+		if _, failed := resp.Checks[named.name]; !failed {
+			resp.Checks[named.name] = "ok"
+		}
+	}
+
+	s.WriteJSON(w, statusCode, resp)
+}
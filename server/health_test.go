@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"medgebot/bot"
+	"medgebot/cache"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthChecks(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+	srv := New(&chatBot, &store, &DebugClient{}, Views{})
+
+	var joinErr error
+	srv.AddLivenessCheck("bot", func() error { return nil })
+	srv.AddReadinessCheck("irc", func() error { return joinErr })
+
+	tests := []struct {
+		name       string
+		path       string
+		joinErr    error
+		statusCode int
+		ircStatus  string
+	}{
+		{"live while joining", "/healthz", errors.New("irc not joined"), 200, ""},
+		{"not ready while joining", "/readyz", errors.New("irc not joined"), 503, "irc not joined"},
+		{"ready once joined", "/readyz", nil, 200, "ok"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			joinErr = test.joinErr
+
+			resp := httptest.NewRecorder()
+			srv.ServeHTTP(resp, httptest.NewRequest("GET", test.path, nil))
+
+			if resp.Code != test.statusCode {
+				t.Fatalf("Expected %d, got %d - %s", test.statusCode, resp.Code, resp.Body.String())
+			}
+
+			var body healthResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("Invalid response body - %v", err)
+			}
+
+			if body.Checks["bot"] != "ok" {
+				t.Fatalf("Expected bot check ok, got %q", body.Checks["bot"])
+			}
+
+			if body.Checks["irc"] != test.ircStatus {
+				t.Fatalf("Expected irc check %q, got %q", test.ircStatus, body.Checks["irc"])
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"medgebot/bot"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultHistoryLimit is the page size when no limit is requested
+	defaultHistoryLimit = 50
+
+	// maxHistoryLimit caps the page size
+	maxHistoryLimit = 500
+)
+
+// historyEntryBody is an event in the history, with when it was received
+type historyEntryBody struct {
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+	eventBody
+}
+
+// historyResponse is a page of the event history. NextCursor is empty on the last page
+type historyResponse struct {
+	Events     []historyEntryBody `json:"events"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+}
+
+// fetchEvents returns the event history, newest first. Filtered with the optional
+// type, user, since (RFC 3339) and limit query params. cursor fetches the next page
+func (s *Server) fetchEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		query := bot.HistoryQuery{
+			Type:  params.Get("type"),
+			User:  params.Get("user"),
+			Limit: defaultHistoryLimit,
+		}
+
+		if query.Type != "" {
+			if _, ok := bot.ParseEventType(query.Type); !ok {
+				s.WriteError(w, 400, "Unknown event type "+query.Type)
+				return
+			}
+		}
+
+		if since := params.Get("since"); since != "" {
+			parsed, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				s.WriteError(w, 400, "since must be an RFC 3339 time, ex: 2021-06-01T20:00:00Z")
+				return
+			}
+			query.Since = parsed
+		}
+
+		if limit := params.Get("limit"); limit != "" {
+			parsed, err := strconv.Atoi(limit)
+			if err != nil || parsed <= 0 {
+				s.WriteError(w, 400, "limit must be a positive number")
+				return
+			}
+			if parsed > maxHistoryLimit {
+				parsed = maxHistoryLimit
+			}
+			query.Limit = parsed
+		}
+
+		if cursor := params.Get("cursor"); cursor != "" {
+			parsed, err := strconv.Atoi(cursor)
+			if err != nil || parsed <= 0 {
+				s.WriteError(w, 400, "Invalid cursor")
+				return
+			}
+			query.Before = parsed
+		}
+
+		entries, more := s.bot.EventHistory(query)
+		resp := historyResponse{
+			Events: make([]historyEntryBody, 0, len(entries)),
+		}
+		for _, entry := range entries {
+			resp.Events = append(resp.Events, historyEntryBody{
+				ID:        entry.ID,
+				Time:      entry.Time,
+				eventBody: toEventBody(entry.Event),
+			})
+		}
+
+		if more {
+			resp.NextCursor = strconv.Itoa(entries[len(entries)-1].ID)
+		}
+
+		s.WriteJSON(w, 200, resp)
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot"
+	"medgebot/cache"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchEventsPaginates(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+	chatBot.RegisterEventHistory(10)
+	chatBot.Start()
+
+	client := &DebugClient{}
+	chatBot.RegisterClient(client)
+	for _, sender := range []string{"saltymoth", "Przemko9856", "BlackMarvel"} {
+		evt := bot.NewBitsEvent()
+		evt.Sender = sender
+		evt.Amount = 100
+		client.Send(evt)
+	}
+
+	srv := &Server{bot: &chatBot}
+	fetch := func(query string) (int, historyResponse) {
+		req := httptest.NewRequest("GET", "/api/events"+query, nil)
+		resp := httptest.NewRecorder()
+		srv.fetchEvents().ServeHTTP(resp, req)
+
+		var body historyResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.Code, body
+	}
+
+	// Events are recorded by a handler, so wait for them to arrive
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, body := fetch(""); len(body.Events) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for events to be recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	status, page := fetch("?type=bits&limit=2")
+	if status != http.StatusOK || len(page.Events) != 2 || page.NextCursor == "" {
+		t.Fatalf("Expected first page with a cursor, got %d %+v", status, page)
+	}
+	if page.Events[0].Sender != "BlackMarvel" || page.Events[0].Type != "bits" {
+		t.Fatalf("Expected newest bits event first, got %+v", page.Events[0])
+	}
+
+	_, last := fetch("?type=bits&limit=2&cursor=" + page.NextCursor)
+	if len(last.Events) != 1 || last.NextCursor != "" || last.Events[0].Sender != "saltymoth" {
+		t.Fatalf("Expected last page with the oldest event, got %+v", last)
+	}
+
+	if status, _ := fetch("?type=follow"); status != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an unknown type, got %d", status)
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"medgebot/bot/viewer"
+	"medgebot/logger"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RefreshingView represents a view that polls for data to be interpolated
+// on the View template. StreamEndpoint pushes changes to Topic as they happen,
+// with ApiEndpoint polled as a fallback when the stream is unavailable.
+// This is the data every overlay template, including overrides, is rendered with
+type RefreshingView struct {
+	ApiEndpoint    string
+	StreamEndpoint string
+	AssetsEndpoint string // Base URL of the overlay directory's assets, without a trailing slash
+	Topic          string
+	Label          string
+	Name           string // Picks one item from the Topic's state, ex: the Goal to show
+}
+
+// metricResponse is the body for the metric endpoints and stream topics.
+// Note: `data` holds the Name for overlays built against the original last-metric endpoints
+type metricResponse struct {
+	viewer.Metric
+	Data string `json:"data"`
+}
+
+// readMetric reads the Metric at the given cache key. An empty Metric if not set
+func (s *Server) readMetric(key string) (viewer.Metric, error) {
+	str, _ := s.store.Get(key)
+	return viewer.FromString(str)
+}
+
+// metricState reads the Metric at the given cache key for the metric endpoints
+func (s *Server) metricState(key string) (metricResponse, error) {
+	metric, err := s.readMetric(key)
+	if err != nil {
+		return metricResponse{}, err
+	}
+
+	return metricResponse{
+		Metric: metric,
+		Data:   metric.Name,
+	}, nil
+}
+
+// fetchMetric responds with the Metric at the given cache key
+func (s *Server) fetchMetric(key string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.metricState(key)
+		if err != nil {
+			logger.Error(err, "%s cache fetch", key)
+			s.WriteError(w, http.StatusInternalServerError, "metric fetch failed")
+			return
+		}
+
+		s.WriteJSON(w, 200, resp)
+	}
+}
+
+// metricView returns the live HTML page for the Metric at the given cache key
+func (s *Server) metricView(key, apiPath, label string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := RefreshingView{
+			ApiEndpoint:    s.overlayURL(r, apiPath),
+			StreamEndpoint: s.overlayURL(r, "/api/stream"),
+			AssetsEndpoint: s.requestBaseURL(r) + "/assets",
+			Topic:          key,
+			Label:          label,
+		}
+		s.renderView(w, MetricLabelView, data)
+	}
+}
+
+// fetchMetrics lists the registered Metrics
+func (s *Server) fetchMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.WriteJSON(w, 200, viewer.Registered())
+	}
+}
+
+// fetchRegisteredMetric responds with the full registered Metric named in the path
+func (s *Server) fetchRegisteredMetric() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := chi.URLParam(r, "key")
+		if _, ok := viewer.Lookup(key); !ok {
+			s.WriteError(w, 404, "Unknown metric "+key)
+			return
+		}
+
+		metric, err := s.readMetric(key)
+		if err != nil {
+			logger.Error(err, "%s cache fetch", key)
+			s.WriteError(w, http.StatusInternalServerError, "metric fetch failed")
+			return
+		}
+
+		s.WriteJSON(w, 200, metric)
+	}
+}
+
+// registeredMetricView returns the live HTML page for the registered Metric named in the path
+func (s *Server) registeredMetricView() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := chi.URLParam(r, "key")
+		def, ok := viewer.Lookup(key)
+		if !ok {
+			s.WriteError(w, 404, "Unknown metric "+key)
+			return
+		}
+
+		s.metricView(def.Key, "/api/metrics/"+def.Key, def.Label)(w, r)
+	}
+}
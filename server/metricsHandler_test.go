@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot/viewer"
+	"medgebot/cache"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestFetchRegisteredMetric(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	raided := time.Date(2021, 6, 1, 20, 0, 0, 0, time.UTC)
+	raid := viewer.Metric{Name: "SpookyGhostMachine", Amount: 500, Time: raided}
+	store.Put(viewer.LastRaider, raid.String())
+
+	srv := &Server{store: &store}
+	router := chi.NewRouter()
+	router.Get("/api/metrics/{key}", srv.fetchRegisteredMetric())
+
+	req := httptest.NewRequest("GET", "/api/metrics/"+viewer.LastRaider, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.Code)
+	}
+
+	var metric viewer.Metric
+	if err := json.NewDecoder(resp.Body).Decode(&metric); err != nil {
+		t.Fatalf("Failed to decode metric: %v", err)
+	}
+
+	if metric.Name != raid.Name || metric.Amount != raid.Amount || !metric.Time.Equal(raided) {
+		t.Errorf("Expected %v, got %v", raid, metric)
+	}
+}
+
+func TestFetchUnregisteredMetric(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	srv := &Server{store: &store}
+	router := chi.NewRouter()
+	router.Get("/api/metrics/{key}", srv.fetchRegisteredMetric())
+
+	req := httptest.NewRequest("GET", "/api/metrics/nope", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"html/template"
+	"io"
+	"medgebot/logger"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Overlay view template file names. A file with the same name in the overlay
+// directory replaces the embedded default
+const (
+	MetricLabelView = "metricLabel.html"
+	PollView        = "pollBox.html"
+	AlertView       = "alertBox.html"
+	GoalView        = "goalBar.html"
+)
+
+// assetsDir is the overlay directory's subdirectory served at /assets/
+const assetsDir = "assets"
+
+// overlayViews holds the overlay view templates. Overrides in the overlay directory
+// are re-parsed when their file changes, so themes can be edited while live
+type overlayViews struct {
+	mu        sync.Mutex
+	dir       string
+	defaults  map[string]*template.Template
+	overrides map[string]overrideView
+}
+
+// overrideView is a template parsed from the overlay directory
+type overrideView struct {
+	tmpl    *template.Template
+	modTime time.Time
+}
+
+// newOverlayViews parses the embedded default templates, by view file name
+func newOverlayViews(sources map[string]string) (*overlayViews, error) {
+	views := &overlayViews{
+		defaults:  make(map[string]*template.Template),
+		overrides: make(map[string]overrideView),
+	}
+
+	for name, source := range sources {
+		tmpl, err := template.New(name).Parse(source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s", name)
+		}
+		views.defaults[name] = tmpl
+	}
+
+	return views, nil
+}
+
+// setDir loads overrides from the given directory. Errors if it isn't a directory
+// or an override fails to parse, so broken themes are caught at startup
+func (v *overlayViews) setDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return errors.Wrap(err, "overlay directory")
+	}
+	if !info.IsDir() {
+		return errors.Errorf("overlay directory %s is not a directory", dir)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.dir = dir
+	v.overrides = make(map[string]overrideView)
+	for name := range v.defaults {
+		if err := v.reload(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the template for the view, preferring an override
+func (v *overlayViews) lookup(name string) *template.Template {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.dir != "" {
+		if err := v.reload(name); err != nil {
+			logger.Error(err, "reload overlay %s. Keeping the previous version", name)
+		}
+
+		if override, ok := v.overrides[name]; ok {
+			return override.tmpl
+		}
+	}
+
+	return v.defaults[name]
+}
+
+// reload parses the view's override file if it changed since last parsed. On a parse
+// error, the previous version is kept until the file changes again. Caller must hold mu
+func (v *overlayViews) reload(name string) error {
+	path := filepath.Join(v.dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		// A removed override falls back to the default
+		delete(v.overrides, name)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "stat "+path)
+	}
+
+	previous, overridden := v.overrides[name]
+	if overridden && previous.modTime.Equal(info.ModTime()) {
+		return nil
+	}
+
+	tmpl, err := template.ParseFiles(path)
+	if err != nil {
+		if !overridden {
+			previous.tmpl = v.defaults[name]
+		}
+		v.overrides[name] = overrideView{tmpl: previous.tmpl, modTime: info.ModTime()}
+		return errors.Wrap(err, "parse "+path)
+	}
+
+	v.overrides[name] = overrideView{tmpl: tmpl, modTime: info.ModTime()}
+	return nil
+}
+
+// assets returns the directory static assets are served from. Empty if not set
+func (v *overlayViews) assets() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.dir == "" {
+		return ""
+	}
+
+	return filepath.Join(v.dir, assetsDir)
+}
+
+// SetOverlayDir sets the directory overlay themes are read from. Templates named after
+// a view (ex: pollBox.html) replace the embedded default, and files under assets/ are
+// served at /assets/
+func (s *Server) SetOverlayDir(dir string) error {
+	return s.views.setDir(dir)
+}
+
+// renderView writes the named overlay view with the given data
+func (s *Server) renderView(w io.Writer, name string, data RefreshingView) {
+	if err := s.views.lookup(name).Execute(w, data); err != nil {
+		logger.Error(err, "render overlay %s", name)
+	}
+}
+
+// overlayAssets serves the fonts, images and sounds in the overlay directory's assets/
+func (s *Server) overlayAssets() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dir := s.views.assets()
+
+		// No directory listings
+		if dir == "" || strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.StripPrefix("/assets/", http.FileServer(http.Dir(dir))).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"medgebot/bot"
+	"medgebot/cache"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverlayDirOverridesViews(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+	srv := New(&chatBot, &store, &DebugClient{}, Views{Poll: "default {{.Topic}}"})
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, assetsDir), 0700); err != nil {
+		t.Fatalf("create assets dir: %v", err)
+	}
+	writeFile(t, filepath.Join(dir, assetsDir, "font.woff"), "FONT", time.Now())
+
+	if err := srv.SetOverlayDir(dir); err != nil {
+		t.Fatalf("SetOverlayDir failed: %v", err)
+	}
+
+	override := filepath.Join(dir, PollView)
+	modified := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		change   func()
+		expected string
+	}{
+		{"embedded default without an override", func() {}, "default poll"},
+		{"override replaces the default", func() {
+			writeFile(t, override, "themed {{.Topic}}", modified)
+		}, "themed poll"},
+		{"changed override is reloaded", func() {
+			modified = modified.Add(time.Minute)
+			writeFile(t, override, "rethemed {{.Topic}}", modified)
+		}, "rethemed poll"},
+		{"broken override keeps the previous version", func() {
+			modified = modified.Add(time.Minute)
+			writeFile(t, override, "broken {{.Topic", modified)
+		}, "rethemed poll"},
+		{"removed override falls back to the default", func() {
+			os.Remove(override)
+		}, "default poll"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.change()
+
+			resp := httptest.NewRecorder()
+			srv.ServeHTTP(resp, httptest.NewRequest("GET", "/poll", nil))
+
+			if body := strings.TrimSpace(resp.Body.String()); body != test.expected {
+				t.Fatalf("Expected %q, got %q", test.expected, body)
+			}
+		})
+	}
+
+	resp := httptest.NewRecorder()
+	srv.ServeHTTP(resp, httptest.NewRequest("GET", "/assets/font.woff", nil))
+	if resp.Code != 200 || resp.Body.String() != "FONT" {
+		t.Fatalf("Expected asset to be served, got %d %q", resp.Code, resp.Body.String())
+	}
+
+	resp = httptest.NewRecorder()
+	srv.ServeHTTP(resp, httptest.NewRequest("GET", "/assets/", nil))
+	if resp.Code != 404 {
+		t.Fatalf("Expected no directory listing, got %d", resp.Code)
+	}
+}
+
+func TestBrokenOverrideFailsAtStartup(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+	srv := New(&chatBot, &store, &DebugClient{}, Views{})
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, GoalView), "{{.Label", time.Now())
+
+	if err := srv.SetOverlayDir(dir); err == nil {
+		t.Fatalf("Expected broken override to be rejected")
+	}
+}
+
+// writeFile writes the contents and sets the modification time, so reloads are
+// detected regardless of the filesystem's timestamp resolution
+func writeFile(t *testing.T, path, contents string, modified time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatalf("set modification time of %s: %v", path, err)
+	}
+}
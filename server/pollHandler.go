@@ -2,63 +2,144 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"medgebot/bot"
+	"medgebot/bot/poll"
 	"medgebot/logger"
 	"net/http"
 	"time"
 )
 
+// Poll durations accepted by POST /poll
+const (
+	defaultPollMinutes = int(bot.DefaultPollDuration / time.Minute)
+	maxPollMinutes     = int(bot.MaxPollDuration / time.Minute)
+)
+
 // currentPollView renders and returns the Poll on-screen HTML box
-func (s *Server) currentPollView(apiEndpoint string) http.HandlerFunc {
+func (s *Server) currentPollView(apiPath string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		data := RefreshingView{
-			ApiEndpoint: apiEndpoint,
+			ApiEndpoint:    s.overlayURL(r, apiPath),
+			StreamEndpoint: s.overlayURL(r, "/api/stream"),
+			AssetsEndpoint: s.requestBaseURL(r) + "/assets",
+			Topic:          bot.PollTopic,
 		}
-		s.pollHTML.Execute(w, data)
+		s.renderView(w, PollView, data)
 	}
 }
 
-// fetchCurrentPoll returns the current poll's question and voted answers state
-func (s *Server) fetchCurrentPoll() http.HandlerFunc {
-	type Answer struct {
-		Label string `json:"label"`
-		Count int    `json:"count"`
+type pollAnswer struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// pollResponse is the body for the current poll's question and voted answers state
+type pollResponse struct {
+	ID       int          `json:"id,omitempty"`
+	Question string       `json:"question"`
+	Answers  []pollAnswer `json:"answers"`
+	Mode     poll.Mode    `json:"mode,omitempty"`
+	Voters   int          `json:"voters"`
+	Ends     *time.Time   `json:"ends,omitempty"`
+}
+
+// finishedPollResponse is a Poll from the history, with its final results
+type finishedPollResponse struct {
+	pollResponse
+	Started  time.Time `json:"started"`
+	Ended    time.Time `json:"ended"`
+	Canceled bool      `json:"canceled"`
+	Winners  []string  `json:"winners"`
+
+	// Ranked polls only: answer counts of each instant runoff round
+	Rounds [][]int `json:"rounds,omitempty"`
+}
+
+// toPollResponse converts a poll.Poll for the API. Answer counts are the current results
+func toPollResponse(current poll.Poll) pollResponse {
+	results := current.Results()
+	resp := pollResponse{
+		ID:       current.ID,
+		Question: current.Question,
+		Answers:  []pollAnswer{},
+		Mode:     current.Options.Mode,
+		Voters:   results.Voters,
+		Ends:     &current.Ends,
 	}
-	type response struct {
-		Question string   `json:"question"`
-		Answers  []Answer `json:"answers"`
+	for idx, answer := range current.Answers {
+		resp.Answers = append(resp.Answers, pollAnswer{
+			Label: answer,
+			Count: results.Counts[idx],
+		})
 	}
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		if !s.bot.IsPollRunning() {
-			s.WriteJSON(w, 200, response{
-				Question: "",
-				Answers:  []Answer{},
-			})
+	return resp
+}
 
-			return
+// toFinishedPollResponse converts an ended poll.Poll for the API
+func toFinishedPollResponse(ended poll.Poll) finishedPollResponse {
+	results := ended.Results()
+	resp := finishedPollResponse{
+		pollResponse: toPollResponse(ended),
+		Started:      ended.Started,
+		Ended:        ended.Ended,
+		Canceled:     ended.Canceled,
+		Winners:      []string{},
+		Rounds:       results.Rounds,
+	}
+	if !ended.Canceled {
+		for _, idx := range results.Winners {
+			resp.Winners = append(resp.Winners, ended.Answers[idx])
 		}
+	}
+
+	return resp
+}
 
-		question, answers := s.bot.GetPollState()
-		resp := response{
-			Question: question,
+// pollState returns the current poll's state, or an empty poll if none is running
+func (s *Server) pollState() pollResponse {
+	current, running := s.bot.CurrentPoll()
+	if !running {
+		return pollResponse{
+			Question: "",
+			Answers:  []pollAnswer{},
 		}
-		for _, answer := range answers {
-			resp.Answers = append(resp.Answers, Answer{
-				Label: answer.Answer,
-				Count: answer.Count,
-			})
+	}
+
+	return toPollResponse(current)
+}
+
+// fetchCurrentPoll returns the current poll's question and voted answers state
+func (s *Server) fetchCurrentPoll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.WriteJSON(w, 200, s.pollState())
+	}
+}
+
+// fetchPollHistory returns the finished polls and their final results, newest first
+func (s *Server) fetchPollHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		history := s.bot.PollHistory()
+
+		resp := make([]finishedPollResponse, 0, len(history))
+		for _, ended := range history {
+			resp = append(resp, toFinishedPollResponse(ended))
 		}
 
 		s.WriteJSON(w, 200, resp)
 	}
 }
 
+// createPoll starts a poll that closes after the requested minutes, 3 if not given
 func (s *Server) createPoll() http.HandlerFunc {
 	type request struct {
-		Question string   `json:"question"`
-		Answers  []string `json:"answers"`
-		Minutes  int      `json:"minutes,omitempty"`
+		Question    string   `json:"question"`
+		Answers     []string `json:"answers"`
+		Minutes     int      `json:"minutes,omitempty"`
+		Mode        string   `json:"mode,omitempty"`
+		AllowChange bool     `json:"allowChange,omitempty"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -83,8 +164,20 @@ func (s *Server) createPoll() http.HandlerFunc {
 			return
 		}
 
-		// TODO accept on the request
-		req.Minutes = 3
+		if req.Minutes == 0 {
+			req.Minutes = defaultPollMinutes
+		}
+
+		if req.Minutes < 0 || req.Minutes > maxPollMinutes {
+			s.WriteError(w, 400, fmt.Sprintf("minutes must be between 1 and %d", maxPollMinutes))
+			return
+		}
+
+		mode, err := poll.ParseMode(req.Mode)
+		if err != nil {
+			s.WriteError(w, 400, err.Error())
+			return
+		}
 
 		// Check if poll already running. If yes - return error
 		if s.bot.IsPollRunning() {
@@ -93,6 +186,38 @@ func (s *Server) createPoll() http.HandlerFunc {
 		}
 
 		// > write poll to cache, trigger Bot into Poll mode
-		s.bot.StartPoll(time.Duration(req.Minutes)*time.Minute, req.Question, req.Answers)
+		options := poll.Options{Mode: mode, AllowChange: req.AllowChange}
+		if err := s.bot.StartPoll(time.Duration(req.Minutes)*time.Minute, req.Question, req.Answers, options); err != nil {
+			s.WriteError(w, 409, err.Error())
+			return
+		}
+
+		s.WriteJSON(w, 201, s.pollState())
+	}
+}
+
+// closePoll ends the running poll early, announcing the winner in Chat
+func (s *Server) closePoll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		closed, err := s.bot.ClosePoll()
+		if err != nil {
+			s.WriteError(w, 404, err.Error())
+			return
+		}
+
+		s.WriteJSON(w, 200, toFinishedPollResponse(closed))
+	}
+}
+
+// cancelPoll ends the running poll without announcing a winner
+func (s *Server) cancelPoll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		canceled, err := s.bot.CancelPoll()
+		if err != nil {
+			s.WriteError(w, 404, err.Error())
+			return
+		}
+
+		s.WriteJSON(w, 200, toFinishedPollResponse(canceled))
 	}
 }
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot"
+	"medgebot/cache"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestPollLifecycle(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+	chatBot.SetChatClient(bot.NewTestChatClient())
+	srv := &Server{bot: &chatBot}
+
+	router := chi.NewRouter()
+	router.Post("/poll", srv.createPoll())
+	router.Post("/poll/close", srv.closePoll())
+	router.Delete("/poll", srv.cancelPoll())
+	router.Get("/api/polls/history", srv.fetchPollHistory())
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return resp
+	}
+
+	resp := send("POST", "/poll", `{"question": "Ship it?", "answers": ["Yes", "No"], "minutes": -1}`)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for negative minutes, got %d", resp.Code)
+	}
+
+	resp = send("POST", "/poll", `{"question": "Ship it?", "answers": ["Yes", "No"], "mode": "loudest"}`)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for unknown mode, got %d", resp.Code)
+	}
+
+	resp = send("POST", "/poll", `{"question": "Ship it?", "answers": ["Yes", "No"], "minutes": 10}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d - %s", resp.Code, resp.Body.String())
+	}
+
+	var created pollResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	if created.Ends == nil || time.Until(*created.Ends) < 9*time.Minute {
+		t.Fatalf("Expected poll to end in 10 minutes, got %+v", created)
+	}
+
+	if resp = send("POST", "/poll", `{"question": "Again?", "answers": ["Yes"]}`); resp.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 while a poll is running, got %d", resp.Code)
+	}
+
+	chatBot.VotePoll("saltymoth", []int{1})
+
+	resp = send("POST", "/poll/close", "")
+	var closed finishedPollResponse
+	json.NewDecoder(resp.Body).Decode(&closed)
+	if resp.Code != http.StatusOK || closed.Canceled || len(closed.Winners) != 1 || closed.Winners[0] != "Yes" {
+		t.Fatalf("Expected closed poll won by Yes, got %d %+v", resp.Code, closed)
+	}
+
+	if resp = send("DELETE", "/poll", ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 canceling with no poll running, got %d", resp.Code)
+	}
+
+	send("POST", "/poll", `{"question": "Pizza?", "answers": ["Yes", "No"]}`)
+	if resp = send("DELETE", "/poll", ""); resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200 canceling the poll, got %d", resp.Code)
+	}
+
+	resp = send("GET", "/api/polls/history", "")
+	var history []finishedPollResponse
+	json.NewDecoder(resp.Body).Decode(&history)
+	if len(history) != 2 || history[0].Question != "Pizza?" || !history[0].Canceled || history[1].Answers[0].Count != 1 {
+		t.Fatalf("Expected canceled then closed poll in history, got %+v", history)
+	}
+}
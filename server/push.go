@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"medgebot/logger"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// subscriberBufferSize is how many pushes may queue for a slow subscriber
+	// before newer pushes are dropped for that subscriber
+	subscriberBufferSize = 16
+
+	// keepAliveInterval for idle SSE/WebSocket connections, so proxies don't close them
+	keepAliveInterval = 30 * time.Second
+)
+
+// push is a state change sent to overlays over SSE or WebSocket
+type push struct {
+	Topic string      `json:"topic"`
+	Data  interface{} `json:"data"`
+}
+
+// hub fans out state pushes to all connected stream subscribers
+type hub struct {
+	sync.Mutex
+	subscribers map[chan push]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		subscribers: make(map[chan push]struct{}),
+	}
+}
+
+// subscribe registers a new subscriber. Call unsubscribe when done with it
+func (h *hub) subscribe() chan push {
+	h.Lock()
+	defer h.Unlock()
+
+	sub := make(chan push, subscriberBufferSize)
+	h.subscribers[sub] = struct{}{}
+	streamClients.Inc()
+	return sub
+}
+
+// unsubscribe removes the subscriber from the hub
+func (h *hub) unsubscribe(sub chan push) {
+	h.Lock()
+	defer h.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		streamClients.Dec()
+	}
+}
+
+// publish sends the given push to every subscriber without blocking.
+// Subscribers that cannot keep up miss the push
+func (h *hub) publish(p push) {
+	h.Lock()
+	defer h.Unlock()
+
+	for sub := range h.subscribers {
+		select {
+		case sub <- p:
+		default:
+			streamDropped.Inc()
+			logger.Warn("stream subscriber full, dropping push for topic %s", p.Topic)
+		}
+	}
+}
+
+// adminTopics are only pushed to clients with an admin token. They carry the dashboard
+// feed, which overlays don't need and the public shouldn't see
+var adminTopics = map[string]bool{
+	ChatTopic:  true,
+	EventTopic: true,
+}
+
+// topicFilter is the optional ?topics=a,b query param, and whether the client may
+// receive adminTopics. An empty topic list accepts every topic the client may receive
+type topicFilter struct {
+	topics map[string]bool
+	admin  bool
+}
+
+func (s *Server) parseTopicFilter(r *http.Request) topicFilter {
+	filter := topicFilter{topics: make(map[string]bool)}
+	for _, topic := range strings.Split(r.URL.Query().Get("topics"), ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			filter.topics[topic] = true
+		}
+	}
+
+	if token, ok := s.authenticate(requestToken(r)); ok {
+		filter.admin = hasScope(token, ScopeAdmin)
+	}
+
+	return filter
+}
+
+func (f topicFilter) accepts(topic string) bool {
+	if adminTopics[topic] && !f.admin {
+		return false
+	}
+
+	return len(f.topics) == 0 || f.topics[topic]
+}
+
+// publishState is a bot.StateListener that pushes the new state of the topic
+func (s *Server) publishState(topic string) {
+	data, ok := s.stateFor(topic)
+	if !ok {
+		return
+	}
+
+	s.hub.publish(push{
+		Topic: topic,
+		Data:  data,
+	})
+}
+
+// snapshot returns the current state of every known topic, used to prime new subscribers
+func (s *Server) snapshot(filter topicFilter) []push {
+	var pushes []push
+	for _, topic := range s.topics() {
+		if !filter.accepts(topic) {
+			continue
+		}
+
+		if data, ok := s.stateFor(topic); ok {
+			pushes = append(pushes, push{Topic: topic, Data: data})
+		}
+	}
+
+	return pushes
+}
+
+// streamEvents pushes state changes as Server-Sent Events. Each SSE event name
+// is the topic, and the data is the same JSON body the matching API endpoint returns
+func (s *Server) streamEvents() http.HandlerFunc {
+	writeEvent := func(w http.ResponseWriter, p push) error {
+		body, err := json.Marshal(p.Data)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", p.Topic, body)
+		return err
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			s.WriteError(w, 500, "Streaming unsupported")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		filter := s.parseTopicFilter(r)
+		sub := s.hub.subscribe()
+		defer s.hub.unsubscribe(sub)
+
+		for _, p := range s.snapshot(filter) {
+			if err := writeEvent(w, p); err != nil {
+				logger.Error(err, "write SSE snapshot")
+				return
+			}
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case p := <-sub:
+				if !filter.accepts(p.Topic) {
+					continue
+				}
+
+				if err := writeEvent(w, p); err != nil {
+					logger.Error(err, "write SSE event")
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// streamWebSocket pushes state changes as JSON {topic, data} messages over a WebSocket
+func (s *Server) streamWebSocket() http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		// Overlays are loaded by OBS browser sources from any origin
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Error(err, "websocket upgrade")
+			return
+		}
+		defer conn.Close()
+
+		filter := s.parseTopicFilter(r)
+		sub := s.hub.subscribe()
+		defer s.hub.unsubscribe(sub)
+
+		// We don't expect messages from the client, but must read to notice a close
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for _, p := range s.snapshot(filter) {
+			if err := conn.WriteJSON(p); err != nil {
+				logger.Error(err, "write websocket snapshot")
+				return
+			}
+		}
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case <-keepAlive.C:
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			case p := <-sub:
+				if !filter.accepts(p.Topic) {
+					continue
+				}
+
+				if err := conn.WriteJSON(p); err != nil {
+					logger.Error(err, "write websocket push")
+					return
+				}
+			}
+		}
+	}
+}
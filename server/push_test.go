@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot"
+	"medgebot/bot/poll"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startTestPoll starts a Poll on the Server's Bot, so the poll topic has state to push
+func startTestPoll(t *testing.T, srv *Server, question string) {
+	srv.bot.SetChatClient(bot.NewTestChatClient())
+	if err := srv.bot.StartPoll(time.Minute, question, []string{"Yes", "No"}, poll.Options{}); err != nil {
+		t.Fatalf("Failed to start poll: %v", err)
+	}
+}
+
+func TestStreamSendsSnapshotOnConnect(t *testing.T) {
+	srv, httpServer := newStreamTestServer(t)
+	startTestPoll(t, srv, "Ship it?")
+
+	events := readStream(t, httpServer.URL+"/api/stream")
+	topics := make([]string, 0)
+	for {
+		evt := nextEvent(t, events)
+		topics = append(topics, evt.topic)
+
+		if evt.topic == bot.PollTopic {
+			var state pollResponse
+			json.Unmarshal([]byte(evt.data), &state)
+			if state.Question != "Ship it?" || len(state.Answers) != 2 {
+				t.Fatalf("Expected the running poll in the snapshot, got %s", evt.data)
+			}
+		}
+
+		if evt.topic == bot.GoalTopic {
+			break
+		}
+	}
+
+	if !strings.Contains(strings.Join(topics, ","), bot.PollTopic+","+bot.GoalTopic) {
+		t.Fatalf("Expected poll and goals state in the snapshot, got topics %v", topics)
+	}
+}
+
+func TestStreamTopicFilter(t *testing.T) {
+	srv, httpServer := newStreamTestServer(t)
+
+	events := readStream(t, httpServer.URL+"/api/stream?topics="+bot.PollTopic)
+	if evt := nextEvent(t, events); evt.topic != bot.PollTopic {
+		t.Fatalf("Expected only the poll state in the snapshot, got %+v", evt)
+	}
+
+	srv.publishState(bot.GoalTopic)
+	startTestPoll(t, srv, "Filtered?")
+	srv.publishState(bot.PollTopic)
+
+	evt := nextEvent(t, events)
+	if evt.topic != bot.PollTopic || !strings.Contains(evt.data, "Filtered?") {
+		t.Fatalf("Expected the goals push to be filtered out, got %+v", evt)
+	}
+}
+
+func TestWebSocketReceivesPush(t *testing.T) {
+	srv, httpServer := newStreamTestServer(t)
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/api/ws?topics=" + bot.PollTopic
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect to %s: %v", url, err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	var received struct {
+		Topic string       `json:"topic"`
+		Data  pollResponse `json:"data"`
+	}
+	if err := conn.ReadJSON(&received); err != nil || received.Topic != bot.PollTopic {
+		t.Fatalf("Expected the poll snapshot, got %+v (%v)", received, err)
+	}
+
+	startTestPoll(t, srv, "Over a WebSocket?")
+	srv.publishState(bot.PollTopic)
+
+	if err := conn.ReadJSON(&received); err != nil || received.Data.Question != "Over a WebSocket?" {
+		t.Fatalf("Expected the poll push, got %+v (%v)", received, err)
+	}
+}
+
+func TestSlowSubscriberDoesNotBlockOthers(t *testing.T) {
+	h := newHub()
+	slow := h.subscribe()
+	defer h.unsubscribe(slow)
+	fast := h.subscribe()
+	defer h.unsubscribe(fast)
+
+	// slow never reads, so its buffer fills up while fast keeps up with every push
+	for i := 0; i < subscriberBufferSize+5; i++ {
+		published := make(chan struct{})
+		go func(i int) {
+			h.publish(push{Topic: bot.PollTopic, Data: i})
+			close(published)
+		}(i)
+
+		select {
+		case <-published:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Publishing blocked on the slow subscriber")
+		}
+
+		select {
+		case p := <-fast:
+			if p.Data != i {
+				t.Fatalf("Expected push %d for the fast subscriber, got %+v", i, p)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Fast subscriber missed push %d", i)
+		}
+	}
+
+	if len(slow) != subscriberBufferSize {
+		t.Fatalf("Expected the slow subscriber to keep %d pushes and drop the rest, got %d", subscriberBufferSize, len(slow))
+	}
+	if first := <-slow; first.Data != 0 {
+		t.Fatalf("Expected the slow subscriber to keep the oldest pushes, got %+v", first)
+	}
+}
@@ -0,0 +1,12 @@
+package server
+
+import (
+	"net/http"
+)
+
+// fetchRaiders returns the raid history of every raider, most recent raid first
+func (s *Server) fetchRaiders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.WriteJSON(w, 200, s.bot.Raiders())
+	}
+}
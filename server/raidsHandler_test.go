@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"medgebot/bot"
+	"medgebot/cache"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchRaiders(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+	chatBot.RegisterRaidHandler(bot.HandlerTemplate{}, bot.RaidOptions{})
+	chatBot.Start()
+
+	client := &DebugClient{}
+	chatBot.RegisterClient(client)
+	for _, raider := range []string{"SpookyGhostMachine", "Sorcerbee", "SpookyGhostMachine"} {
+		evt := bot.NewRaidEvent()
+		evt.Sender = raider
+		evt.Amount = 10
+		client.Send(evt)
+	}
+
+	srv := &Server{bot: &chatBot}
+	fetch := func() []bot.RaiderStats {
+		resp := httptest.NewRecorder()
+		srv.fetchRaiders().ServeHTTP(resp, httptest.NewRequest("GET", "/api/raiders", nil))
+
+		var raiders []bot.RaiderStats
+		json.NewDecoder(resp.Body).Decode(&raiders)
+		return raiders
+	}
+
+	// Raids are recorded by a handler, so wait for them to arrive
+	deadline := time.Now().Add(time.Second)
+	for {
+		raiders := fetch()
+		if len(raiders) == 2 && raiders[0].Name == "SpookyGhostMachine" && raiders[0].Raids == 2 {
+			if raiders[0].TotalViewers != 20 || raiders[1].Name != "Sorcerbee" {
+				t.Fatalf("Expected most recent raider first, got %+v", raiders)
+			}
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for raids to be recorded, got %+v", raiders)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
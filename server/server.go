@@ -2,11 +2,15 @@ package server
 
 import (
 	"encoding/json"
-	"html/template"
 	"medgebot/bot"
+	"medgebot/bot/viewer"
 	"medgebot/cache"
+	"medgebot/config"
 	"medgebot/logger"
+	"medgebot/secret"
 	"net/http"
+	neturl "net/url"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -17,68 +21,264 @@ type Server struct {
 	bot         *bot.Bot
 	store       cache.Cache
 	debugClient *DebugClient
-	labelHTML   *template.Template
-	pollHTML    *template.Template
+	views       *overlayViews
+	adminHTML   []byte
+
+	// Alert overlay queue. nil if alerts are not enabled
+	alerts *alertQueue
+
+	// Live state pushes to overlays
+	hub *hub
+
+	// baseURL overrides the request-derived URL used to link overlays to the API
+	baseURL string
+
+	// Runtime changes made through the admin API are persisted here, if set
+	config *config.Config
+
+	// API tokens accepted by protected routes
+	tokens              []secret.APIToken
+	overlayAuthRequired bool
+
+	// Checks reported by /healthz and /readyz
+	livenessChecks  []namedCheck
+	readinessChecks []namedCheck
+}
+
+// Views holds the HTML templates for the overlay views, and the admin dashboard page
+type Views struct {
+	MetricLabel string
+	Poll        string
+	Alert       string
+	Goal        string
+	Admin       string
 }
 
 // New returns a Server instance to be run with http.ListenAndServe()
-func New(bot *bot.Bot, dataStore cache.Cache, debugClient *DebugClient, labelHTMLStr string, pollHTMLStr string) *Server {
+func New(chatBot *bot.Bot, dataStore cache.Cache, debugClient *DebugClient, views Views) *Server {
 	srv := &Server{
 		router:      chi.NewRouter(),
 		store:       dataStore,
 		debugClient: debugClient,
-		bot:         bot,
+		bot:         chatBot,
+		hub:         newHub(),
+		adminHTML:   []byte(views.Admin),
 	}
 
-	// Parse Metrics Label HTML template for reuse by the View Handlers
-	tmpl, err := template.New("MetricsLabel").Parse(labelHTMLStr)
+	// Parse the overlay view templates for reuse by the View Handlers
+	overlays, err := newOverlayViews(map[string]string{
+		MetricLabelView: views.MetricLabel,
+		PollView:        views.Poll,
+		AlertView:       views.Alert,
+		GoalView:        views.Goal,
+	})
 	if err != nil {
-		logger.Fatal(err, "Failed to parse Metrics Label HTML template")
+		logger.Fatal(err, "Failed to parse overlay HTML templates")
 	}
-	srv.labelHTML = tmpl
+	srv.views = overlays
 
-	// Parse Poll HTML template for reuse by the Poll View Handlers
-	tmpl, err = template.New("PolTemplate").Parse(pollHTMLStr)
-	if err != nil {
-		logger.Fatal(err, "Failed to parse Poll HTML template")
+	// Push state changes to connected overlays as they happen
+	chatBot.AddStateListener(srv.publishState)
+
+	// Push chat and events to the dashboard. Requires the Bot to not be started yet
+	chatBot.AddMessageListener(srv.publishSentMessage)
+	if err := chatBot.RegisterHandler(bot.NewHandler(srv.publishEvent).Named("server.events")); err != nil {
+		logger.Fatal(err, "Failed to register Server event handler")
 	}
-	srv.pollHTML = tmpl
 
 	srv.routes()
 	return srv
 }
 
+// SetBaseURL overrides the base URL (ex: https://bot.example.com) overlays use to
+// reach the API. By default, it is derived from each request
+func (s *Server) SetBaseURL(baseURL string) {
+	s.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
 func (s *Server) routes() {
-	// TODO pull from config
-	baseURL := "http://localhost:8080"
+	// Must come before any route is added
+	s.router.Use(s.instrument)
+
+	// Health checks for the orchestrator. Public, so probes need no token
+	s.router.Get("/healthz", s.healthz())
+	s.router.Get("/readyz", s.readyz())
+
+	// Fonts, images and sounds for overlay themes. Public, as browser sources load
+	// them from CSS and HTML without a token
+	s.router.Get("/assets/*", s.overlayAssets())
+
+	// Admin dashboard page. Data is loaded with an admin token through the API
+	s.router.Get("/admin", s.adminView())
+
+	// Overlays and the read-only API they use. Public unless overlay auth is turned on
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.overlayAuth)
+
+		// Metrics. Every registered viewer.Metric is served by key
+		r.Get("/api/metrics", s.fetchMetrics())
+		r.Get("/api/metrics/{key}", s.fetchRegisteredMetric())
+		r.Get("/views/metrics/{key}", s.registeredMetricView())
+
+		// Original metric endpoints, kept for existing overlays
+		r.Get("/api/subs/last", s.fetchMetric(viewer.LastSub))
+		r.Get("/subs/last", s.metricView(viewer.LastSub, "/api/subs/last", "Last Sub"))
+
+		r.Get("/api/gift/last", s.fetchMetric(viewer.LastGiftSub))
+		r.Get("/gift/last", s.metricView(viewer.LastGiftSub, "/api/gift/last", "Last Gifter"))
+
+		r.Get("/api/bits/last", s.fetchMetric(viewer.LastBits))
+		r.Get("/bits/last", s.metricView(viewer.LastBits, "/api/bits/last", "Last Bits"))
+
+		// Polls
+		r.Get("/poll", s.currentPollView("/api/poll"))
+		r.Get("/api/poll", s.fetchCurrentPoll())
+		r.Get("/api/polls/history", s.fetchPollHistory())
+
+		// Event history
+		r.Get("/api/events", s.fetchEvents())
+		r.Get("/api/raiders", s.fetchRaiders())
+
+		// Goals
+		r.Get("/goals/{name}", s.goalView())
+		r.Get("/api/goals", s.fetchGoals())
+		r.Get("/api/goals/{name}", s.fetchGoal())
+
+		// Alerts
+		r.Get("/alerts", s.alertView("/api/alerts/current"))
+		r.Get("/api/alerts/current", s.fetchCurrentAlert())
+
+		// Live pushes of the above state
+		r.Get("/api/stream", s.streamEvents())
+		r.Get("/api/ws", s.streamWebSocket())
+	})
+
+	// Admin - changes Bot state
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.requireScope(ScopeAdmin), s.audit)
+
+		r.Post("/poll", s.createPoll())
+		r.Post("/poll/close", s.closePoll())
+		r.Delete("/poll", s.cancelPoll())
+
+		r.Put("/api/goals/{name}", s.saveGoal())
+		r.Post("/api/goals/{name}/progress", s.adjustGoal())
+		r.Delete("/api/goals/{name}", s.deleteGoal())
+
+		r.Get("/api/alerts", s.fetchAlerts())
+		r.Post("/api/alerts/skip", s.skipAlert())
+		r.Post("/api/alerts/{id}/replay", s.replayAlert())
 
-	// Metrics endpoints
-	s.router.Get("/api/subs/last", s.fetchLastSub())
-	s.router.Get("/subs/last", s.lastSubView(baseURL+"/api/subs/last"))
+		// Dashboard chat box
+		r.Post("/api/chat", s.sendChatMessage())
 
-	s.router.Get("/api/gift/last", s.fetchLastGiftSub())
-	s.router.Get("/gift/last", s.lastGiftSubView(baseURL+"/api/gift/last"))
+		// Runtime control of features, message templates and commands
+		r.Get("/api/config", s.fetchConfig())
 
-	s.router.Get("/api/bits/last", s.fetchLastBits())
-	s.router.Get("/bits/last", s.lastBitsView(baseURL+"/api/bits/last"))
+		r.Get("/api/features", s.fetchFeatures())
+		r.Put("/api/features/{feature}", s.updateFeature())
 
-	// Polls
-	s.router.Post("/poll", s.createPoll())
-	s.router.Get("/poll", s.currentPollView(baseURL+"/api/poll"))
-	s.router.Get("/api/poll", s.fetchCurrentPoll())
+		r.Get("/api/templates", s.fetchTemplates())
+		r.Put("/api/templates/{name}", s.updateTemplate())
+
+		r.Get("/api/commands", s.fetchCommands())
+		r.Post("/api/commands", s.saveCommand())
+		r.Put("/api/commands/{prefix}", s.saveCommand())
+		r.Delete("/api/commands/{prefix}", s.deleteCommand())
+	})
+
+	// Prometheus scraping
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.requireScope(ScopeMetrics))
+
+		r.Get("/metrics", s.metrics())
+	})
 
 	// DEBUG - trigger various events for testing
-	// TODO how do secure when deploy?
-	s.router.Get("/debug/sub", s.debugSub(s.debugClient))
-	s.router.Get("/debug/gift", s.debugGift(s.debugClient))
-	s.router.Get("/debug/bit", s.debugBit(s.debugClient))
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.requireScope(ScopeDebug), s.audit)
+
+		r.Get("/debug/sub", s.debugSub(s.debugClient))
+		r.Get("/debug/gift", s.debugGift(s.debugClient))
+		r.Get("/debug/bit", s.debugBit(s.debugClient))
+
+		r.Post("/debug/events", s.debugEvent(s.debugClient))
+		r.Get("/debug/scenarios", s.fetchScenarios())
+		r.Post("/debug/scenarios/{name}", s.debugScenario(s.debugClient))
+	})
+}
+
+// topics lists every state topic the Server can push
+func (s *Server) topics() []string {
+	var topics []string
+	for _, def := range viewer.Registered() {
+		topics = append(topics, def.Key)
+	}
+	topics = append(topics, bot.PollTopic, bot.GoalTopic)
+
+	if s.alerts != nil {
+		topics = append(topics, AlertTopic)
+	}
+
+	return topics
+}
+
+// stateFor returns the API response body for the given state topic
+func (s *Server) stateFor(topic string) (interface{}, bool) {
+	if _, ok := viewer.Lookup(topic); ok {
+		data, err := s.metricState(topic)
+		if err != nil {
+			logger.Error(err, "%s cache fetch", topic)
+			return nil, false
+		}
+		return data, true
+	}
+
+	switch topic {
+	case bot.PollTopic:
+		return s.pollState(), true
+	case bot.GoalTopic:
+		return s.goalsState(), true
+	case AlertTopic:
+		return s.currentAlert(), true
+	default:
+		return nil, false
+	}
+}
+
+// requestBaseURL returns the configured base URL, or derives one from the request
+func (s *Server) requestBaseURL(r *http.Request) string {
+	if s.baseURL != "" {
+		return s.baseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host
+}
+
+// overlayURL links an overlay to the given API path. The request's token is
+// passed along, as overlays can't send an Authorization header
+func (s *Server) overlayURL(r *http.Request, path string) string {
+	url := s.requestBaseURL(r) + path
+	if token := r.URL.Query().Get("token"); token != "" {
+		url += "?token=" + neturl.QueryEscape(token)
+	}
+
+	return url
 }
 
 // WriteJSON is a helper to respond with a JSON message body.
 // If marshalling fails, it will respond with a HTTP 500
 func (s *Server) WriteJSON(w http.ResponseWriter, statusCode int, msg interface{}) {
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	w.Header().Add("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(msg)
 	if err != nil {
 		logger.Error(err, "Failed to write Error response")
@@ -87,13 +287,13 @@ func (s *Server) WriteJSON(w http.ResponseWriter, statusCode int, msg interface{
 }
 
 // Standard errors
-type error struct {
+type errorResponse struct {
 	Error string `json:"error"`
 }
 
 // WriteError responds with a standardized error body
 func (s *Server) WriteError(w http.ResponseWriter, statusCode int, msg string) {
-	s.WriteJSON(w, statusCode, error{
+	s.WriteJSON(w, statusCode, errorResponse{
 		Error: msg,
 	})
 }
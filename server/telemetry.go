@@ -0,0 +1,53 @@
+package server
+
+import (
+	"medgebot/telemetry"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+var (
+	httpRequests = telemetry.NewCounter("medgebot_http_requests_total",
+		"HTTP requests served, by method, route and status", "method", "route", "status")
+	httpDuration = telemetry.NewHistogram("medgebot_http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by method and route", "method", "route")
+	streamClients = telemetry.NewGauge("medgebot_stream_clients",
+		"Connected SSE and WebSocket stream clients")
+	streamDropped = telemetry.NewCounter("medgebot_stream_dropped_total",
+		"Pushes dropped because a stream client could not keep up")
+)
+
+// instrument records request counts and durations by route pattern, so
+// path params like {key} don't create a series per value
+func (s *Server) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		httpRequests.Inc(r.Method, route, strconv.Itoa(status))
+		httpDuration.ObserveSince(start, r.Method, route)
+	})
+}
+
+// metrics serves every telemetry metric in the Prometheus text format
+func (s *Server) metrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		telemetry.Default.Write(w)
+	}
+}
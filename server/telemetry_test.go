@@ -0,0 +1,43 @@
+package server
+
+import (
+	"medgebot/bot"
+	"medgebot/cache"
+	"medgebot/secret"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	store, _ := cache.InMemory(0)
+	chatBot := bot.New(&store)
+	srv := New(&chatBot, &store, &DebugClient{}, Views{})
+	srv.SetAPITokens([]secret.APIToken{
+		{Name: "prometheus", Token: "scrape", Scopes: []string{ScopeMetrics}},
+	})
+
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/metrics/lastSub", nil))
+
+	resp := httptest.NewRecorder()
+	srv.ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected /metrics to need a token, got %d", resp.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape")
+	resp = httptest.NewRecorder()
+	srv.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.Code)
+	}
+
+	// Requests are recorded by route pattern, not the requested path
+	expected := `medgebot_http_requests_total{method="GET",route="/api/metrics/{key}",status="200"} 1`
+	if !strings.Contains(resp.Body.String(), expected) {
+		t.Fatalf("Missing %s in:\n%s", expected, resp.Body.String())
+	}
+}
@@ -0,0 +1,79 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWritePrometheusText(t *testing.T) {
+	registry := NewRegistry()
+
+	events := &Counter{newVec("test_events_total", "Events received", "counter", []string{"type"})}
+	registry.register(events.name, events)
+	events.Inc("sub")
+	events.Add(2, "bits")
+
+	depth := &Gauge{newVec("test_queue_depth", "Queued \"events\"", "gauge", nil)}
+	registry.register(depth.name, depth)
+	depth.Set(3)
+
+	var out strings.Builder
+	registry.Write(&out)
+
+	expected := `# HELP test_events_total Events received
+# TYPE test_events_total counter
+test_events_total{type="bits"} 2
+test_events_total{type="sub"} 1
+# HELP test_queue_depth Queued "events"
+# TYPE test_queue_depth gauge
+test_queue_depth 3
+`
+	if out.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, out.String())
+	}
+}
+
+func TestHistogramBucketsAreCumulative(t *testing.T) {
+	registry := NewRegistry()
+	latency := &Histogram{
+		name:       "test_latency_seconds",
+		help:       "Latency",
+		labelNames: []string{"handler"},
+		buckets:    []float64{0.1, 1},
+		series:     make(map[string]*histogramSeries),
+	}
+	registry.register(latency.name, latency)
+
+	latency.Observe(0.05, "bits")
+	latency.Observe(0.5, "bits")
+	latency.Observe(2, "bits")
+
+	var out strings.Builder
+	registry.Write(&out)
+
+	for _, line := range []string{
+		`test_latency_seconds_bucket{handler="bits",le="0.1"} 1`,
+		`test_latency_seconds_bucket{handler="bits",le="1"} 2`,
+		`test_latency_seconds_bucket{handler="bits",le="+Inf"} 3`,
+		`test_latency_seconds_sum{handler="bits"} 2.55`,
+		`test_latency_seconds_count{handler="bits"} 3`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Missing line %s in:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestLabelValuesEscaped(t *testing.T) {
+	registry := NewRegistry()
+	requests := &Counter{newVec("test_requests_total", "Requests", "counter", []string{"path"})}
+	registry.register(requests.name, requests)
+	requests.Inc("/say \"hi\"\n")
+
+	var out strings.Builder
+	registry.Write(&out)
+
+	if !strings.Contains(out.String(), `test_requests_total{path="/say \"hi\"\n"} 1`) {
+		t.Fatalf("Label value not escaped:\n%s", out.String())
+	}
+}
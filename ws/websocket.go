@@ -2,6 +2,7 @@ package ws
 
 import (
 	log "medgebot/logger"
+	"medgebot/telemetry"
 	"net/url"
 	"sync"
 	"time"
@@ -10,6 +11,9 @@ import (
 	"github.com/pkg/errors"
 )
 
+var reconnects = telemetry.NewCounter("medgebot_ws_reconnects_total",
+	"WebSocket reconnect attempts, by host and result", "host", "result")
+
 // Connection implements io.ReadWriteCloser with gorilla/websocket,
 // also allowing for automatic reconnect/retry logic on a read/write
 // error
@@ -18,6 +22,9 @@ type Connection struct {
 	conn    *websocket.Conn
 	connURL url.URL
 
+	// Rest of the last message, for Reads into a smaller buffer
+	unread []byte
+
 	// Reconnection/retry params
 	postReconnectFunc func() error
 	maxRetries        int
@@ -65,24 +72,43 @@ func (ws *Connection) Connect() error {
 func (ws *Connection) Reconnect() error {
 	err := ws.Connect()
 	if err != nil {
+		reconnects.Inc(ws.connURL.Host, "failed")
 		return errors.Wrap(err, "reconnect failed")
 	}
 
 	err = ws.postReconnectFunc()
 	if err != nil {
+		reconnects.Inc(ws.connURL.Host, "failed")
 		return errors.Wrap(err, "post-reconnect func failed")
 	}
 
+	reconnects.Inc(ws.connURL.Host, "success")
 	return nil
 }
 
-// Read from the underlying connection.
+// Read from the underlying connection. A message larger than dest is returned over several
+// Reads, so nothing is truncated. Same retries as ReadFrame
+func (ws *Connection) Read(dest []byte) (int, error) {
+	if len(ws.unread) == 0 {
+		message, err := ws.ReadFrame()
+		if err != nil {
+			return 0, err
+		}
+		ws.unread = message
+	}
+
+	n := copy(dest, ws.unread)
+	ws.unread = ws.unread[n:]
+	return n, nil
+}
+
+// ReadFrame reads the next whole websocket message from the underlying connection.
 // On a read error, if maxRetries > 0, it will attempt to reconnect the WS.
 // On ws.maxReconnectRetries, the error will be returned
-func (ws *Connection) Read(dest []byte) (int, error) {
+func (ws *Connection) ReadFrame() ([]byte, error) {
 	_, message, err := ws.conn.ReadMessage()
 	if err == nil {
-		return copy(dest, message), nil
+		return message, nil
 	}
 
 	// On error, retry
@@ -101,7 +127,7 @@ func (ws *Connection) Read(dest []byte) (int, error) {
 
 			_, message, err := ws.conn.ReadMessage()
 			if err == nil {
-				return copy(dest, message), nil
+				return message, nil
 			}
 
 			log.Error(err, "retry %d failed. Retry after %d", i, nextWait)
@@ -109,7 +135,7 @@ func (ws *Connection) Read(dest []byte) (int, error) {
 	}
 
 	// Absolute failure, return the error
-	return 0, err
+	return nil, ws.exhausted(err)
 }
 
 // Write to the underlying connection.
@@ -149,7 +175,18 @@ func (ws *Connection) Write(data []byte) (int, error) {
 	}
 
 	// Absolute failure, return the error
-	return 0, err
+	return 0, ws.exhausted(err)
+}
+
+// exhausted marks the error as final, so callers can tell retries are exhausted
+func (ws *Connection) exhausted(err error) error {
+	if ws.maxRetries <= 0 {
+		return err
+	}
+
+	err = errors.Wrapf(err, "%s: %d retries exhausted", ws.connURL.Host, ws.maxRetries)
+	log.Error(err, "ws connection lost")
+	return err
 }
 
 // Close the underlying connection
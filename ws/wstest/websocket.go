@@ -28,29 +28,39 @@ func (w *Websocket) Send(message string) {
 	w.Write([]byte(message))
 }
 
+// SendLine is a convenience method over Write() for IRC lines, ending the line with CRLF
+// like Twitch does
+func (w *Websocket) SendLine(line string) {
+	w.Write([]byte(line + "\r\n"))
+}
+
 // SendAndWait is a convenience method over Write() that also waits
 // for a new message to arrive
 func (w *Websocket) SendAndWait(message string) {
 	w.Write([]byte(message))
+	current := w.lineCount()
 
-	w.Lock()
-	current := len(w.lines)
-	w.Unlock()
-
-	for {
-		if len(w.lines) != current {
-			break
-		}
-
+	for w.lineCount() == current {
 		time.Sleep(10 * time.Millisecond)
 	}
 }
 
+// lineCount returns the number of lines written so far
+func (w *Websocket) lineCount() int {
+	w.Lock()
+	defer w.Unlock()
+
+	return len(w.lines)
+}
+
 // Received indicates if the given message contents was _ever_ received
-// on this Websocket
+// on this Websocket, ignoring line endings
 func (w *Websocket) Received(contents string) bool {
+	w.Lock()
+	defer w.Unlock()
+
 	for _, line := range w.lines {
-		if line == contents {
+		if strings.TrimRight(line, "\r\n") == contents {
 			return true
 		}
 	}
@@ -61,6 +71,9 @@ func (w *Websocket) Received(contents string) bool {
 
 // String returns the current WS line buffer as a \n delimited string
 func (w *Websocket) String() string {
+	w.Lock()
+	defer w.Unlock()
+
 	var sb strings.Builder
 	for _, line := range w.lines {
 		sb.WriteString(line)
@@ -72,25 +85,30 @@ func (w *Websocket) String() string {
 	return sb.String()
 }
 
-// io.ReadWriteCloser
-func (w *Websocket) Read(dst []byte) (int, error) {
-	// Block until lines available
+// ReadFrame blocks until a line is available, returning it whole, like a websocket message
+func (w *Websocket) ReadFrame() ([]byte, error) {
 	for {
-		if len(w.lines) != 0 && w.readCursor < len(w.lines) {
-			break
+		w.Lock()
+		if w.readCursor < len(w.lines) {
+			head := w.lines[w.readCursor]
+			w.readCursor++
+			w.Unlock()
+			return []byte(head), nil
 		}
+		w.Unlock()
 
 		time.Sleep(10 * time.Millisecond)
 	}
+}
 
-	w.Lock()
-	defer w.Unlock()
-
-	head := w.lines[w.readCursor]
-	copy(dst, []byte(head))
-	w.readCursor++
+// io.ReadWriteCloser
+func (w *Websocket) Read(dst []byte) (int, error) {
+	head, err := w.ReadFrame()
+	if err != nil {
+		return 0, err
+	}
 
-	return len(head), nil
+	return copy(dst, head), nil
 }
 
 func (w *Websocket) Write(data []byte) (int, error) {